@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// freedbIDPattern matches FreeDB disc IDs, used as cache keys before MusicBrainz disc IDs.
+var freedbIDPattern = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+// CollisionError reports two discs sharing the same FreeDB ID but having different TOCs.
+type CollisionError struct {
+	FreedbID string
+	Existing string
+	New      string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("FreeDB ID %s collision: already used by %s, not aliasing %s", e.FreedbID, e.Existing, e.New)
+}
+
+// IsFreedbID reports whether id looks like a FreeDB disc ID.
+func IsFreedbID(id string) bool {
+	return freedbIDPattern.MatchString(id)
+}
+
+// IsLegacyFolder reports whether id is a disc folder keyed by FreeDB ID, created before
+// discs were keyed by MusicBrainz disc ID, rather than an alias.
+func IsLegacyFolder(cacheLocation, id string) bool {
+	if !IsFreedbID(id) {
+		return false
+	}
+	info, err := os.Lstat(filepath.Join(cacheLocation, id))
+	return err == nil && info.IsDir()
+}
+
+// ResolveKey returns the cache key a disc ID refers to, following FreeDB ID aliases.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - discID: A cache key or a FreeDB ID alias.
+//
+// Returns:
+//   - string: The cache key, discID itself if it is not an alias.
+func ResolveKey(cacheLocation, discID string) string {
+	target, err := os.Readlink(filepath.Join(cacheLocation, discID))
+	if err != nil {
+		return discID
+	}
+	return filepath.Base(target)
+}
+
+// LinkAlias makes the FreeDB ID of a disc an alias of its cache key, as a relative symbolic link,
+// so the disc can still be found by its FreeDB ID. FreeDB IDs being collision-prone, an existing
+// alias or legacy folder of a disc with a different TOC is kept and reported as a CollisionError.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - freedbID: The FreeDB ID of the disc.
+//   - key: The cache key of the disc (its MusicBrainz disc ID).
+//   - toc: The disc TOC in MusicBrainz format, compared with the TOC of a legacy folder.
+//
+// Returns:
+//   - error: A *CollisionError on collision, or any error encountered while creating the link.
+func LinkAlias(cacheLocation, freedbID, key, toc string) error {
+	if freedbID == "" || freedbID == key {
+		return nil
+	}
+	aliasPath := filepath.Join(cacheLocation, freedbID)
+	info, err := os.Lstat(aliasPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return os.Symlink(key, aliasPath)
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(aliasPath)
+		if err != nil {
+			return err
+		}
+		if filepath.Base(target) == key {
+			return nil
+		}
+		if _, err := os.Stat(aliasPath); errors.Is(err, os.ErrNotExist) {
+			// Dangling alias of a removed disc
+			if err := os.Remove(aliasPath); err != nil {
+				return err
+			}
+			return os.Symlink(key, aliasPath)
+		}
+		return &CollisionError{FreedbID: freedbID, Existing: filepath.Base(target), New: key}
+	default:
+		// Legacy folder keyed by FreeDB ID
+		if metadata, err := ReadMetadata(cacheLocation, freedbID); err == nil && metadata.Toc != "" && metadata.Toc != toc {
+			return &CollisionError{FreedbID: freedbID, Existing: freedbID, New: key}
+		}
+		return nil
+	}
+}
+
+// removeAliases deletes the aliases pointing to key.
+func removeAliases(cacheLocation, key string) error {
+	dirEntries, err := os.ReadDir(cacheLocation)
+	if err != nil {
+		return err
+	}
+	for _, dirEntry := range dirEntries {
+		if dirEntry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		aliasPath := filepath.Join(cacheLocation, dirEntry.Name())
+		if target, err := os.Readlink(aliasPath); err == nil && filepath.Base(target) == key {
+			if err := os.Remove(aliasPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DanglingAliases lists the aliases whose disc was removed.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//
+// Returns:
+//   - []string: The names of the dangling aliases.
+//   - error: Any error encountered while listing the cache.
+func DanglingAliases(cacheLocation string) ([]string, error) {
+	dirEntries, err := os.ReadDir(cacheLocation)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dangling := []string{}
+	for _, dirEntry := range dirEntries {
+		if dirEntry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cacheLocation, dirEntry.Name())); errors.Is(err, os.ErrNotExist) {
+			dangling = append(dangling, dirEntry.Name())
+		}
+	}
+	return dangling, nil
+}
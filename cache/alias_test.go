@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// aliasToc is the TOC of the disc aliased in the tests, otherToc the TOC of another disc.
+const (
+	aliasToc = "1 3 100000 150 30000 60000"
+	otherToc = "1 2 100000 150 30000"
+)
+
+// legacyFolder creates a folder keyed by FreeDB ID, with a sidecar holding toc.
+func legacyFolder(t *testing.T, dir, freedbID, toc string) {
+	t.Helper()
+	if err := WriteMetadata(dir, &Metadata{DiscID: freedbID, Toc: toc, Source: SourceManual}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkAlias(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup prepares the cache before "0a000001" is aliased to the disc "key"
+		setup         func(t *testing.T, dir string)
+		wantCollision string
+		wantResolve   string
+	}{
+		{name: "new alias", setup: func(t *testing.T, dir string) {}, wantResolve: "key"},
+		{name: "alias to the same key", setup: func(t *testing.T, dir string) {
+			os.Symlink("key", filepath.Join(dir, "0a000001"))
+		}, wantResolve: "key"},
+		{name: "dangling alias replaced", setup: func(t *testing.T, dir string) {
+			os.Symlink("removed", filepath.Join(dir, "0a000001"))
+		}, wantResolve: "key"},
+		{name: "alias of another disc", setup: func(t *testing.T, dir string) {
+			os.Mkdir(filepath.Join(dir, "other"), 0755)
+			os.Symlink("other", filepath.Join(dir, "0a000001"))
+		}, wantCollision: "other", wantResolve: "other"},
+		{name: "legacy folder of the disc", setup: func(t *testing.T, dir string) {
+			legacyFolder(t, dir, "0a000001", aliasToc)
+		}, wantResolve: "0a000001"},
+		{name: "legacy folder of another disc", setup: func(t *testing.T, dir string) {
+			legacyFolder(t, dir, "0a000001", otherToc)
+		}, wantCollision: "0a000001", wantResolve: "0a000001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(dir, "key"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(t, dir)
+
+			err := LinkAlias(dir, "0a000001", "key", aliasToc)
+			var collision *CollisionError
+			if tt.wantCollision == "" && err != nil {
+				t.Errorf("LinkAlias() error = %v", err)
+			} else if tt.wantCollision != "" && (!errors.As(err, &collision) || collision.Existing != tt.wantCollision || collision.New != "key") {
+				t.Errorf("LinkAlias() error = %v, want a collision with %s", err, tt.wantCollision)
+			}
+			if got := ResolveKey(dir, "0a000001"); got != tt.wantResolve {
+				t.Errorf("ResolveKey() = %q, want %q", got, tt.wantResolve)
+			}
+		})
+	}
+}
+
+func TestLinkAliasNoAlias(t *testing.T) {
+	dir := t.TempDir()
+	for _, freedbID := range []string{"", "key"} {
+		if err := LinkAlias(dir, freedbID, "key", aliasToc); err != nil {
+			t.Errorf("LinkAlias(%q) error = %v", freedbID, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("LinkAlias() without FreeDB ID created %d files", len(entries))
+	}
+}
+
+func TestResolveKey(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "key"), 0755)
+	os.Symlink("key", filepath.Join(dir, "0a000001"))
+	for id, want := range map[string]string{"0a000001": "key", "key": "key", "missing": "missing"} {
+		if got := ResolveKey(dir, id); got != want {
+			t.Errorf("ResolveKey(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestDanglingAliases(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "key"), 0755)
+	os.Symlink("key", filepath.Join(dir, "0a000001"))
+	os.Symlink("removed", filepath.Join(dir, "0b000002"))
+	dangling, err := DanglingAliases(dir)
+	if err != nil || !reflect.DeepEqual(dangling, []string{"0b000002"}) {
+		t.Errorf("DanglingAliases() = %v, %v, want [0b000002]", dangling, err)
+	}
+	if dangling, err := DanglingAliases(filepath.Join(dir, "missing")); err != nil || len(dangling) != 0 {
+		t.Errorf("DanglingAliases() of a missing cache = %v, %v, want none", dangling, err)
+	}
+}
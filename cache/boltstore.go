@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+var (
+	// discsBucket holds a nested bucket per disc, mapping file names to contents.
+	discsBucket = []byte("discs")
+	// aliasesBucket maps FreeDB IDs to disc IDs.
+	aliasesBucket = []byte("aliases")
+)
+
+// boltLockTimeout is how long an operation waits for the database file lock.
+const boltLockTimeout = 30 * time.Second
+
+// BoltStore stores the whole cache in a single bbolt database file, which hosts can share over NFS.
+// Each operation takes a POSIX record lock on the lock file next to the database, shared while
+// reading and exclusive while writing, which NFS forwards to the server. It then opens the database
+// and closes it right after, so it reads what other hosts committed before (close-to-open
+// consistency). Sharing requires working NFS locks: NFSv4, or NFSv3 with lockd, not mounted with
+// the nolock or local_lock options.
+type BoltStore struct {
+	path string
+}
+
+// NewBoltStore opens the store in the database file at path, creating it if needed.
+//
+// Parameters:
+//   - path: The path of the database file.
+//
+// Returns:
+//   - *BoltStore: The database store.
+//   - error: An error if the database cannot be created.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := utils.CreateFolderIfNeeded(path); err != nil {
+		return nil, fmt.Errorf("Failed to create %s folder: %w", path, err)
+	}
+	s := &BoltStore{path: path}
+	err := s.update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(discsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(aliasesBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open cache store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// open takes the record lock of the database, exclusive if writing, and opens the database.
+//
+// Returns:
+//   - *bolt.DB: The open database, to be closed before releasing the lock.
+//   - *utils.FileLock: The held lock.
+//   - error: An error if the lock is not taken within boltLockTimeout or the database cannot be opened.
+func (s *BoltStore) open(writing bool) (*bolt.DB, *utils.FileLock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), boltLockTimeout)
+	defer cancel()
+	lock, err := utils.LockRecord(ctx, s.path+".lock", writing)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{ReadOnly: !writing, Timeout: boltLockTimeout})
+	if err != nil {
+		lock.Unlock()
+		return nil, nil, err
+	}
+	return db, lock, nil
+}
+
+// view runs fn in a read-only transaction, under a shared lock of the database.
+func (s *BoltStore) view(fn func(*bolt.Tx) error) error {
+	db, lock, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	defer db.Close()
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction, under an exclusive lock of the database.
+func (s *BoltStore) update(fn func(*bolt.Tx) error) error {
+	db, lock, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	defer db.Close()
+	return db.Update(fn)
+}
+
+// Get returns the content of a file of the disc.
+func (s *BoltStore) Get(discID, name string) ([]byte, error) {
+	var data []byte
+	err := s.view(func(tx *bolt.Tx) error {
+		disc := tx.Bucket(discsBucket).Bucket([]byte(discID))
+		if disc == nil {
+			return fmt.Errorf("%s/%s: %w", discID, name, os.ErrNotExist)
+		}
+		value := disc.Get([]byte(name))
+		if value == nil {
+			return fmt.Errorf("%s/%s: %w", discID, name, os.ErrNotExist)
+		}
+		// Values are only valid during the transaction
+		data = append([]byte{}, value...)
+		return nil
+	})
+	return data, err
+}
+
+// Put replaces the content of a file of the disc in a single transaction.
+func (s *BoltStore) Put(discID, name string, data []byte) error {
+	return s.update(func(tx *bolt.Tx) error {
+		disc, err := tx.Bucket(discsBucket).CreateBucketIfNotExists([]byte(discID))
+		if err != nil {
+			return err
+		}
+		return disc.Put([]byte(name), data)
+	})
+}
+
+// Has reports whether a file of the disc exists.
+func (s *BoltStore) Has(discID, name string) bool {
+	_, err := s.Get(discID, name)
+	return err == nil
+}
+
+// List returns the IDs of the stored discs, sorted.
+func (s *BoltStore) List() ([]string, error) {
+	discIDs := []string{}
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(discsBucket).ForEach(func(k, v []byte) error {
+			if v == nil {
+				discIDs = append(discIDs, string(k))
+			}
+			return nil
+		})
+	})
+	return discIDs, err
+}
+
+// Delete removes the disc and the aliases referring to it.
+func (s *BoltStore) Delete(discID string) error {
+	discID = s.Resolve(discID)
+	return s.update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(discsBucket).DeleteBucket([]byte(discID)); errors.Is(err, bolt.ErrBucketNotFound) {
+			return fmt.Errorf("disc %s is not cached: %w", discID, os.ErrNotExist)
+		} else if err != nil {
+			return err
+		}
+		aliases := tx.Bucket(aliasesBucket)
+		var stale [][]byte
+		if err := aliases.ForEach(func(k, v []byte) error {
+			if string(v) == discID {
+				stale = append(stale, append([]byte{}, k...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, alias := range stale {
+			if err := aliases.Delete(alias); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteFile removes a file of the disc in a single transaction.
+func (s *BoltStore) DeleteFile(discID, name string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		disc := tx.Bucket(discsBucket).Bucket([]byte(discID))
+		if disc == nil {
+			return nil
+		}
+		return disc.Delete([]byte(name))
+	})
+}
+
+// Alias makes alias refer to the disc key, replacing an alias of a removed disc.
+// toc is not used: the database holds no legacy entry keyed by FreeDB ID.
+func (s *BoltStore) Alias(alias, key, toc string) error {
+	if alias == "" || alias == key {
+		return nil
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		aliases := tx.Bucket(aliasesBucket)
+		if existing := aliases.Get([]byte(alias)); existing != nil && string(existing) != key &&
+			tx.Bucket(discsBucket).Bucket(existing) != nil {
+			return &CollisionError{FreedbID: alias, Existing: string(existing), New: key}
+		}
+		return aliases.Put([]byte(alias), []byte(key))
+	})
+}
+
+// Resolve returns the disc ID the alias id refers to, or id itself.
+func (s *BoltStore) Resolve(id string) string {
+	resolved := id
+	s.view(func(tx *bolt.Tx) error {
+		if key := tx.Bucket(aliasesBucket).Get([]byte(id)); key != nil {
+			resolved = string(key)
+		}
+		return nil
+	})
+	return resolved
+}
+
+// Close does nothing, the database file being only open during operations.
+func (s *BoltStore) Close() error {
+	return nil
+}
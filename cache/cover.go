@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CoverIndexFile is the name of the file listing the cover art images stored for a disc.
+const CoverIndexFile = "covers.json"
+
+// Cover art image types, as named in the stored file names.
+const (
+	CoverFront   = "front"
+	CoverBack    = "back"
+	CoverBooklet = "booklet"
+)
+
+// CoverImage describes a cover art image stored for a disc.
+//
+// Fields:
+//   - Type (string): The image type: CoverFront, CoverBack or CoverBooklet.
+//   - File (string): The stored file name, e.g. "front.jpg" or "booklet-02.png".
+//   - ID (string): The Cover Art Archive image ID.
+//   - Size (string): The downloaded size: "250", "500", "1200" or "full".
+//   - Width (int): The image width in pixels, once validated.
+//   - Height (int): The image height in pixels, once validated.
+//   - Comment (string): The Cover Art Archive comment, if any.
+type CoverImage struct {
+	Type    string `json:"type"`
+	File    string `json:"file"`
+	ID      string `json:"id,omitempty"`
+	Size    string `json:"size,omitempty"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// CoverIndex lists the cover art images stored for a disc.
+//
+// Fields:
+//   - Source (string): The cover source the images come from, e.g. "release" or "local".
+//   - Release (string): The MusicBrainz release ID of the disc, if known.
+//   - Images ([]CoverImage): The stored images, front first.
+type CoverIndex struct {
+	Source  string       `json:"source,omitempty"`
+	Release string       `json:"release,omitempty"`
+	Images  []CoverImage `json:"images"`
+}
+
+// Front returns the front image of the index, if any.
+func (i *CoverIndex) Front() (CoverImage, bool) {
+	for _, image := range i.Images {
+		if image.Type == CoverFront {
+			return image, true
+		}
+	}
+	return CoverImage{}, false
+}
+
+// GetCoverIndex reads the cover art index of a disc.
+//
+// Parameters:
+//   - store: The cache store.
+//   - discID: The disc ID.
+//
+// Returns:
+//   - *CoverIndex: The cover art index.
+//   - error: An error if the index is missing or cannot be parsed; os.ErrNotExist can be tested with errors.Is.
+func GetCoverIndex(store Store, discID string) (*CoverIndex, error) {
+	data, err := store.Get(discID, CoverIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	var index CoverIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s cover index: %w", discID, err)
+	}
+	return &index, nil
+}
+
+// PutCoverIndex writes the cover art index of a disc.
+//
+// Parameters:
+//   - store: The cache store.
+//   - discID: The disc ID.
+//   - index: The cover art index.
+//
+// Returns:
+//   - error: Any error encountered while writing the index.
+func PutCoverIndex(store Store, discID string, index *CoverIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.Put(discID, CoverIndexFile, data)
+}
+
+// FrontCover returns the file name of the front cover of a disc: the front image of its cover
+// index, or the cover.jpg saved by older versions.
+//
+// Parameters:
+//   - store: The cache store.
+//   - discID: The disc ID.
+//
+// Returns:
+//   - string: The front cover file name, empty if the disc has no cover art.
+func FrontCover(store Store, discID string) string {
+	if index, err := GetCoverIndex(store, discID); err == nil {
+		if front, ok := index.Front(); ok && store.Has(discID, front.File) {
+			return front.File
+		}
+	}
+	if store.Has(discID, CoverFile) {
+		return CoverFile
+	}
+	return ""
+}
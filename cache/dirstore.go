@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// DirStore stores each disc in a folder of the cache directory, aliases being symbolic links.
+// It keeps the cache index up to date as metadata sidecars are written and discs removed.
+type DirStore struct {
+	Root string
+}
+
+// NewDirStore creates a store in the cache directory root.
+//
+// Parameters:
+//   - root: The base directory for caching files.
+//
+// Returns:
+//   - *DirStore: The directory store.
+func NewDirStore(root string) *DirStore {
+	return &DirStore{Root: root}
+}
+
+// Path returns the path of a file in the disc folder.
+func (s *DirStore) Path(discID, name string) string {
+	return utils.CacheFilePath(s.Root, discID, name)
+}
+
+// Dir returns the cache directory.
+func (s *DirStore) Dir() string {
+	return s.Root
+}
+
+// Get reads a file of the disc folder.
+func (s *DirStore) Get(discID, name string) ([]byte, error) {
+	return os.ReadFile(s.Path(discID, name))
+}
+
+// Put writes a file of the disc folder atomically, creating the folder if needed.
+func (s *DirStore) Put(discID, name string, data []byte) error {
+	path := s.Path(discID, name)
+	if err := utils.CreateFolderIfNeeded(path); err != nil {
+		return fmt.Errorf("Failed to create %s folder: %w", path, err)
+	}
+	if err := utils.WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return fmt.Errorf("Failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Has reports whether a file of the disc folder exists.
+func (s *DirStore) Has(discID, name string) bool {
+	return utils.CheckIfFileExists(s.Path(discID, name))
+}
+
+// List returns the names of the disc folders, aliases excluded.
+func (s *DirStore) List() ([]string, error) {
+	dirEntries, err := os.ReadDir(s.Root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list cache %s: %w", s.Root, err)
+	}
+	discIDs := []string{}
+	for _, dirEntry := range dirEntries {
+		if isDiscFolder(dirEntry) {
+			discIDs = append(discIDs, dirEntry.Name())
+		}
+	}
+	return discIDs, nil
+}
+
+// Delete removes the disc folder, its aliases and its index entry.
+func (s *DirStore) Delete(discID string) error {
+	return Remove(s.Root, discID)
+}
+
+// DeleteFile removes a file of the disc folder.
+func (s *DirStore) DeleteFile(discID, name string) error {
+	if err := os.Remove(s.Path(discID, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Failed to remove %s: %w", s.Path(discID, name), err)
+	}
+	return nil
+}
+
+// Alias links alias to the disc folder, see LinkAlias.
+func (s *DirStore) Alias(alias, key, toc string) error {
+	return LinkAlias(s.Root, alias, key, toc)
+}
+
+// Resolve follows the alias id, see ResolveKey.
+func (s *DirStore) Resolve(id string) string {
+	return ResolveKey(s.Root, id)
+}
+
+// Close does nothing.
+func (s *DirStore) Close() error {
+	return nil
+}
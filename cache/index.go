@@ -0,0 +1,276 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+const (
+	// indexFileName is the name of the index file at the root of the cache.
+	indexFileName = "index.json"
+	// indexVersion is the version of the index format.
+	indexVersion = 1
+	// indexLockName is the name of the lock serializing index updates.
+	indexLockName = "index"
+)
+
+// Entry summarizes a cached disc in the index.
+//
+// Fields:
+//   - DiscID (string): The disc ID used as cache key.
+//   - FreedbID (string): The FreeDB ID of the disc, if known.
+//   - Artist (string): The artist of the disc.
+//   - Title (string): The title of the disc.
+//   - Source (string): The provider the metadata comes from.
+//   - MusicBrainzID (string): The MusicBrainz release ID, if known.
+//   - FetchedAt (time.Time): When the metadata was fetched.
+//   - HasCover (bool): Whether cover art was saved for the disc.
+type Entry struct {
+	DiscID        string    `json:"discId"`
+	FreedbID      string    `json:"freedbId,omitempty"`
+	Artist        string    `json:"artist"`
+	Title         string    `json:"title"`
+	Source        string    `json:"source"`
+	MusicBrainzID string    `json:"musicbrainzId,omitempty"`
+	FetchedAt     time.Time `json:"fetchedAt"`
+	HasCover      bool      `json:"hasCover"`
+}
+
+// Index lists the cached discs, keyed by disc ID.
+type Index struct {
+	Version int              `json:"version"`
+	Entries map[string]Entry `json:"entries"`
+}
+
+// Filter selects index entries in Query.
+type Filter func(Entry) bool
+
+// BySource selects the entries whose metadata comes from source.
+func BySource(source string) Filter {
+	return func(e Entry) bool {
+		return e.Source == source
+	}
+}
+
+// MissingCover selects the entries without cover art.
+func MissingCover() Filter {
+	return func(e Entry) bool {
+		return !e.HasCover
+	}
+}
+
+// FetchedBefore selects the entries fetched before t.
+func FetchedBefore(t time.Time) Filter {
+	return func(e Entry) bool {
+		return e.FetchedAt.Before(t)
+	}
+}
+
+// newEntry summarizes metadata into an index entry.
+func newEntry(metadata *Metadata) Entry {
+	return Entry{
+		DiscID:        metadata.DiscID,
+		FreedbID:      metadata.FreedbID,
+		Artist:        metadata.DiscInfo.Artist,
+		Title:         metadata.DiscInfo.Title,
+		Source:        metadata.Source,
+		MusicBrainzID: metadata.MusicBrainzID,
+		FetchedAt:     metadata.FetchedAt,
+		HasCover:      metadata.DiscInfo.CoverArtPath != "",
+	}
+}
+
+// indexPath returns the path of the index file.
+func indexPath(cacheLocation string) string {
+	return filepath.Join(cacheLocation, indexFileName)
+}
+
+// LoadIndex loads the cache index, rebuilding it from the sidecars if it is missing or unreadable.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//
+// Returns:
+//   - *Index: The cache index.
+//   - error: Any error encountered while rebuilding the index.
+func LoadIndex(cacheLocation string) (*Index, error) {
+	data, err := os.ReadFile(indexPath(cacheLocation))
+	if err == nil {
+		index := &Index{}
+		if err = json.Unmarshal(data, index); err == nil && index.Version == indexVersion {
+			if index.Entries == nil {
+				index.Entries = map[string]Entry{}
+			}
+			return index, nil
+		}
+	}
+	return RebuildIndex(cacheLocation)
+}
+
+// StoreIndex returns the index of the discs of a store: the index file of a directory store,
+// or an index built from the sidecars of any other store.
+//
+// Parameters:
+//   - store: The cache store.
+//
+// Returns:
+//   - *Index: The index of the store.
+//   - error: Any error encountered while listing the store.
+func StoreIndex(store Store) (*Index, error) {
+	if dir, ok := store.(*DirStore); ok {
+		return LoadIndex(dir.Root)
+	}
+	discIDs, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	index := &Index{Version: indexVersion, Entries: map[string]Entry{}}
+	for _, discID := range discIDs {
+		metadata, err := GetMetadata(store, discID)
+		if err != nil {
+			continue
+		}
+		entry := newEntry(metadata)
+		entry.HasCover = FrontCover(store, discID) != ""
+		index.Entries[entry.DiscID] = entry
+	}
+	return index, nil
+}
+
+// RebuildIndex scans the metadata sidecars of the cache and saves a fresh index.
+// Folders without a readable sidecar are skipped.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//
+// Returns:
+//   - *Index: The rebuilt index.
+//   - error: Any error encountered while listing the cache or saving the index.
+func RebuildIndex(cacheLocation string) (*Index, error) {
+	index := &Index{Version: indexVersion, Entries: map[string]Entry{}}
+	dirEntries, err := os.ReadDir(cacheLocation)
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list cache %s: %w", cacheLocation, err)
+	}
+	for _, dirEntry := range dirEntries {
+		if !isDiscFolder(dirEntry) {
+			continue
+		}
+		metadata, err := ReadMetadata(cacheLocation, dirEntry.Name())
+		if err != nil {
+			continue
+		}
+		index.Put(metadata)
+	}
+	return index, index.Save(cacheLocation)
+}
+
+// UpdateIndex adds or replaces the index entry of a disc and saves the index.
+// Concurrent updates, including from other processes, are serialized.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - metadata: The disc metadata.
+//
+// Returns:
+//   - error: Any error encountered while loading or saving the index.
+func UpdateIndex(cacheLocation string, metadata *Metadata) error {
+	return updateIndex(cacheLocation, func(index *Index) {
+		index.Put(metadata)
+	})
+}
+
+// updateIndex loads the index, applies update and saves it while holding the index lock.
+func updateIndex(cacheLocation string, update func(*Index)) error {
+	lock, err := utils.LockFile(context.Background(), utils.CacheLockPath(cacheLocation, indexLockName))
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	index, err := LoadIndex(cacheLocation)
+	if err != nil {
+		return err
+	}
+	update(index)
+	return index.Save(cacheLocation)
+}
+
+// Put adds or replaces the entry of a disc.
+func (i *Index) Put(metadata *Metadata) {
+	i.Entries[metadata.DiscID] = newEntry(metadata)
+}
+
+// Remove deletes the entry of a disc.
+func (i *Index) Remove(discID string) {
+	delete(i.Entries, discID)
+}
+
+// Query returns the entries matching every filter, sorted by disc ID.
+//
+// Parameters:
+//   - filters: The filters to apply. Every entry is returned if none is given.
+//
+// Returns:
+//   - []Entry: The matching entries.
+func (i *Index) Query(filters ...Filter) []Entry {
+	entries := []Entry{}
+	for _, entry := range i.Entries {
+		if matches(entry, filters) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].DiscID < entries[b].DiscID
+	})
+	return entries
+}
+
+// Save writes the index at the root of the cache. The file is replaced atomically.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//
+// Returns:
+//   - error: Any error encountered while writing the index.
+func (i *Index) Save(cacheLocation string) error {
+	i.Version = indexVersion
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cacheLocation, os.ModePerm); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(indexPath(cacheLocation), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// isDiscFolder reports whether a cache entry is a disc folder, as opposed to the index or the hidden lock folder.
+func isDiscFolder(dirEntry os.DirEntry) bool {
+	return dirEntry.IsDir() && !strings.HasPrefix(dirEntry.Name(), ".")
+}
+
+func matches(entry Entry, filters []Filter) bool {
+	for _, filter := range filters {
+		if !filter(entry) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexQuery(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	index := &Index{Entries: map[string]Entry{
+		"c": {DiscID: "c", Source: "gnudb", FetchedAt: now.Add(-10 * day)},
+		"a": {DiscID: "a", Source: "musicbrainz", FetchedAt: now.Add(-40 * day), HasCover: true},
+		"d": {DiscID: "d", Source: SourceManual, FetchedAt: now.Add(-400 * day)},
+		"b": {DiscID: "b", Source: "gnudb", FetchedAt: now.Add(-day), HasCover: true},
+	}}
+	policy := TTLPolicy{DefaultTTLKey: 30 * day, "gnudb": 7 * day}
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    []string
+	}{
+		{name: "no filter", want: []string{"a", "b", "c", "d"}},
+		{name: "source", filters: []Filter{BySource("gnudb")}, want: []string{"b", "c"}},
+		{name: "unknown source", filters: []Filter{BySource("discogs")}, want: []string{}},
+		{name: "missing cover", filters: []Filter{MissingCover()}, want: []string{"c", "d"}},
+		{name: "fetched before", filters: []Filter{FetchedBefore(now.Add(-5 * day))}, want: []string{"a", "c", "d"}},
+		{name: "expired", filters: []Filter{Expired(policy, now)}, want: []string{"a", "c"}},
+		{name: "expired without cover", filters: []Filter{Expired(policy, now), MissingCover()}, want: []string{"c"}},
+		{name: "source without cover", filters: []Filter{BySource("gnudb"), MissingCover()}, want: []string{"c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, entry := range index.Query(tt.filters...) {
+				got = append(got, entry.DiscID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// DiscStatus describes the files stored for a disc.
+//
+// Fields:
+//   - DiscID (string): The disc ID used as cache key.
+//   - Artist (string): The artist, from the sidecar or the CUE file.
+//   - Title (string): The title, from the sidecar or the CUE file.
+//   - Source (string): The metadata source, empty if there is no sidecar.
+//   - FetchedAt (time.Time): The fetch time from the sidecar, or the playlist modification time.
+//   - HasPlaylist (bool): Whether playlist.cue exists.
+//   - HasCover (bool): Whether a front cover exists.
+//   - HasMetadata (bool): Whether a readable metadata.json exists.
+//   - Folder (string): The folder of the disc, for stores keeping files on disk.
+//   - FileCount (int): The number of files in the folder, for stores keeping files on disk.
+//   - Size (int64): The total size of the folder in bytes, for stores keeping files on disk.
+//   - Problems ([]string): The inconsistencies found in the stored files.
+type DiscStatus struct {
+	DiscID      string
+	Artist      string
+	Title       string
+	Source      string
+	FetchedAt   time.Time
+	HasPlaylist bool
+	HasCover    bool
+	HasMetadata bool
+	Folder      string
+	FileCount   int
+	Size        int64
+	Problems    []string
+}
+
+// Empty reports whether the disc folder contains no file.
+func (s DiscStatus) Empty() bool {
+	return s.Folder != "" && s.FileCount == 0
+}
+
+// OrphanedCover reports whether the disc holds cover art without any playlist.
+func (s DiscStatus) OrphanedCover() bool {
+	return s.HasCover && !s.HasPlaylist
+}
+
+// Scan inspects every disc of a store.
+//
+// Parameters:
+//   - store: The cache store.
+//
+// Returns:
+//   - []DiscStatus: The status of each disc, sorted by disc ID.
+//   - error: Any error encountered while listing the store.
+func Scan(store Store) ([]DiscStatus, error) {
+	discIDs, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	statuses := []DiscStatus{}
+	for _, discID := range discIDs {
+		status, err := Stat(store, discID)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(a, b int) bool {
+		return statuses[a].DiscID < statuses[b].DiscID
+	})
+	return statuses, nil
+}
+
+// Stat inspects the files stored for a disc. The folder of a store keeping files on disk, a Locator,
+// is also checked for leftover temporary files, missing referenced cover art, and emptiness.
+//
+// Parameters:
+//   - store: The cache store.
+//   - discID: The disc ID, or a FreeDB ID alias.
+//
+// Returns:
+//   - DiscStatus: The status of the disc.
+//   - error: An error if the folder of the disc cannot be read.
+func Stat(store Store, discID string) (DiscStatus, error) {
+	discID = store.Resolve(discID)
+	status := DiscStatus{
+		DiscID:      discID,
+		HasPlaylist: store.Has(discID, PlaylistFile),
+		HasCover:    FrontCover(store, discID) != "",
+	}
+	if locator, ok := store.(Locator); ok {
+		if err := statFolder(locator, &status); err != nil {
+			return status, err
+		}
+	}
+
+	metadata, err := GetMetadata(store, discID)
+	switch {
+	case err == nil:
+		status.HasMetadata = true
+		status.Artist = metadata.DiscInfo.Artist
+		status.Title = metadata.DiscInfo.Title
+		status.Source = metadata.Source
+		status.FetchedAt = metadata.FetchedAt
+		if _, ok := store.(Locator); ok && metadata.DiscInfo.CoverArtPath != "" && !utils.CheckIfFileExists(metadata.DiscInfo.CoverArtPath) {
+			status.Problems = append(status.Problems, "cover art referenced in metadata is missing")
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if status.HasPlaylist {
+			status.Problems = append(status.Problems, "missing metadata sidecar")
+		}
+	default:
+		status.Problems = append(status.Problems, fmt.Sprintf("unreadable metadata sidecar: %v", err))
+	}
+	if index, err := GetCoverIndex(store, discID); err == nil {
+		for _, image := range index.Images {
+			if !store.Has(discID, image.File) {
+				status.Problems = append(status.Problems, fmt.Sprintf("cover art %s listed in the cover index is missing", image.File))
+			}
+		}
+	}
+
+	if status.HasPlaylist && !status.HasMetadata {
+		if data, err := store.Get(discID, PlaylistFile); err == nil {
+			status.Artist, status.Title = readCueHeader(data)
+		}
+	}
+
+	if status.Empty() {
+		status.Problems = append(status.Problems, "empty directory")
+	} else if status.OrphanedCover() {
+		status.Problems = append(status.Problems, "orphaned cover art")
+	} else if !status.HasPlaylist {
+		status.Problems = append(status.Problems, "missing playlist")
+	}
+	return status, nil
+}
+
+// statFolder counts the files of the disc folder of a store keeping files on disk, reports leftover
+// temporary files, and dates discs without sidecar by their playlist.
+func statFolder(locator Locator, status *DiscStatus) error {
+	status.Folder = filepath.Join(locator.Dir(), status.DiscID)
+	err := filepath.Walk(status.Folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			status.FileCount++
+			status.Size += info.Size()
+			if utils.IsTempFile(info.Name()) {
+				status.Problems = append(status.Problems, fmt.Sprintf("leftover temporary file %s", info.Name()))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to inspect %s: %w", status.Folder, err)
+	}
+	if info, err := os.Stat(locator.Path(status.DiscID, PlaylistFile)); err == nil {
+		status.FetchedAt = info.ModTime()
+	}
+	return nil
+}
+
+// Remove deletes the cache folder of a disc, its aliases and its index entry, waiting for any
+// generation of the disc in progress.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - discID: The disc ID, or a FreeDB ID alias.
+//
+// Returns:
+//   - error: An error if the disc is not cached or cannot be removed.
+func Remove(cacheLocation, discID string) error {
+	if filepath.Base(discID) != discID || discID == "." || discID == ".." {
+		return fmt.Errorf("invalid disc ID %q", discID)
+	}
+	discID = ResolveKey(cacheLocation, discID)
+	folder := filepath.Join(cacheLocation, discID)
+	if _, err := os.Stat(folder); err != nil {
+		return fmt.Errorf("disc %s is not cached: %w", discID, err)
+	}
+
+	lock, err := utils.LockDisc(context.Background(), cacheLocation, discID)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err := os.RemoveAll(folder); err != nil {
+		return fmt.Errorf("Failed to remove %s: %w", folder, err)
+	}
+	if err := removeAliases(cacheLocation, discID); err != nil {
+		return fmt.Errorf("Failed to remove %s aliases: %w", discID, err)
+	}
+	return updateIndex(cacheLocation, func(index *Index) {
+		index.Remove(discID)
+	})
+}
+
+// Prune removes the discs fetched before cutoff, as well as the empty disc folders of a store
+// keeping files on disk.
+//
+// Parameters:
+//   - store: The cache store.
+//   - cutoff: The discs fetched before this time are removed.
+//
+// Returns:
+//   - []string: The IDs of the removed discs.
+//   - error: Any error encountered while listing or removing.
+func Prune(store Store, cutoff time.Time) ([]string, error) {
+	index, err := StoreIndex(store)
+	if err != nil {
+		return nil, err
+	}
+	pruned := map[string]bool{}
+	for _, entry := range index.Query(FetchedBefore(cutoff)) {
+		pruned[entry.DiscID] = !entry.FetchedAt.IsZero()
+	}
+	if _, ok := store.(Locator); ok {
+		statuses, err := Scan(store)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range statuses {
+			if status.Empty() {
+				pruned[status.DiscID] = true
+			}
+		}
+	}
+	var discIDs []string
+	for discID, prune := range pruned {
+		if prune {
+			discIDs = append(discIDs, discID)
+		}
+	}
+	sort.Strings(discIDs)
+	removed := []string{}
+	for _, discID := range discIDs {
+		if err := store.Delete(discID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, discID)
+	}
+	return removed, nil
+}
+
+// readCueHeader returns the disc PERFORMER and TITLE of a CUE sheet, for discs cached without sidecar.
+func readCueHeader(data []byte) (string, string) {
+	var artist, title string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "PERFORMER "):
+			artist = strings.Trim(strings.TrimPrefix(line, "PERFORMER "), "\"")
+		case strings.HasPrefix(line, "TITLE "):
+			title = strings.Trim(strings.TrimPrefix(line, "TITLE "), "\"")
+		case strings.HasPrefix(line, "FILE "):
+			return artist, title
+		}
+	}
+	return artist, title
+}
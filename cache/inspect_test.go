@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+// putDisc stores a disc with a playlist, a sidecar fetched at fetchedAt and the given files.
+func putDisc(t *testing.T, store Store, discID string, fetchedAt time.Time, files ...string) {
+	t.Helper()
+	if err := store.Put(discID, PlaylistFile, []byte("PERFORMER \"Artist\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutMetadata(store, &Metadata{DiscID: discID, Source: "gnudb", FetchedAt: fetchedAt, DiscInfo: types.DiscInfo{Title: discID}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		if err := store.Put(discID, name, []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStat(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			putDisc(t, store, "complete", time.Now(), "front.jpg")
+			if err := PutCoverIndex(store, "complete", &CoverIndex{Images: []CoverImage{
+				{Type: CoverFront, File: "front.jpg"},
+				{Type: CoverBack, File: "back.jpg"},
+			}}); err != nil {
+				t.Fatal(err)
+			}
+			store.Put("legacy", PlaylistFile, []byte("PERFORMER \"Old Artist\"\nTITLE \"Old Album\"\nFILE \"cdda.wav\" WAVE\nTITLE \"Track\"\n"))
+			store.Put("orphan", CoverFile, []byte("data"))
+
+			tests := []struct {
+				discID   string
+				artist   string
+				title    string
+				cover    bool
+				problems []string
+			}{
+				{discID: "complete", title: "complete", cover: true, problems: []string{"cover art back.jpg listed in the cover index is missing"}},
+				{discID: "legacy", artist: "Old Artist", title: "Old Album", problems: []string{"missing metadata sidecar"}},
+				{discID: "orphan", cover: true, problems: []string{"orphaned cover art"}},
+			}
+			for _, tt := range tests {
+				status, err := Stat(store, tt.discID)
+				if err != nil {
+					t.Fatalf("Stat(%s) error = %v", tt.discID, err)
+				}
+				if status.Artist != tt.artist || status.Title != tt.title || status.HasCover != tt.cover || !reflect.DeepEqual(status.Problems, tt.problems) {
+					t.Errorf("Stat(%s) = %q %q, cover %v, problems %q, want %q %q, cover %v, problems %q", tt.discID,
+						status.Artist, status.Title, status.HasCover, status.Problems, tt.artist, tt.title, tt.cover, tt.problems)
+				}
+			}
+
+			statuses, err := Scan(store)
+			if err != nil || len(statuses) != 3 {
+				t.Errorf("Scan() = %d statuses, %v, want 3", len(statuses), err)
+			}
+		})
+	}
+}
+
+func TestStatFolder(t *testing.T) {
+	store := NewDirStore(t.TempDir())
+	putDisc(t, store, "disc", time.Now(), ".tmp-front.jpg-123")
+	os.Mkdir(store.Path("empty", ""), os.ModePerm)
+
+	status, err := Stat(store, "disc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Folder != store.Path("disc", "") || status.FileCount != 3 || len(status.Problems) != 1 || !strings.Contains(status.Problems[0], "leftover temporary file") {
+		t.Errorf("Stat() = folder %s, %d files, problems %q, want 3 files and a leftover temporary file", status.Folder, status.FileCount, status.Problems)
+	}
+	if status, err = Stat(store, "empty"); err != nil || !status.Empty() {
+		t.Errorf("Stat() of an empty folder = %+v, %v, want empty", status, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			putDisc(t, store, "old", now.Add(-48*time.Hour))
+			putDisc(t, store, "recent", now.Add(-time.Hour))
+			want := []string{"old"}
+			if dir, ok := store.(*DirStore); ok {
+				os.Mkdir(dir.Path("empty", ""), os.ModePerm)
+				want = []string{"empty", "old"}
+			}
+
+			removed, err := Prune(store, now.Add(-24*time.Hour))
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if !reflect.DeepEqual(removed, want) {
+				t.Errorf("Prune() = %q, want %q", removed, want)
+			}
+			if discIDs, _ := store.List(); !reflect.DeepEqual(discIDs, []string{"recent"}) {
+				t.Errorf("discs after Prune() = %q, want [recent]", discIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package cache manages the storage of the cached discs, the per-disc metadata sidecars stored
+// alongside the playlists, and the index built from them.
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+// MetadataVersion is the version of the sidecar format written by WriteMetadata.
+const MetadataVersion = 1
+
+// Metadata is the sidecar saved next to the playlist of each cached disc. It holds everything
+// needed to regenerate the playlist without querying the network.
+//
+// Fields:
+//   - Version (int): The sidecar format version.
+//   - DiscID (string): The disc ID used as cache key, i.e. the MusicBrainz disc ID when the TOC is known.
+//   - FreedbID (string): The FreeDB ID of the disc, aliased to the cache key.
+//   - Toc (string): The disc TOC in MusicBrainz format, empty when only a disc ID was known.
+//   - Source (string): The provider the metadata comes from.
+//   - MusicBrainzID (string): The MusicBrainz release ID, if known.
+//   - CoverSource (string): The cover source the cover art comes from, empty without cover art.
+//   - FetchedAt (time.Time): When the metadata was fetched.
+//   - DiscInfo (types.DiscInfo): The disc metadata.
+type Metadata struct {
+	Version       int            `json:"version"`
+	DiscID        string         `json:"discId"`
+	FreedbID      string         `json:"freedbId,omitempty"`
+	Toc           string         `json:"toc,omitempty"`
+	Source        string         `json:"source"`
+	MusicBrainzID string         `json:"musicbrainzId,omitempty"`
+	CoverSource   string         `json:"coverSource,omitempty"`
+	FetchedAt     time.Time      `json:"fetchedAt"`
+	DiscInfo      types.DiscInfo `json:"discInfo"`
+}
+
+// ReadMetadata reads the metadata sidecar of a disc cached in the cache directory.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - discID: The disc ID.
+//
+// Returns:
+//   - *Metadata: The disc metadata.
+//   - error: An error if the sidecar is missing or cannot be parsed; os.ErrNotExist can be tested with errors.Is.
+func ReadMetadata(cacheLocation, discID string) (*Metadata, error) {
+	return GetMetadata(NewDirStore(cacheLocation), discID)
+}
+
+// WriteMetadata writes the metadata sidecar of a disc cached in the cache directory and updates the cache index.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - metadata: The disc metadata. Its Version is set to MetadataVersion.
+//
+// Returns:
+//   - error: Any error encountered while writing the sidecar or the index.
+func WriteMetadata(cacheLocation string, metadata *Metadata) error {
+	return PutMetadata(NewDirStore(cacheLocation), metadata)
+}
+
+// GetMetadata reads the metadata sidecar of a disc from a store.
+//
+// Parameters:
+//   - store: The cache store.
+//   - discID: The disc ID.
+//
+// Returns:
+//   - *Metadata: The disc metadata.
+//   - error: An error if the sidecar is missing or cannot be parsed; os.ErrNotExist can be tested with errors.Is.
+func GetMetadata(store Store, discID string) (*Metadata, error) {
+	data, err := store.Get(discID, MetadataFile)
+	if err != nil {
+		return nil, err
+	}
+	var metadata Metadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s metadata: %w", discID, err)
+	}
+	if metadata.Version > MetadataVersion {
+		return nil, fmt.Errorf("unsupported metadata version %d for %s", metadata.Version, discID)
+	}
+	return &metadata, nil
+}
+
+// PutMetadata writes the metadata sidecar of a disc to a store, and updates the index of a directory store.
+//
+// Parameters:
+//   - store: The cache store.
+//   - metadata: The disc metadata. Its Version is set to MetadataVersion.
+//
+// Returns:
+//   - error: Any error encountered while writing the sidecar or the index.
+func PutMetadata(store Store, metadata *Metadata) error {
+	metadata.Version = MetadataVersion
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := store.Put(metadata.DiscID, MetadataFile, data); err != nil {
+		return err
+	}
+	if dir, ok := store.(*DirStore); ok {
+		return UpdateIndex(dir.Root, metadata)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			metadata := &Metadata{
+				DiscID:        "disc",
+				FreedbID:      "0a000001",
+				Toc:           aliasToc,
+				Source:        "musicbrainz",
+				MusicBrainzID: "rel",
+				CoverSource:   "coverartarchive",
+				FetchedAt:     time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+				DiscInfo: types.DiscInfo{
+					Artist:       "Artist",
+					Title:        "Album",
+					Tracks:       []string{"One", "Two"},
+					Medium:       2,
+					ISRCs:        []string{"USAAA0000001", "USAAA0000002"},
+					CoverArtPath: "front.jpg",
+				},
+			}
+			if err := PutMetadata(store, metadata); err != nil {
+				t.Fatal(err)
+			}
+			if metadata.Version != MetadataVersion {
+				t.Errorf("PutMetadata() version = %d, want %d", metadata.Version, MetadataVersion)
+			}
+			got, err := GetMetadata(store, "disc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, metadata) {
+				t.Errorf("GetMetadata() = %+v, want %+v", got, metadata)
+			}
+		})
+	}
+}
+
+func TestGetMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sidecar string
+		wantErr string
+	}{
+		{name: "newer version", sidecar: `{"version": ` + strconv.Itoa(MetadataVersion+1) + `, "discId": "disc"}`, wantErr: "unsupported metadata version"},
+		{name: "invalid JSON", sidecar: `{"version": `, wantErr: "Failed to parse"},
+	}
+	for name, store := range testStores(t) {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				if err := store.Put("disc", MetadataFile, []byte(tt.sidecar)); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := GetMetadata(store, "disc"); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("GetMetadata() error = %v, want %q", err, tt.wantErr)
+				}
+			})
+		}
+		t.Run(name+"/missing", func(t *testing.T) {
+			if _, err := GetMetadata(store, "missing"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("GetMetadata() of a missing disc: error = %v, want os.ErrNotExist", err)
+			}
+		})
+	}
+}
+
+func TestWriteMetadataUpdatesIndex(t *testing.T) {
+	dir := t.TempDir()
+	fetchedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	metadata := &Metadata{DiscID: "disc", FreedbID: "0a000001", Source: "gnudb", FetchedAt: fetchedAt, DiscInfo: types.DiscInfo{Artist: "Artist", Title: "Album"}}
+	if err := WriteMetadata(dir, metadata); err != nil {
+		t.Fatal(err)
+	}
+	metadata.DiscInfo.CoverArtPath = "front.jpg"
+	if err := WriteMetadata(dir, metadata); err != nil {
+		t.Fatal(err)
+	}
+	index, err := LoadIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Entry{DiscID: "disc", FreedbID: "0a000001", Artist: "Artist", Title: "Album", Source: "gnudb", FetchedAt: fetchedAt, HasCover: true}
+	if got := index.Entries["disc"]; len(index.Entries) != 1 || !reflect.DeepEqual(got, want) {
+		t.Errorf("index entries = %+v, want only %+v", index.Entries, want)
+	}
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// Migration describes the migration of a legacy disc folder keyed by FreeDB ID.
+//
+// Fields:
+//   - FreedbID (string): The legacy folder name, kept as an alias.
+//   - Key (string): The new cache key (MusicBrainz disc ID), empty if it cannot be computed.
+//   - Err (error): The reason the folder was not migrated, if any.
+type Migration struct {
+	FreedbID string
+	Key      string
+	Err      error
+}
+
+// MigrateLegacy moves the disc folders keyed by FreeDB ID to their MusicBrainz disc ID, computed from the
+// TOC saved in their sidecar, and leaves the FreeDB ID as an alias. Folders without sidecar TOC cannot be
+// migrated and are reported. The playlists referencing the old folder must be regenerated afterwards.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - dryRun: Only reports the planned migrations if true.
+//
+// Returns:
+//   - []Migration: The migrated, or failed, legacy folders.
+//   - error: Any error encountered while listing the cache.
+func MigrateLegacy(cacheLocation string, dryRun bool) ([]Migration, error) {
+	dirEntries, err := os.ReadDir(cacheLocation)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list cache %s: %w", cacheLocation, err)
+	}
+	migrations := []Migration{}
+	for _, dirEntry := range dirEntries {
+		if !isDiscFolder(dirEntry) || !IsFreedbID(dirEntry.Name()) {
+			continue
+		}
+		migration := Migration{FreedbID: dirEntry.Name()}
+		migration.Key, migration.Err = legacyKey(cacheLocation, migration.FreedbID)
+		if migration.Err == nil && !dryRun {
+			migration.Err = migrateFolder(cacheLocation, migration.FreedbID, migration.Key)
+		}
+		migrations = append(migrations, migration)
+	}
+	return migrations, nil
+}
+
+// legacyKey computes the new cache key of a legacy folder from its sidecar TOC.
+func legacyKey(cacheLocation, freedbID string) (string, error) {
+	metadata, err := ReadMetadata(cacheLocation, freedbID)
+	if err != nil {
+		return "", fmt.Errorf("no readable metadata: %w", err)
+	}
+	if metadata.Toc == "" {
+		return "", errors.New("no TOC in metadata")
+	}
+	toc, err := utils.ParseMusicBrainzToc(metadata.Toc)
+	if err != nil {
+		return "", err
+	}
+	key := toc.MusicBrainzDiscID()
+	if _, err := os.Lstat(filepath.Join(cacheLocation, key)); err == nil {
+		return key, fmt.Errorf("%s already exists", key)
+	}
+	return key, nil
+}
+
+// migrateFolder renames a legacy folder to key, updates its sidecar and index entry, and aliases the FreeDB ID.
+// Both the FreeDB ID and key are locked, so that a generation of the disc under its new key does not
+// race with the migration.
+func migrateFolder(cacheLocation, freedbID, key string) error {
+	for _, id := range []string{freedbID, key} {
+		lock, err := utils.LockDisc(context.Background(), cacheLocation, id)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+
+	oldFolder := filepath.Join(cacheLocation, freedbID)
+	newFolder := filepath.Join(cacheLocation, key)
+	// The disc may have been generated under key since legacyKey checked it
+	if _, err := os.Lstat(newFolder); err == nil {
+		return fmt.Errorf("%s already exists", key)
+	}
+	if err := os.Rename(oldFolder, newFolder); err != nil {
+		return err
+	}
+
+	metadata, err := ReadMetadata(cacheLocation, key)
+	if err != nil {
+		return err
+	}
+	metadata.DiscID = key
+	metadata.FreedbID = freedbID
+	if strings.HasPrefix(metadata.DiscInfo.CoverArtPath, oldFolder+string(filepath.Separator)) {
+		metadata.DiscInfo.CoverArtPath = filepath.Join(newFolder, strings.TrimPrefix(metadata.DiscInfo.CoverArtPath, oldFolder))
+	}
+	if err := WriteMetadata(cacheLocation, metadata); err != nil {
+		return err
+	}
+	if err := updateIndex(cacheLocation, func(index *Index) {
+		index.Remove(freedbID)
+	}); err != nil {
+		return err
+	}
+	return os.Symlink(key, oldFolder)
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// discKey returns the MusicBrainz disc ID of a TOC.
+func discKey(t *testing.T, mbToc string) string {
+	t.Helper()
+	toc, err := utils.ParseMusicBrainzToc(mbToc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toc.MusicBrainzDiscID()
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	dir := t.TempDir()
+	key := discKey(t, aliasToc)
+	legacyFolder(t, dir, "0a000001", aliasToc)
+	cover := filepath.Join(dir, "0a000001", "front.jpg")
+	os.WriteFile(cover, []byte("cover"), 0644)
+	metadata, _ := ReadMetadata(dir, "0a000001")
+	metadata.DiscInfo.CoverArtPath = cover
+	WriteMetadata(dir, metadata)
+	// Without sidecar TOC, the disc ID cannot be computed
+	legacyFolder(t, dir, "0b000002", "")
+
+	// A dry run plans the migration without moving anything
+	migrations, err := MigrateLegacy(dir, true)
+	if err != nil || len(migrations) != 2 || migrations[0].Key != key || migrations[0].Err != nil {
+		t.Fatalf("MigrateLegacy() dry run = %+v, %v, want 0a000001 migrated to %s", migrations, err, key)
+	}
+	if !IsLegacyFolder(dir, "0a000001") {
+		t.Fatal("dry run moved the legacy folder")
+	}
+
+	migrations, err = MigrateLegacy(dir, false)
+	if err != nil || len(migrations) != 2 {
+		t.Fatalf("MigrateLegacy() = %+v, %v, want 2 migrations", migrations, err)
+	}
+	if migrations[0].FreedbID != "0a000001" || migrations[0].Key != key || migrations[0].Err != nil {
+		t.Errorf("migration = %+v, want 0a000001 migrated to %s", migrations[0], key)
+	}
+	if migrations[1].FreedbID != "0b000002" || migrations[1].Err == nil || !strings.Contains(migrations[1].Err.Error(), "no TOC") {
+		t.Errorf("migration = %+v, want 0b000002 failed without TOC", migrations[1])
+	}
+
+	// The folder moved to its disc ID, with the FreeDB ID as alias
+	if IsLegacyFolder(dir, "0a000001") || ResolveKey(dir, "0a000001") != key {
+		t.Errorf("0a000001 resolves to %s, want an alias of %s", ResolveKey(dir, "0a000001"), key)
+	}
+	metadata, err = ReadMetadata(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.DiscID != key || metadata.FreedbID != "0a000001" || metadata.DiscInfo.CoverArtPath != filepath.Join(dir, key, "front.jpg") {
+		t.Errorf("migrated metadata = %+v, want the disc ID, FreeDB ID and cover path updated", metadata)
+	}
+	index, err := LoadIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := index.Entries["0a000001"]; ok {
+		t.Error("index still lists the legacy folder")
+	}
+	if _, ok := index.Entries[key]; !ok {
+		t.Errorf("index does not list %s", key)
+	}
+	if !IsLegacyFolder(dir, "0b000002") {
+		t.Error("folder without TOC moved")
+	}
+
+	// Migrating again finds nothing left to move
+	if migrations, err := MigrateLegacy(dir, false); err != nil || len(migrations) != 1 || migrations[0].FreedbID != "0b000002" {
+		t.Errorf("second MigrateLegacy() = %+v, %v, want only 0b000002", migrations, err)
+	}
+}
+
+func TestMigrateLegacyExistingKey(t *testing.T) {
+	dir := t.TempDir()
+	key := discKey(t, aliasToc)
+	legacyFolder(t, dir, "0a000001", aliasToc)
+	// The disc was generated again under its disc ID before the migration
+	if err := WriteMetadata(dir, &Metadata{DiscID: key, Toc: aliasToc, Source: SourceManual}); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := MigrateLegacy(dir, false)
+	if err != nil || len(migrations) != 1 || migrations[0].Err == nil {
+		t.Fatalf("MigrateLegacy() = %+v, %v, want the migration refused", migrations, err)
+	}
+	if !IsLegacyFolder(dir, "0a000001") {
+		t.Error("legacy folder moved over the existing disc")
+	}
+}
+
+func TestMigrateFolderLocksKey(t *testing.T) {
+	dir := t.TempDir()
+	key := discKey(t, aliasToc)
+	legacyFolder(t, dir, "0a000001", aliasToc)
+	lock, err := utils.LockDisc(context.Background(), dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- migrateFolder(dir, "0a000001", key) }()
+	select {
+	case err := <-done:
+		t.Fatalf("migrateFolder() = %v while %s is locked, want it to wait", err, key)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// A generation holding the lock writes the disc under its key in the meantime
+	WriteMetadata(dir, &Metadata{DiscID: key, Toc: aliasToc, Source: SourceManual})
+	lock.Unlock()
+	if err := <-done; err == nil {
+		t.Error("migrateFolder() over a disc generated meanwhile: error = nil")
+	}
+	if !IsLegacyFolder(dir, "0a000001") {
+		t.Error("legacy folder moved over the disc generated meanwhile")
+	}
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Names of the files stored for each disc.
+const (
+	PlaylistFile = "playlist.cue"
+	MetadataFile = "metadata.json"
+	// CoverFile is the front cover saved by older versions, before the cover index.
+	CoverFile = "cover.jpg"
+)
+
+// Cache storage backends selected by the cacheBackend setting.
+const (
+	BackendDir  = "dir"
+	BackendBolt = "bolt"
+)
+
+// Store holds the files of the cached discs, i.e. playlists, cover art and metadata sidecars,
+// each addressed by disc ID and file name.
+type Store interface {
+	// Get returns the content of a file. A missing file is reported as os.ErrNotExist.
+	Get(discID, name string) ([]byte, error)
+	// Put replaces the content of a file, atomically.
+	Put(discID, name string, data []byte) error
+	// Has reports whether a file exists.
+	Has(discID, name string) bool
+	// List returns the IDs of the cached discs, sorted.
+	List() ([]string, error)
+	// Delete removes a disc, its files and its aliases.
+	Delete(discID string) error
+	// DeleteFile removes a file of a disc. A missing file is not an error.
+	DeleteFile(discID, name string) error
+	// Alias makes alias, a FreeDB ID, refer to the disc key. An alias of a disc with a different
+	// TOC is kept and reported as a *CollisionError.
+	Alias(alias, key, toc string) error
+	// Resolve returns the disc ID id refers to, id itself if it is not an alias.
+	Resolve(id string) string
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Locator is implemented by the stores keeping files as regular files, which playlists can reference by path.
+type Locator interface {
+	// Path returns the path of a file.
+	Path(discID, name string) string
+	// Dir returns the directory holding the disc folders, their aliases and the index.
+	Dir() string
+}
+
+// FileRef returns the path of a stored file if the store has one, or "<discID>/<name>" otherwise.
+//
+// Parameters:
+//   - store: The cache store.
+//   - discID: The disc ID.
+//   - name: The file name.
+//
+// Returns:
+//   - string: The path or the reference of the file.
+func FileRef(store Store, discID, name string) string {
+	if locator, ok := store.(Locator); ok {
+		return locator.Path(discID, name)
+	}
+	return discID + "/" + name
+}
+
+// OpenStore opens the cache store of a backend: the cache directory by default, or a bbolt
+// database file with BackendBolt.
+//
+// Parameters:
+//   - backend: The storage backend, BackendDir if empty.
+//   - cacheLocation: The base directory for caching files.
+//   - storePath: The path of the database file. Defaults to cache.db in cacheLocation.
+//
+// Returns:
+//   - Store: The cache store.
+//   - error: An error if the backend is unknown or the store cannot be opened.
+func OpenStore(backend, cacheLocation, storePath string) (Store, error) {
+	switch backend {
+	case "", BackendDir:
+		return NewDirStore(cacheLocation), nil
+	case BackendBolt:
+		if storePath == "" {
+			storePath = filepath.Join(cacheLocation, "cache.db")
+		}
+		return NewBoltStore(storePath)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", backend)
+	}
+}
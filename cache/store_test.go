@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testStores returns an empty store of each backend, by backend name.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Store{BackendDir: NewDirStore(t.TempDir()), BackendBolt: bolt}
+}
+
+func TestStoreDeleteFile(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.DeleteFile("disc", "front.png"); err != nil {
+				t.Errorf("DeleteFile() of a missing disc: error = %v", err)
+			}
+			if err := store.Put("disc", "front.png", []byte("data")); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.DeleteFile("disc", "front.png"); err != nil || store.Has("disc", "front.png") {
+				t.Errorf("DeleteFile() error = %v, file kept = %v", err, store.Has("disc", "front.png"))
+			}
+			if err := store.DeleteFile("disc", "front.png"); err != nil {
+				t.Errorf("DeleteFile() of a missing file: error = %v", err)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	tests := []struct {
+		name string
+		// run operates on a store holding the discs "a" and "b", each with a playlist reading its ID,
+		// "a" being aliased by "0a000001"
+		run func(t *testing.T, store Store)
+	}{
+		{"get", func(t *testing.T, store Store) {
+			if data, err := store.Get("a", PlaylistFile); err != nil || string(data) != "a" {
+				t.Errorf("Get() = %q, %v, want \"a\"", data, err)
+			}
+			if _, err := store.Get("a", "missing"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Get() of a missing file: error = %v, want os.ErrNotExist", err)
+			}
+			if _, err := store.Get("missing", PlaylistFile); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Get() of a missing disc: error = %v, want os.ErrNotExist", err)
+			}
+		}},
+		{"put replaces", func(t *testing.T, store Store) {
+			if err := store.Put("a", PlaylistFile, []byte("new")); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := store.Get("a", PlaylistFile); string(data) != "new" {
+				t.Errorf("Get() after Put() = %q, want \"new\"", data)
+			}
+		}},
+		{"has", func(t *testing.T, store Store) {
+			if !store.Has("a", PlaylistFile) || store.Has("a", "missing") || store.Has("missing", PlaylistFile) {
+				t.Error("Has() does not match the stored files")
+			}
+		}},
+		{"list excludes aliases", func(t *testing.T, store Store) {
+			if discIDs, err := store.List(); err != nil || !reflect.DeepEqual(discIDs, []string{"a", "b"}) {
+				t.Errorf("List() = %q, %v, want [a b]", discIDs, err)
+			}
+		}},
+		{"resolve", func(t *testing.T, store Store) {
+			if got := store.Resolve("0a000001"); got != "a" {
+				t.Errorf("Resolve(alias) = %q, want \"a\"", got)
+			}
+			if got := store.Resolve("b"); got != "b" {
+				t.Errorf("Resolve(key) = %q, want \"b\"", got)
+			}
+			if got := store.Resolve("missing"); got != "missing" {
+				t.Errorf("Resolve(unknown) = %q, want \"missing\"", got)
+			}
+		}},
+		{"alias again and to itself", func(t *testing.T, store Store) {
+			for _, alias := range []string{"0a000001", "a", ""} {
+				if err := store.Alias(alias, "a", ""); err != nil {
+					t.Errorf("Alias(%q, a) error = %v", alias, err)
+				}
+			}
+			if got := store.Resolve("0a000001"); got != "a" {
+				t.Errorf("Resolve(alias) = %q, want \"a\"", got)
+			}
+		}},
+		{"alias collision", func(t *testing.T, store Store) {
+			var collision *CollisionError
+			if err := store.Alias("0a000001", "b", ""); !errors.As(err, &collision) || collision.Existing != "a" || collision.New != "b" {
+				t.Errorf("Alias() of another disc: error = %v, want a collision with a", err)
+			}
+			if got := store.Resolve("0a000001"); got != "a" {
+				t.Errorf("Resolve(alias) after a collision = %q, want \"a\"", got)
+			}
+		}},
+		{"delete", func(t *testing.T, store Store) {
+			if err := store.Delete("a"); err != nil {
+				t.Fatal(err)
+			}
+			if store.Has("a", PlaylistFile) || store.Resolve("0a000001") != "0a000001" {
+				t.Error("Delete() kept the disc or its alias")
+			}
+			if discIDs, _ := store.List(); !reflect.DeepEqual(discIDs, []string{"b"}) {
+				t.Errorf("List() after Delete() = %q, want [b]", discIDs)
+			}
+			if err := store.Delete("a"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Delete() of a missing disc: error = %v, want os.ErrNotExist", err)
+			}
+			// The alias of the removed disc can be reused
+			if err := store.Alias("0a000001", "b", ""); err != nil || store.Resolve("0a000001") != "b" {
+				t.Errorf("Alias() replacing a removed disc: error = %v, resolves to %q", err, store.Resolve("0a000001"))
+			}
+		}},
+		{"delete by alias", func(t *testing.T, store Store) {
+			if err := store.Delete("0a000001"); err != nil {
+				t.Fatal(err)
+			}
+			if store.Has("a", PlaylistFile) {
+				t.Error("Delete(alias) kept the disc")
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, store := range testStores(t) {
+				t.Run(name, func(t *testing.T) {
+					for _, discID := range []string{"a", "b"} {
+						if err := store.Put(discID, PlaylistFile, []byte(discID)); err != nil {
+							t.Fatal(err)
+						}
+					}
+					if err := store.Alias("0a000001", "a", ""); err != nil {
+						t.Fatal(err)
+					}
+					tt.run(t, store)
+				})
+			}
+		})
+	}
+}
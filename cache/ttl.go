@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"time"
+)
+
+// DefaultTTLKey is the TTLPolicy key applying to sources without their own TTL.
+const DefaultTTLKey = "default"
+
+// SourceManual is the metadata source of discs whose metadata was edited by the user. Unlike the
+// other sources, the default TTL does not apply to it, so edits are not overwritten by refreshes.
+const SourceManual = "manual"
+
+// TTLPolicy holds the time to live of cached discs per metadata source.
+// A missing or zero TTL means cached discs never expire.
+type TTLPolicy map[string]time.Duration
+
+// TTL returns the time to live of discs whose metadata comes from source.
+func (p TTLPolicy) TTL(source string) time.Duration {
+	if ttl, ok := p[source]; ok {
+		return ttl
+	}
+	if source == SourceManual {
+		return 0
+	}
+	return p[DefaultTTLKey]
+}
+
+// Expired reports whether metadata fetched at fetchedAt from source is stale at now.
+//
+// Parameters:
+//   - source: The metadata source.
+//   - fetchedAt: When the metadata was fetched.
+//   - now: The reference time.
+//
+// Returns:
+//   - bool: True if the TTL of source is set and elapsed.
+func (p TTLPolicy) Expired(source string, fetchedAt time.Time, now time.Time) bool {
+	ttl := p.TTL(source)
+	return ttl > 0 && fetchedAt.Add(ttl).Before(now)
+}
+
+// Expired selects the index entries that are stale at now according to policy.
+func Expired(policy TTLPolicy, now time.Time) Filter {
+	return func(e Entry) bool {
+		return policy.Expired(e.Source, e.FetchedAt, now)
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLPolicyExpired(t *testing.T) {
+	fetchedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	policy := TTLPolicy{DefaultTTLKey: 30 * day, "gnudb": 7 * day, "musicbrainz": 0}
+	tests := []struct {
+		name   string
+		policy TTLPolicy
+		source string
+		age    time.Duration
+		want   bool
+	}{
+		{name: "own TTL not elapsed", policy: policy, source: "gnudb", age: 7 * day, want: false},
+		{name: "own TTL elapsed", policy: policy, source: "gnudb", age: 7*day + time.Second, want: true},
+		{name: "default TTL not elapsed", policy: policy, source: "musicbrainz-release", age: 29 * day, want: false},
+		{name: "default TTL elapsed", policy: policy, source: "musicbrainz-release", age: 31 * day, want: true},
+		{name: "zero TTL never expires", policy: policy, source: "musicbrainz", age: 3650 * day, want: false},
+		{name: "manual ignores the default TTL", policy: policy, source: SourceManual, age: 3650 * day, want: false},
+		{name: "manual with its own TTL", policy: TTLPolicy{DefaultTTLKey: day, SourceManual: 2 * day}, source: SourceManual, age: 3 * day, want: true},
+		{name: "empty policy", policy: nil, source: "gnudb", age: 3650 * day, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Expired(tt.source, fetchedAt, fetchedAt.Add(tt.age)); got != tt.want {
+				t.Errorf("Expired(%q, age %v) = %v, want %v", tt.source, tt.age, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiredFilter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	filter := Expired(TTLPolicy{DefaultTTLKey: time.Hour}, now)
+	tests := []struct {
+		entry Entry
+		want  bool
+	}{
+		{Entry{Source: "gnudb", FetchedAt: now.Add(-2 * time.Hour)}, true},
+		{Entry{Source: "gnudb", FetchedAt: now.Add(-time.Minute)}, false},
+		{Entry{Source: SourceManual, FetchedAt: now.Add(-2 * time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		if got := filter(tt.entry); got != tt.want {
+			t.Errorf("Expired filter of %s fetched %v ago = %v, want %v", tt.entry.Source, now.Sub(tt.entry.FetchedAt), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// runCache dispatches the cache subcommands.
+func runCache(cuerConfig *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing cache subcommand: list, show, cat, rm, prune, verify or migrate")
+	}
+	store, err := cache.OpenStore(cuerConfig.CacheBackend, cuerConfig.GetCacheLocation(), cuerConfig.CacheStore)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	switch args[0] {
+	case "list":
+		return cacheList(store, args[1:])
+	case "show":
+		return cacheShow(store, args[1:])
+	case "cat":
+		return cacheCat(store, args[1:])
+	case "rm":
+		return cacheRemove(store, args[1:])
+	case "prune":
+		return cachePrune(cuerConfig, store, args[1:])
+	case "verify":
+		return cacheVerify(store, args[1:])
+	case "migrate":
+		return cacheMigrate(cuerConfig, store, args[1:])
+	default:
+		return fmt.Errorf("unknown cache subcommand %q", args[0])
+	}
+}
+
+// cacheList prints one line per disc of the store index.
+func cacheList(store cache.Store, args []string) error {
+	fs := newFlagSet("cache list", "[--source <source>] [--missing-cover]")
+	source := fs.String("source", "", "only list discs whose metadata comes from this source")
+	missingCover := fs.Bool("missing-cover", false, "only list discs without cover art")
+	fs.Parse(args)
+
+	index, err := cache.StoreIndex(store)
+	if err != nil {
+		return err
+	}
+	var filters []cache.Filter
+	if *source != "" {
+		filters = append(filters, cache.BySource(*source))
+	}
+	if *missingCover {
+		filters = append(filters, cache.MissingCover())
+	}
+	entries := index.Query(filters...)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DISC ID\tARTIST\tTITLE\tSOURCE\tCOVER")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.DiscID, orDash(entry.Artist), orDash(entry.Title),
+			orDash(entry.Source), yesNo(entry.HasCover))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d disc(s) listed, %d cached\n", len(entries), len(index.Entries))
+	return nil
+}
+
+// cacheShow prints the details of a cached disc.
+func cacheShow(store cache.Store, args []string) error {
+	fs := newFlagSet("cache show", "<disc-id>")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one disc ID")
+	}
+	discID := store.Resolve(fs.Arg(0))
+	metadata, err := cache.GetMetadata(store, discID)
+	if err != nil {
+		return err
+	}
+	status, err := cache.Stat(store, discID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Disc ID:     %s\n", metadata.DiscID)
+	if status.Folder != "" {
+		fmt.Printf("Folder:      %s\n", status.Folder)
+	}
+	fmt.Printf("Artist:      %s\n", orDash(metadata.DiscInfo.Artist))
+	fmt.Printf("Title:       %s\n", orDash(metadata.DiscInfo.Title))
+	fmt.Printf("Source:      %s\n", orDash(metadata.Source))
+	fmt.Printf("Fetched at:  %s\n", metadata.FetchedAt.Format(time.RFC3339))
+	if status.Folder != "" {
+		fmt.Printf("Size:        %s in %d file(s)\n", formatSize(status.Size), status.FileCount)
+	}
+	printMetadata(metadata)
+	fmt.Printf("Cover:       %s\n", orDash(cache.FrontCover(store, discID)))
+	printTracks(metadata)
+	for _, problem := range status.Problems {
+		fmt.Printf("Problem:     %s\n", problem)
+	}
+	return nil
+}
+
+// cacheCat writes a file of a cached disc to stdout, the playlist by default.
+func cacheCat(store cache.Store, args []string) error {
+	fs := newFlagSet("cache cat", "<disc-id> [file]")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a disc ID and an optional file name")
+	}
+	name := cache.PlaylistFile
+	if fs.NArg() == 2 {
+		name = fs.Arg(1)
+	}
+	data, err := store.Get(store.Resolve(fs.Arg(0)), name)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// cacheRemove deletes the given discs from the store.
+func cacheRemove(store cache.Store, args []string) error {
+	fs := newFlagSet("cache rm", "<disc-id>...")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one disc ID")
+	}
+	var errs []error
+	for _, discID := range fs.Args() {
+		if err := store.Delete(discID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Printf("removed %s\n", discID)
+	}
+	return errors.Join(errs...)
+}
+
+// cachePrune deletes the discs fetched before the given age, empty folders, and the cover art responses
+// not used since then.
+func cachePrune(cuerConfig *config.Config, store cache.Store, args []string) error {
+	fs := newFlagSet("cache prune", "--older-than <age>")
+	olderThan := fs.String("older-than", "", "remove discs fetched longer ago than this age (e.g. 720h, 30d)")
+	fs.Parse(args)
+	if *olderThan == "" {
+		fs.Usage()
+		return fmt.Errorf("--older-than is required")
+	}
+	age, err := config.ParseDuration(*olderThan)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-age)
+	removed, err := cache.Prune(store, cutoff)
+	for _, discID := range removed {
+		fmt.Printf("removed %s\n", discID)
+	}
+	fmt.Printf("%d disc(s) pruned\n", len(removed))
+
+	responses, httpErr := utils.PruneHTTPCache(filepath.Join(cuerConfig.GetCacheLocation(), cue.HTTPCacheDir), cutoff)
+	fmt.Printf("%d cached HTTP response(s) pruned\n", responses)
+	return errors.Join(err, httpErr)
+}
+
+// cacheVerify reports the inconsistencies of the store, and fixes them with --fix. The dangling
+// aliases and the index file are only checked in a store keeping files on disk.
+func cacheVerify(store cache.Store, args []string) error {
+	fs := newFlagSet("cache verify", "[--fix]")
+	fix := fs.Bool("fix", false, "remove empty folders, orphaned cover art and dangling aliases, and rebuild the index")
+	fs.Parse(args)
+
+	statuses, err := cache.Scan(store)
+	if err != nil {
+		return err
+	}
+	index, err := cache.StoreIndex(store)
+	if err != nil {
+		return err
+	}
+
+	var problems int
+	var total int64
+	cached := map[string]bool{}
+	for _, status := range statuses {
+		cached[status.DiscID] = true
+		total += status.Size
+		for _, problem := range status.Problems {
+			problems++
+			fmt.Printf("%s: %s\n", status.DiscID, problem)
+		}
+		if *fix && (status.Empty() || status.OrphanedCover()) {
+			if err := store.Delete(status.DiscID); err != nil {
+				return err
+			}
+			fmt.Printf("%s: removed\n", status.DiscID)
+		}
+	}
+	for _, entry := range index.Query() {
+		if !cached[entry.DiscID] {
+			problems++
+			fmt.Printf("%s: stale index entry\n", entry.DiscID)
+		}
+	}
+	if locator, ok := store.(cache.Locator); ok {
+		dangling, err := cache.DanglingAliases(locator.Dir())
+		if err != nil {
+			return err
+		}
+		for _, alias := range dangling {
+			problems++
+			fmt.Printf("%s: dangling alias\n", alias)
+			if *fix {
+				if err := os.Remove(filepath.Join(locator.Dir(), alias)); err != nil {
+					return err
+				}
+				fmt.Printf("%s: removed\n", alias)
+			}
+		}
+		if *fix {
+			if _, err := cache.RebuildIndex(locator.Dir()); err != nil {
+				return err
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("%d disc(s) checked, %d problem(s) found", len(statuses), problems)
+	if _, ok := store.(cache.Locator); ok {
+		summary += ", total size " + formatSize(total)
+	}
+	fmt.Println(summary)
+	if problems > 0 && !*fix {
+		return fmt.Errorf("%d problem(s) found", problems)
+	}
+	return nil
+}
+
+// cacheMigrate moves the disc folders keyed by FreeDB ID to their MusicBrainz disc ID and regenerates
+// their playlists, so the cover art paths they reference are updated. Only a store keeping files on
+// disk can hold such folders, saved by older versions.
+func cacheMigrate(cuerConfig *config.Config, store cache.Store, args []string) error {
+	fs := newFlagSet("cache migrate", "[--dry-run]")
+	dryRun := fs.Bool("dry-run", false, "only list the folders that would be migrated")
+	fs.Parse(args)
+
+	locator, ok := store.(cache.Locator)
+	if !ok {
+		fmt.Println("0 folder(s) migrated, the store holds no folder keyed by FreeDB ID")
+		return nil
+	}
+	migrations, err := cache.MigrateLegacy(locator.Dir(), *dryRun)
+	if err != nil {
+		return err
+	}
+	generator, err := cue.NewGenerator(cuerConfig, cue.WithStore(store))
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, migration := range migrations {
+		if migration.Err == nil && !*dryRun {
+			_, migration.Err = generator.Regenerate(migration.Key)
+		}
+		if migration.Err != nil {
+			failed++
+			fmt.Printf("%s: not migrated: %v\n", migration.FreedbID, migration.Err)
+			continue
+		}
+		fmt.Printf("%s -> %s\n", migration.FreedbID, migration.Key)
+	}
+	fmt.Printf("%d folder(s) migrated, %d failed\n", len(migrations)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d folder(s) not migrated", failed)
+	}
+	return nil
+}
+
+// printMetadata prints the identifiers and release details of a disc sidecar.
+func printMetadata(metadata *cache.Metadata) {
+	fmt.Printf("FreeDB ID:   %s\n", orDash(metadata.FreedbID))
+	fmt.Printf("MusicBrainz: %s\n", orDash(metadata.MusicBrainzID))
+	fmt.Printf("TOC:         %s\n", orDash(metadata.Toc))
+	fmt.Printf("Date:        %s\n", orDash(metadata.DiscInfo.ReleaseDate))
+	fmt.Printf("Genre:       %s\n", orDash(metadata.DiscInfo.Genre))
+}
+
+// printTracks prints the track list of a disc sidecar.
+func printTracks(metadata *cache.Metadata) {
+	fmt.Println("Tracks:")
+	for i, track := range metadata.DiscInfo.Tracks {
+		fmt.Printf("  %02d. %s\n", i+1, track)
+	}
+}
+
+// formatSize formats a size in bytes with a binary unit.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
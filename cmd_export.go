@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/library"
+)
+
+// runExport exports cached discs to the music library.
+func runExport(cuerConfig *config.Config, args []string) error {
+	fs := newFlagSet("export", "[--root dir] [--template t] [--mode m] [--on-collision c] [--file name] [--all | disc-id...]")
+	root := fs.String("root", cuerConfig.ExportRoot, "music library folder")
+	template := fs.String("template", cuerConfig.ExportTemplate, "path template of the exported sheet, relative to the library")
+	mode := fs.String("mode", cuerConfig.ExportMode, "copy, hardlink or symlink the cached files")
+	collision := fs.String("on-collision", cuerConfig.ExportCollision, "when a path holds another file: rename, overwrite or skip")
+	file := fs.String("file", cache.PlaylistFile, "cached playlist to export")
+	all := fs.Bool("all", false, "export every cached disc")
+	fs.Parse(args)
+
+	store, err := cache.OpenStore(cuerConfig.CacheBackend, cuerConfig.GetCacheLocation(), cuerConfig.CacheStore)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	exporter, err := library.NewExporter(store, *root, *template, *mode, *collision)
+	if err != nil {
+		return err
+	}
+	exporter.SetFile(*file)
+
+	discIDs := fs.Args()
+	if *all {
+		if discIDs, err = store.List(); err != nil {
+			return err
+		}
+	}
+	if len(discIDs) == 0 {
+		return fmt.Errorf("missing disc ID, or --all")
+	}
+
+	var failed int
+	for _, discID := range discIDs {
+		export, err := exporter.Export(discID)
+		if err != nil {
+			failed++
+			cuerConfig.GetLogger().Error("export failed", "disc_id", discID, "error", err)
+			continue
+		}
+		printExport(export)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d export(s) failed", failed)
+	}
+	return nil
+}
+
+// printExport prints the paths of an export.
+func printExport(export library.Export) {
+	for _, path := range []string{export.Sheet, export.Cover} {
+		if path != "" {
+			fmt.Printf("%s: %s\n", export.DiscID, path)
+		}
+	}
+	for _, path := range export.Skipped {
+		fmt.Printf("%s: %s exists, skipped\n", export.DiscID, path)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+)
+
+// runLookup identifies a disc and prints its metadata as a JSON Report, without reading or
+// writing the cache. The disc flags may be given before or after the command name.
+func runLookup(cuerConfig *config.Config, args []string) error {
+	fs := newFlagSet("lookup", "[--device <device>] [--toc <toc>] [--musicbrainz <release_id>] [--disc-id <disc_id>] [--from-drive] [--medium <n>]")
+	fs.StringVar(&deviceFlag, "device", deviceFlag, "Disc Device")
+	fs.StringVar(&tocFlag, "toc", tocFlag, "specify disc TOC directly (MusicBrainz format)")
+	fs.StringVar(&musicbrainzID, "musicbrainz", musicbrainzID, "specify MusicBrainz release ID directly")
+	fs.StringVar(&providedDiscID, "disc-id", providedDiscID, "specify disc ID directly")
+	fs.BoolVar(&fromDrive, "from-drive", fromDrive, "with --musicbrainz, read the disc ID from the drive instead of the release disc IDs")
+	fs.IntVar(&mediumFlag, "medium", mediumFlag, "with --musicbrainz, position of the disc in a release of several discs")
+	fs.Parse(args)
+
+	generator, err := cue.NewGenerator(cuerConfig)
+	if err != nil {
+		return err
+	}
+	result, err := generator.Lookup(context.Background(), newRequest(cuerConfig))
+	if werr := cue.NewReport(result, err).WriteJSON(os.Stdout); werr != nil {
+		return werr
+	}
+	return err
+}
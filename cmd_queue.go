@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/mpd"
+)
+
+// runQueue queues cached discs on the MPD server.
+func runQueue(cuerConfig *config.Config, args []string) error {
+	fs := newFlagSet("queue", "[--address a] [--mode add|load] [--replace=false] [--play] [--device d] disc-id...")
+	address := fs.String("address", cuerConfig.MPDAddress, "MPD server: Unix socket path or host[:port]")
+	mode := fs.String("mode", cuerConfig.MPDMode, "add the cdda:// tracks with tags, or load the CUE sheet")
+	replace := fs.Bool("replace", cuerConfig.MPDReplace, "clear the queue first, else append the disc")
+	play := fs.Bool("play", cuerConfig.MPDPlay, "start playback at the first track of the disc")
+	device := fs.String("device", "", "drive holding the discs, in the cdda:// URIs of the add mode (default: the MPD default drive)")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("missing disc ID")
+	}
+
+	store, err := cache.OpenStore(cuerConfig.CacheBackend, cuerConfig.GetCacheLocation(), cuerConfig.CacheStore)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	client, err := mpd.Dial(context.Background(), *address, cuerConfig.MPDPassword)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for i, discID := range fs.Args() {
+		// Only the first disc replaces the queue, and playback starts with it
+		opts := mpd.QueueOptions{Mode: *mode, Replace: *replace && i == 0, Play: *play && i == 0, Device: *device}
+		tracks, err := mpd.Queue(client, store, discID, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d tracks queued\n", discID, tracks)
+	}
+	return nil
+}
+
+// queueRun tracks the discs queued on the MPD server during a run generating several drives, so that
+// only the first disc queued replaces the queue and starts playback, the others being appended. It is
+// safe for concurrent use by the goroutines of the drives.
+type queueRun struct {
+	mu     sync.Mutex
+	queued bool
+}
+
+// queueGenerated queues a generated disc on the MPD server. Failures are only logged, as the disc is
+// cached anyway.
+//
+// Parameters:
+//   - device: The drive holding the disc, referenced by the queued tracks.
+//   - run: The run the disc belongs to, or nil to apply MPDReplace and MPDPlay to this disc alone.
+func queueGenerated(cuerConfig *config.Config, generator *cue.Generator, discID, device string, run *queueRun) {
+	opts := mpd.QueueOptions{Mode: cuerConfig.MPDMode, Replace: cuerConfig.MPDReplace, Play: cuerConfig.MPDPlay, Device: device}
+	if run != nil {
+		// Held until the disc is queued, so that the first disc clears the queue before the others are appended
+		run.mu.Lock()
+		defer run.mu.Unlock()
+		if run.queued {
+			opts.Replace, opts.Play = false, false
+		}
+	}
+
+	logger := cuerConfig.GetLogger()
+	client, err := mpd.Dial(context.Background(), cuerConfig.MPDAddress, cuerConfig.MPDPassword)
+	if err != nil {
+		logger.Error("MPD queue failed", "disc_id", discID, "error", err)
+		return
+	}
+	defer client.Close()
+	tracks, err := mpd.Queue(client, generator.Store(), discID, opts)
+	if err != nil {
+		logger.Error("MPD queue failed", "disc_id", discID, "error", err)
+		return
+	}
+	if run != nil {
+		run.queued = true
+	}
+	logger.Info("disc queued on MPD", "disc_id", discID, "device", device, "tracks", tracks, "address", cuerConfig.MPDAddress)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+)
+
+// runRefresh fetches again the metadata of the stale cached discs, one at a time.
+// Requests go through the generator rate limited HTTP client.
+func runRefresh(cuerConfig *config.Config, args []string) error {
+	fs := newFlagSet("refresh", "[--all] [--dry-run] [disc-id...]")
+	all := fs.Bool("all", false, "refresh every cached disc, not only the stale ones")
+	dryRun := fs.Bool("dry-run", false, "only list the discs that would be refreshed")
+	fs.Parse(args)
+
+	discIDs := fs.Args()
+	if len(discIDs) == 0 {
+		store, err := cache.OpenStore(cuerConfig.CacheBackend, cuerConfig.GetCacheLocation(), cuerConfig.CacheStore)
+		if err != nil {
+			return err
+		}
+		index, err := cache.StoreIndex(store)
+		store.Close()
+		if err != nil {
+			return err
+		}
+		var filters []cache.Filter
+		if !*all {
+			filters = append(filters, cache.Expired(cache.TTLPolicy(cuerConfig.CacheTTL), time.Now()))
+		}
+		for _, entry := range index.Query(filters...) {
+			discIDs = append(discIDs, entry.DiscID)
+		}
+	}
+
+	if *dryRun {
+		for _, discID := range discIDs {
+			fmt.Println(discID)
+		}
+		return nil
+	}
+
+	generator, err := cue.NewGenerator(cuerConfig)
+	if err != nil {
+		return err
+	}
+	defer generator.Wait()
+	ctx := context.Background()
+	var failed int
+	for _, discID := range discIDs {
+		result, err := generator.Refresh(ctx, discID)
+		if err != nil {
+			failed++
+			cuerConfig.GetLogger().Error("refresh failed", "disc_id", discID, "error", err)
+			continue
+		}
+		fmt.Printf("%s: refreshed from %s\n", discID, result.Source)
+	}
+	fmt.Printf("%d disc(s) refreshed, %d failed\n", len(discIDs)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d refresh(es) failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/rip"
+	"github.com/b0bbywan/go-disc-cuer/watch"
+)
+
+// runRip generates the playlist of the disc in the drive, then rips its tracks to FLAC files in the
+// music library, next to a CUE sheet referencing them.
+func runRip(cuerConfig *config.Config, args []string) error {
+	fs := newFlagSet("rip", "[--device <device>] [--root dir] [--template t] [--overwrite] [--ripper p] [--encoder p] [--compression 0-8]")
+	fs.StringVar(&deviceFlag, "device", deviceFlag, "Disc Device")
+	root := fs.String("root", cuerConfig.ExportRoot, "music library folder")
+	template := fs.String("template", cuerConfig.ExportTemplate, "path template of the sheet, relative to the library; the tracks are written next to it")
+	reRip := fs.Bool("overwrite", false, "rip again the tracks already in the library")
+	ripperProgram := fs.String("ripper", cuerConfig.Ripper, "cdparanoia compatible ripper (default: cdparanoia, else cd-paranoia)")
+	encoderProgram := fs.String("encoder", cuerConfig.Encoder, "flac encoder")
+	compression := fs.Int("compression", cuerConfig.FLACCompression, "FLAC compression level, from 0 (fastest) to 8 (smallest)")
+	fs.Parse(args)
+
+	if tocFlag != "" || providedDiscID != "" {
+		return fmt.Errorf("rip reads the disc in the drive and cannot be combined with --toc or --disc-id")
+	}
+	device := getDevice(deviceFlag, cuerConfig)
+	if status, err := watch.DriveStatus(device); err == nil && status != watch.StatusAudio {
+		return fmt.Errorf("no audio disc in %s: %s", device, status)
+	}
+	ripper, err := rip.NewCdparanoia(*ripperProgram)
+	if err != nil {
+		return err
+	}
+	encoder, err := rip.NewFlac(*encoderProgram, *compression)
+	if err != nil {
+		return err
+	}
+
+	generator, err := cue.NewGenerator(cuerConfig)
+	if err != nil {
+		return err
+	}
+	defer generator.Wait()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	req := newRequest(cuerConfig)
+	req.Device = device
+	// The tracks come from the drive, so a forced release must not replace the disc ID of the drive
+	req.FromDrive = req.MusicBrainzID != ""
+	generated, err := generator.Generate(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	logger := cuerConfig.GetLogger()
+	result, err := rip.Rip(ctx, generator.Store(), generated.DiscID, device, rip.Options{
+		Root:      *root,
+		Template:  *template,
+		Ripper:    ripper,
+		Encoder:   encoder,
+		Overwrite: *reRip,
+		Progress: func(track, total int, path string) {
+			logger.Info("track ripped", "disc_id", generated.DiscID, "track", track, "total", total, "path", path)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	printRip(result)
+	return nil
+}
+
+// printRip prints the paths of a rip.
+func printRip(result rip.Result) {
+	for _, path := range append([]string{result.Sheet, result.Cover}, result.Tracks...) {
+		if path != "" {
+			fmt.Printf("%s: %s\n", result.DiscID, path)
+		}
+	}
+	for _, path := range result.Skipped {
+		fmt.Printf("%s: %s exists, kept\n", result.DiscID, path)
+	}
+}
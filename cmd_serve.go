@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/server"
+	"github.com/b0bbywan/go-disc-cuer/watch"
+)
+
+// shutdownTimeout is how long the serve command waits for the running requests when interrupted.
+const shutdownTimeout = 30 * time.Second
+
+// runServe serves the HTTP API until interrupted, then lets the running requests complete.
+func runServe(cuerConfig *config.Config, args []string) error {
+	fs := newFlagSet("serve", "[--addr host:port] [--device <device>] [--watch [--all-drives]]")
+	addr := fs.String("addr", cuerConfig.ServeAddress, "address to listen on")
+	fs.StringVar(&deviceFlag, "device", deviceFlag, "Disc Device read by GET /discs/current")
+	watchDrive := fs.Bool("watch", cuerConfig.ServeWatch, "watch the drive, generating inserted discs and publishing the drive events")
+	fs.BoolVar(&allDrives, "all-drives", allDrives, "with --watch, watch every drive of the devices setting, else every drive detected")
+	fs.Parse(args)
+
+	logger := cuerConfig.GetLogger()
+	events := server.NewBroker()
+	generator, err := cue.NewGenerator(cuerConfig, cue.WithHooks(events.Hooks()))
+	if err != nil {
+		return err
+	}
+	device := getDevice(deviceFlag, cuerConfig)
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(generator, device, server.WithLogger(logger), server.WithEvents(events)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Event streams never complete on their own: end them so that Shutdown does not wait for them
+	httpServer.RegisterOnShutdown(events.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var watching sync.WaitGroup
+	if *watchDrive {
+		ready := onReady(cuerConfig, generator)
+		watchers, err := newWatchers(cuerConfig, generator, cuerConfig.WatchInterval, cuerConfig.WatchUevents,
+			cuerConfig.WatchStateFile, func() func(watch.State) {
+				publish := events.DriveChanges()
+				return func(state watch.State) {
+					publish(state)
+					ready(state)
+				}
+			})
+		if err != nil {
+			return err
+		}
+		watching.Add(1)
+		go func() {
+			defer watching.Done()
+			watch.RunAll(ctx, watchers...)
+		}()
+	}
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("serving API", "addr", *addr)
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err = <-errs:
+		stop()
+		watching.Wait()
+		generator.Wait()
+		return err
+	case <-ctx.Done():
+	}
+	logger.Info("shutting down API", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = httpServer.Shutdown(shutdownCtx)
+	watching.Wait()
+	// Let stale-while-revalidate refreshes and hook commands started by requests complete
+	generator.Wait()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/watch"
+)
+
+// runWatch generates the playlist of each disc inserted in the drives, until interrupted, printing
+// the status of each drive on every change.
+func runWatch(cuerConfig *config.Config, args []string) error {
+	fs := newFlagSet("watch", "[--device <device> | --all-drives] [--interval 2s] [--no-uevents] [--state-file <path>]")
+	fs.StringVar(&deviceFlag, "device", deviceFlag, "Disc Device")
+	fs.BoolVar(&allDrives, "all-drives", allDrives, "watch every drive of the devices setting, else every drive detected")
+	interval := fs.Duration("interval", cuerConfig.WatchInterval, "delay between two drive status polls, 0 to only use uevents")
+	noUevents := fs.Bool("no-uevents", !cuerConfig.WatchUevents, "only poll the drive status, without listening to the kernel uevents")
+	stateFile := fs.String("state-file", cuerConfig.WatchStateFile, "JSON file describing the disc in the drive, empty to disable")
+	fs.Parse(args)
+
+	generator, err := cue.NewGenerator(cuerConfig)
+	if err != nil {
+		return err
+	}
+	printer := &statusPrinter{json: outputFormat == "json"}
+	ready := onReady(cuerConfig, generator)
+	watchers, err := newWatchers(cuerConfig, generator, *interval, !*noUevents, *stateFile, func() func(watch.State) {
+		return func(state watch.State) {
+			printer.print(state)
+			ready(state)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = watch.RunAll(ctx, watchers...)
+	generator.Wait()
+	return err
+}
+
+// newWatchers creates a watcher for each drive to read, sharing generator, so its rate limited
+// clients and cache. With several drives, each writes its own state file, see watch.DriveStateFile.
+//
+// Parameters:
+//   - cuerConfig: The configuration.
+//   - generator: The generator shared by the watchers.
+//   - interval, uevents, stateFile: The watch settings.
+//   - onChange: Returns the state change callback of a new watcher.
+//
+// Returns:
+//   - []*watch.Watcher: One watcher per drive.
+//   - error: An error if the drives cannot be listed or a watcher cannot be created.
+func newWatchers(cuerConfig *config.Config, generator *cue.Generator, interval time.Duration, uevents bool,
+	stateFile string, onChange func() func(watch.State)) ([]*watch.Watcher, error) {
+	devices, err := getDevices(cuerConfig)
+	if err != nil {
+		return nil, err
+	}
+	watchers := make([]*watch.Watcher, 0, len(devices))
+	for _, device := range devices {
+		path := stateFile
+		if len(devices) > 1 {
+			path = watch.DriveStateFile(stateFile, device)
+		}
+		watcher, err := watch.NewWatcher(generator, device,
+			watch.WithInterval(interval),
+			watch.WithUevents(uevents),
+			watch.WithStateFile(path),
+			watch.WithLogger(cuerConfig.GetLogger()),
+			watch.WithOnChange(onChange()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		watchers = append(watchers, watcher)
+	}
+	return watchers, nil
+}
+
+// onReady returns a watcher callback exporting or queuing each generated disc, see afterGenerate.
+func onReady(cuerConfig *config.Config, generator *cue.Generator) func(watch.State) {
+	return func(state watch.State) {
+		if state.State == watch.StateReady && state.Disc != nil {
+			afterGenerate(cuerConfig, generator, state.Disc.DiscID, state.Device, nil)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/b0bbywan/go-disc-cuer/config"
+)
+
+// command is a subcommand of the CLI, receiving the arguments following its name.
+type command struct {
+	summary string
+	run     func(cuerConfig *config.Config, args []string) error
+}
+
+// commands lists the available subcommands by name.
+var commands = map[string]command{
+	"cache":   {summary: "inspect and clean the cache (list, show, cat, rm, prune, verify, migrate)", run: runCache},
+	"export":  {summary: "copy or link cached sheets and covers into the music library", run: runExport},
+	"lookup":  {summary: "print the metadata of a disc as JSON without writing the cache", run: runLookup},
+	"queue":   {summary: "queue cached discs on the MPD server", run: runQueue},
+	"refresh": {summary: "fetch again the metadata of expired cached discs", run: runRefresh},
+	"rip":     {summary: "rip the disc in the drive to FLAC files in the music library, with a CUE sheet", run: runRip},
+	"serve":   {summary: "serve the lookups, generations and cache over an HTTP/JSON API", run: runServe},
+	"watch":   {summary: "generate the playlist of each disc inserted in the drive", run: runWatch},
+}
+
+// runCommand runs the subcommand named by args[0].
+func runCommand(cuerConfig *config.Config, args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	return cmd.run(cuerConfig, args[1:])
+}
+
+// usage prints the global flags and the subcommands.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command [args]]\n\n", config.AppName)
+	fmt.Fprintf(out, "Without command, generates the playlist of the inserted disc.\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// newFlagSet creates the flag set of a subcommand, exiting on parse errors like the global flags.
+func newFlagSet(name, argsUsage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n", config.AppName, name, argsUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -2,9 +2,13 @@ package config
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -14,6 +18,10 @@ const (
 	AppVersion = "0.3"
 )
 
+// discardLogger is the logger of configurations created without one, so that a library does not
+// write to the output of its host application.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
 type Config struct {
 	AppName       string
 	AppVersion    string
@@ -21,6 +29,107 @@ type Config struct {
 	GnuDbUrl      string
 	CacheLocation string
 	Device        string
+	// Devices lists the drives handled together by the --all-drives mode. Every drive detected is
+	// handled if empty.
+	Devices []string
+
+	// Formats lists the playlist formats generated: "cue", "m3u8", "xspf", "pls", and the
+	// burning formats "burn-cue" and "toc".
+	Formats []string
+	// ImageFile is the disc image referenced by the burning formats, relative to the sheet.
+	ImageFile string
+
+	// CacheBackend selects the cache storage: "dir" (default) or "bolt", a single database file.
+	CacheBackend string
+	// CacheStore is the path of the bolt database file. Defaults to cache.db in CacheLocation.
+	CacheStore string
+
+	// CacheTTL holds the time to live of cached discs per metadata source. The "default" key applies
+	// to unlisted sources. A missing or zero TTL means cached discs never expire.
+	CacheTTL map[string]time.Duration
+	// StaleWhileRevalidate returns expired cached discs immediately and refreshes them in the background.
+	StaleWhileRevalidate bool
+	// RequestInterval is the minimum delay between two requests to the same web service host.
+	RequestInterval time.Duration
+
+	// CoverArtSize selects the cover art size downloaded from the Cover Art Archive:
+	// "250", "500" or "1200" pixels thumbnails, or "full" for the original image.
+	CoverArtSize string
+	// CoverArtBack also downloads the back cover.
+	CoverArtBack bool
+	// CoverArtBooklet also downloads the booklet pages.
+	CoverArtBooklet bool
+	// CoverArtMaxFileSize is the maximum size in bytes of a cover art image, unlimited if zero.
+	CoverArtMaxFileSize int64
+	// CoverArtMinDimension is the minimum width and height in pixels of a cover art image.
+	CoverArtMinDimension int
+	// CoverArtMaxDimension resizes larger cover art images to fit this width and height. Zero keeps them as is.
+	CoverArtMaxDimension int
+	// CoverArtNegativeTTL is how long a release without cover art is remembered before asking again.
+	CoverArtNegativeTTL time.Duration
+	// CoverArtSources lists the cover art sources queried in order: "release", "release-group" and "local".
+	CoverArtSources []string
+	// CoverArtFolder holds user-supplied images for the "local" cover art source.
+	CoverArtFolder string
+
+	// ExportRoot is the music library folder the export command writes to.
+	ExportRoot string
+	// ExportTemplate is the path of an exported sheet within ExportRoot, with {field} placeholders
+	// (e.g. "{albumartist}/{year} - {album}/{album}.cue").
+	ExportTemplate string
+	// ExportMode selects how exported files are created from the cache: "copy", "hardlink" or "symlink".
+	ExportMode string
+	// ExportCollision selects what happens when an exported path holds another file: "rename", "overwrite" or "skip".
+	ExportCollision string
+	// ExportOnGenerate exports each generated disc to the library.
+	ExportOnGenerate bool
+
+	// WatchInterval is the delay between two drive status polls of the watch command. Zero disables polling.
+	WatchInterval time.Duration
+	// WatchUevents listens to the kernel uevents of the drive in the watch command, to detect insertions at once.
+	WatchUevents bool
+	// WatchStateFile is the JSON file describing the disc in the drive, written by the watch command.
+	// Defaults to state.json in CacheLocation.
+	WatchStateFile string
+
+	// ServeAddress is the address the serve command listens on.
+	ServeAddress string
+	// ServeWatch watches the drive in the serve command, generating inserted discs and publishing the drive events.
+	ServeWatch bool
+
+	// MPDAddress is the MPD server the discs are queued on: a Unix socket path or host[:port].
+	MPDAddress string
+	// MPDPassword is the password of the MPD server, if required.
+	MPDPassword string
+	// MPDMode selects how a disc is queued: "add" its cdda:// tracks with tags, or "load" its CUE sheet.
+	MPDMode string
+	// MPDReplace clears the MPD queue before queuing a disc, else the disc is appended.
+	MPDReplace bool
+	// MPDPlay starts playback once a disc is queued.
+	MPDPlay bool
+	// MPDOnGenerate queues each generated disc on the MPD server.
+	MPDOnGenerate bool
+
+	// OnGenerated is a shell command run after each successful generation, including cache hits. The
+	// hook commands receive the generation in environment variables, see cue.HookEnv.
+	OnGenerated string
+	// OnNotFound is a shell command run when no metadata provider knows the disc.
+	OnNotFound string
+	// OnError is a shell command run when a generation fails for another reason.
+	OnError string
+	// HookTimeout kills the hook commands running longer. Zero means no limit.
+	HookTimeout time.Duration
+
+	// Ripper is the cdparanoia compatible program the rip command extracts tracks with. Empty searches
+	// cdparanoia then cd-paranoia.
+	Ripper string
+	// Encoder is the flac program the rip command encodes tracks with.
+	Encoder string
+	// FLACCompression is the FLAC compression level of the ripped tracks, from 0 (fastest) to 8 (smallest).
+	FLACCompression int
+
+	// Logger receives the diagnostics of the packages using this configuration.
+	Logger *slog.Logger
 }
 
 // NewDefaultConfig creates a Config struct with default application settings.
@@ -34,6 +143,7 @@ func NewDefaultConfig() (*Config, error) {
 }
 
 // NewConfig initializes the Config struct for the specified application with custom settings.
+// Diagnostics are discarded, see NewConfigWithLogger.
 //
 // Parameters:
 //   - appName: The name of the application. Defaults to the package-level AppName if empty.
@@ -44,6 +154,25 @@ func NewDefaultConfig() (*Config, error) {
 //   - *Config: A configuration instance populated with the given settings.
 //   - error: Any error encountered during initialization.
 func NewConfig(appName, appVersion, baseCacheFolder string) (*Config, error) {
+	return NewConfigWithLogger(appName, appVersion, baseCacheFolder, nil)
+}
+
+// NewConfigWithLogger initializes the Config struct like NewConfig, reporting diagnostics to logger.
+// The logger is kept in the Config and used by the packages receiving it.
+//
+// Parameters:
+//   - appName: The name of the application. Defaults to the package-level AppName if empty.
+//   - appVersion: The version of the application. Defaults to the package-level AppVersion if empty.
+//   - baseCacheFolder: The base folder for caching. Uses system defaults if empty.
+//   - logger: The logger to use. Diagnostics are discarded if nil.
+//
+// Returns:
+//   - *Config: A configuration instance populated with the given settings.
+//   - error: Any error encountered during initialization.
+func NewConfigWithLogger(appName, appVersion, baseCacheFolder string, logger *slog.Logger) (*Config, error) {
+	if logger == nil {
+		logger = discardLogger
+	}
 	if appName == "" {
 		appName = AppName
 	}
@@ -55,6 +184,46 @@ func NewConfig(appName, appVersion, baseCacheFolder string) (*Config, error) {
 	viper.SetDefault("gnuHelloEmail", "")
 	viper.SetDefault("gnuDbUrl", "https://gnudb.gnudb.org")
 	viper.SetDefault("device", "/dev/sr0")
+	viper.SetDefault("devices", []string{})
+	viper.SetDefault("formats", []string{"cue"})
+	viper.SetDefault("imageFile", "disc.wav")
+	viper.SetDefault("cacheBackend", "dir")
+	viper.SetDefault("cacheStore", "")
+	viper.SetDefault("cacheTTL", map[string]string{})
+	viper.SetDefault("staleWhileRevalidate", false)
+	viper.SetDefault("requestInterval", "1s")
+	viper.SetDefault("coverArtSize", "full")
+	viper.SetDefault("coverArtBack", false)
+	viper.SetDefault("coverArtBooklet", false)
+	viper.SetDefault("coverArtMaxFileSize", "20MB")
+	viper.SetDefault("coverArtMinDimension", 100)
+	viper.SetDefault("coverArtMaxDimension", 0)
+	viper.SetDefault("coverArtNegativeTTL", "7d")
+	viper.SetDefault("coverArtSources", []string{"release", "release-group", "local"})
+	viper.SetDefault("coverArtFolder", "")
+	viper.SetDefault("exportRoot", getDefaultExportRoot())
+	viper.SetDefault("exportTemplate", "{albumartist}/{year} - {album}/{album}.cue")
+	viper.SetDefault("exportMode", "copy")
+	viper.SetDefault("exportCollision", "rename")
+	viper.SetDefault("exportOnGenerate", false)
+	viper.SetDefault("watchInterval", "2s")
+	viper.SetDefault("watchUevents", true)
+	viper.SetDefault("watchStateFile", "")
+	viper.SetDefault("serveAddress", "localhost:8080")
+	viper.SetDefault("serveWatch", false)
+	viper.SetDefault("mpdAddress", "localhost:6600")
+	viper.SetDefault("mpdPassword", "")
+	viper.SetDefault("mpdMode", "add")
+	viper.SetDefault("mpdReplace", true)
+	viper.SetDefault("mpdPlay", false)
+	viper.SetDefault("mpdOnGenerate", false)
+	viper.SetDefault("onGenerated", "")
+	viper.SetDefault("onNotFound", "")
+	viper.SetDefault("onError", "")
+	viper.SetDefault("hookTimeout", "1m")
+	viper.SetDefault("ripper", "")
+	viper.SetDefault("encoder", "flac")
+	viper.SetDefault("flacCompression", 8)
 
 	// Load configuration paths and environment variables
 	viper.SetConfigName("config")
@@ -81,11 +250,74 @@ func NewConfig(appName, appVersion, baseCacheFolder string) (*Config, error) {
 		GnuHelloEmail: viper.GetString("gnuHelloEmail"),
 		GnuDbUrl:      viper.GetString("gnuDbUrl"),
 		Device:        viper.GetString("device"),
+		Devices:       viper.GetStringSlice("devices"),
+		Formats:       viper.GetStringSlice("formats"),
+		ImageFile:     viper.GetString("imageFile"),
+		CacheBackend:  viper.GetString("cacheBackend"),
+		CacheStore:    viper.GetString("cacheStore"),
+		Logger:        logger,
+
+		StaleWhileRevalidate: viper.GetBool("staleWhileRevalidate"),
+		CoverArtSize:         viper.GetString("coverArtSize"),
+		CoverArtBack:         viper.GetBool("coverArtBack"),
+		CoverArtBooklet:      viper.GetBool("coverArtBooklet"),
+		CoverArtMaxFileSize:  int64(viper.GetSizeInBytes("coverArtMaxFileSize")),
+		CoverArtMinDimension: viper.GetInt("coverArtMinDimension"),
+		CoverArtMaxDimension: viper.GetInt("coverArtMaxDimension"),
+		CoverArtSources:      viper.GetStringSlice("coverArtSources"),
+		CoverArtFolder:       viper.GetString("coverArtFolder"),
+		ExportRoot:           viper.GetString("exportRoot"),
+		ExportTemplate:       viper.GetString("exportTemplate"),
+		ExportMode:           viper.GetString("exportMode"),
+		ExportCollision:      viper.GetString("exportCollision"),
+		ExportOnGenerate:     viper.GetBool("exportOnGenerate"),
+		WatchUevents:         viper.GetBool("watchUevents"),
+		WatchStateFile:       viper.GetString("watchStateFile"),
+		ServeAddress:         viper.GetString("serveAddress"),
+		ServeWatch:           viper.GetBool("serveWatch"),
+		MPDAddress:           viper.GetString("mpdAddress"),
+		MPDPassword:          viper.GetString("mpdPassword"),
+		MPDMode:              viper.GetString("mpdMode"),
+		MPDReplace:           viper.GetBool("mpdReplace"),
+		MPDPlay:              viper.GetBool("mpdPlay"),
+		MPDOnGenerate:        viper.GetBool("mpdOnGenerate"),
+		OnGenerated:          viper.GetString("onGenerated"),
+		OnNotFound:           viper.GetString("onNotFound"),
+		OnError:              viper.GetString("onError"),
+		Ripper:               viper.GetString("ripper"),
+		Encoder:              viper.GetString("encoder"),
+		FLACCompression:      viper.GetInt("flacCompression"),
+	}
+
+	var err error
+	if config.CacheTTL, err = parseCacheTTL(viper.GetStringMapString("cacheTTL")); err != nil {
+		return nil, err
+	}
+	if config.RequestInterval, err = ParseDuration(viper.GetString("requestInterval")); err != nil {
+		return nil, fmt.Errorf("invalid requestInterval: %w", err)
+	}
+	if config.WatchInterval, err = ParseDuration(viper.GetString("watchInterval")); err != nil {
+		return nil, fmt.Errorf("invalid watchInterval: %w", err)
+	}
+	if config.HookTimeout, err = ParseDuration(viper.GetString("hookTimeout")); err != nil {
+		return nil, fmt.Errorf("invalid hookTimeout: %w", err)
+	}
+	if config.WatchStateFile == "" {
+		config.WatchStateFile = filepath.Join(config.CacheLocation, "state.json")
+	}
+	if config.CoverArtNegativeTTL, err = ParseDuration(viper.GetString("coverArtNegativeTTL")); err != nil {
+		return nil, fmt.Errorf("invalid coverArtNegativeTTL: %w", err)
+	}
+
+	switch config.CoverArtSize {
+	case "250", "500", "1200", "full":
+	default:
+		return nil, fmt.Errorf("invalid coverArtSize %q: expected 250, 500, 1200 or full", config.CoverArtSize)
 	}
 
 	// Validate required fields
 	if config.GnuHelloEmail == "" {
-		fmt.Fprintf(os.Stderr, "Warning: gnuHelloEmail is required for gnuDB operations.\n")
+		logger.Warn("gnuHelloEmail is required for gnuDB operations")
 	}
 
 	return config, nil
@@ -99,6 +331,59 @@ func (c *Config) GetCacheLocation() string {
 	return c.CacheLocation
 }
 
+// GetLogger retrieves the logger of the current configuration.
+//
+// Returns:
+//   - *slog.Logger: The configured logger, or a logger discarding the diagnostics if none is set.
+func (c *Config) GetLogger() *slog.Logger {
+	if c.Logger == nil {
+		return discardLogger
+	}
+	return c.Logger
+}
+
+// ParseDuration parses a duration like time.ParseDuration, also accepting a number of days with a "d" suffix (e.g. "30d").
+//
+// Parameters:
+//   - value: The duration to parse.
+//
+// Returns:
+//   - time.Duration: The parsed duration.
+//   - error: An error if the value is not a valid duration.
+func ParseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// parseCacheTTL parses the cacheTTL map of durations per source.
+func parseCacheTTL(values map[string]string) (map[string]time.Duration, error) {
+	ttl := make(map[string]time.Duration, len(values))
+	for source, value := range values {
+		duration, err := ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cacheTTL for %s: %w", source, err)
+		}
+		ttl[source] = duration
+	}
+	return ttl, nil
+}
+
+// getDefaultExportRoot returns the Music folder of the user, or "Music" in the working directory
+// if the home folder is unknown.
+func getDefaultExportRoot() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "Music"
+	}
+	return filepath.Join(home, "Music")
+}
+
 func getCacheFolder(baseCacheFolder, appName string) string {
 	if baseCacheFolder == "" {
 		return getDefaultCacheFolder(appName)
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestNewConfigDiscardsDiagnostics(t *testing.T) {
+	var output bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&output, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	// An application name without config file, so that the test does not read the user settings
+	cuerConfig, err := NewConfig("disc-cuer-test", "", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cuerConfig.GetLogger().Error("diagnostic")
+	(&Config{}).GetLogger().Error("diagnostic")
+	if output.Len() != 0 {
+		t.Errorf("diagnostics written to the default logger: %q", output.String())
+	}
+
+	var own bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&own, nil))
+	if cuerConfig, err = NewConfigWithLogger("disc-cuer-test", "", t.TempDir(), logger); err != nil {
+		t.Fatal(err)
+	}
+	cuerConfig.GetLogger().Error("diagnostic")
+	if own.Len() == 0 {
+		t.Error("diagnostics not written to the given logger")
+	}
+}
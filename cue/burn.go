@@ -0,0 +1,251 @@
+package cue
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+const (
+	// framesPerSecond is the number of CD frames per second, the unit of the TOC offsets.
+	framesPerSecond = 75
+	// pregapFrames is the standard 2 seconds pregap of the first track, implied by burning programs.
+	pregapFrames = 150
+	// cdTextMaxLength is the maximum length of a CD-TEXT string accepted by CUE sheet burners.
+	cdTextMaxLength = 80
+)
+
+// isrcPattern matches an ISRC without hyphens: country, registrant, year and designation codes.
+var isrcPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$`)
+
+// burnTrack describes a track of a disc image.
+//
+// Fields:
+//   - Number (int): The track number.
+//   - Title, Performer, Songwriter (string): The CD-TEXT of the track.
+//   - ISRC (string): The validated ISRC of the track, if known.
+//   - Pregap (int): The silence to generate before the track, in frames, beyond the standard pregap.
+//   - Start (int): The start of the track in the image, in frames.
+//   - Length (int): The length of the track in the image, in frames.
+type burnTrack struct {
+	Number     int
+	Title      string
+	Performer  string
+	Songwriter string
+	ISRC       string
+	Pregap     int
+	Start      int
+	Length     int
+}
+
+// burnTracks lays the tracks of toc out in a disc image holding the audio from the start of the first
+// track to the lead-out, as ripped with e.g. `cdparanoia 1-`. Audio before the first track is not in
+// the image and becomes a pregap.
+//
+// Parameters:
+//   - info: Metadata about the disc.
+//   - toc: The disc TOC.
+//
+// Returns:
+//   - []burnTrack: The tracks of the disc, one per TOC track.
+func burnTracks(info *types.DiscInfo, toc *utils.Toc) []burnTrack {
+	tracks := make([]burnTrack, len(toc.Offsets))
+	for i, offset := range toc.Offsets {
+		track := burnTrack{
+			Number:     toc.FirstTrack + i,
+			Performer:  info.Artist,
+			Songwriter: trackField(info.Songwriters, i),
+			ISRC:       normalizeISRC(trackField(info.ISRCs, i)),
+			Start:      offset - toc.Offsets[0],
+			Length:     toc.TrackFrames(toc.FirstTrack + i),
+		}
+		track.Title = trackField(info.Tracks, i)
+		if i == 0 && offset > pregapFrames {
+			track.Pregap = offset - pregapFrames
+		}
+		tracks[i] = track
+	}
+	return tracks
+}
+
+// trackField returns values[i], or "" if the track has no value.
+func trackField(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+// normalizeISRC removes the hyphens of an ISRC and returns it if valid, "" otherwise.
+func normalizeISRC(isrc string) string {
+	isrc = strings.ToUpper(strings.ReplaceAll(isrc, "-", ""))
+	if !isrcPattern.MatchString(isrc) {
+		return ""
+	}
+	return isrc
+}
+
+// catalogNumber returns the 13 digits Media Catalog Number of a UPC or EAN barcode, "" if invalid.
+func catalogNumber(barcode string) string {
+	for _, r := range barcode {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	switch len(barcode) {
+	case 12:
+		return "0" + barcode
+	case 13:
+		return barcode
+	default:
+		return ""
+	}
+}
+
+// msf formats a number of frames as "mm:ss:ff", the time format of CUE sheets and cdrdao TOC files.
+func msf(frames int) string {
+	return fmt.Sprintf("%02d:%02d:%02d", frames/(60*framesPerSecond), frames/framesPerSecond%60, frames%framesPerSecond)
+}
+
+// BurnCueWriter writes CUE sheets with CD-TEXT for burning a copy of the disc from a single image file.
+//
+// Fields:
+//   - ImageFile (string): The disc image, a WAVE file or raw little-endian samples otherwise.
+type BurnCueWriter struct {
+	ImageFile string
+}
+
+// FileName returns "image.cue".
+func (BurnCueWriter) FileName() string {
+	return "image.cue"
+}
+
+// Write renders the CUE sheet for info, with a track index at each TOC offset.
+//
+// Parameters:
+//   - w: The destination of the CUE sheet.
+//   - info: Metadata about the disc.
+//   - toc: The disc TOC.
+//
+// Returns:
+//   - error: ErrTocRequired if toc is nil, or any error encountered while writing.
+func (cw BurnCueWriter) Write(w io.Writer, info *types.DiscInfo, toc *utils.Toc) error {
+	if toc == nil {
+		return ErrTocRequired
+	}
+	var b strings.Builder
+	if catalog := catalogNumber(info.Barcode); catalog != "" {
+		fmt.Fprintf(&b, "CATALOG %s\n", catalog)
+	}
+	fmt.Fprintf(&b, "PERFORMER \"%s\"\nTITLE \"%s\"\n", cueText(info.Artist), cueText(info.Title))
+	fileType := "BINARY"
+	if strings.EqualFold(filepath.Ext(cw.ImageFile), ".wav") {
+		fileType = "WAVE"
+	}
+	fmt.Fprintf(&b, "FILE \"%s\" %s\n", cueText(cw.ImageFile), fileType)
+	for _, track := range burnTracks(info, toc) {
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", track.Number)
+		fmt.Fprintf(&b, "    TITLE \"%s\"\n    PERFORMER \"%s\"\n", cueText(track.Title), cueText(track.Performer))
+		if track.Songwriter != "" {
+			fmt.Fprintf(&b, "    SONGWRITER \"%s\"\n", cueText(track.Songwriter))
+		}
+		if track.ISRC != "" {
+			fmt.Fprintf(&b, "    ISRC %s\n", track.ISRC)
+		}
+		if track.Pregap > 0 {
+			fmt.Fprintf(&b, "    PREGAP %s\n", msf(track.Pregap))
+		}
+		fmt.Fprintf(&b, "    INDEX 01 %s\n", msf(track.Start))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cueText makes s a valid CD-TEXT string of a CUE sheet, which cannot escape quotes.
+func cueText(s string) string {
+	return truncateCDText(strings.ReplaceAll(singleLine(s), `"`, "'"))
+}
+
+// singleLine replaces the control characters of s, such as newlines, with spaces, so that a value
+// cannot break the line holding it.
+func singleLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// truncateCDText truncates s to the CD-TEXT length accepted by burners.
+func truncateCDText(s string) string {
+	if runes := []rune(s); len(runes) > cdTextMaxLength {
+		return string(runes[:cdTextMaxLength])
+	}
+	return s
+}
+
+// CdrdaoWriter writes cdrdao TOC files with CD-TEXT for burning a copy of the disc from a single image file.
+//
+// Fields:
+//   - ImageFile (string): The disc image, a WAVE file or raw big-endian samples otherwise, as read by cdrdao.
+type CdrdaoWriter struct {
+	ImageFile string
+}
+
+// FileName returns "image.toc".
+func (CdrdaoWriter) FileName() string {
+	return "image.toc"
+}
+
+// Write renders the cdrdao TOC file for info, with a track at each TOC offset.
+//
+// Parameters:
+//   - w: The destination of the TOC file.
+//   - info: Metadata about the disc.
+//   - toc: The disc TOC.
+//
+// Returns:
+//   - error: ErrTocRequired if toc is nil, or any error encountered while writing.
+func (cw CdrdaoWriter) Write(w io.Writer, info *types.DiscInfo, toc *utils.Toc) error {
+	if toc == nil {
+		return ErrTocRequired
+	}
+	var b strings.Builder
+	b.WriteString("CD_DA\n\n")
+	if catalog := catalogNumber(info.Barcode); catalog != "" {
+		fmt.Fprintf(&b, "CATALOG \"%s\"\n\n", catalog)
+	}
+	b.WriteString("CD_TEXT {\n  LANGUAGE_MAP {\n    0 : EN\n  }\n  LANGUAGE 0 {\n")
+	fmt.Fprintf(&b, "    TITLE %s\n    PERFORMER %s\n  }\n}\n", cdrdaoText(info.Title), cdrdaoText(info.Artist))
+	for _, track := range burnTracks(info, toc) {
+		fmt.Fprintf(&b, "\n// Track %d\nTRACK AUDIO\n", track.Number)
+		if track.ISRC != "" {
+			fmt.Fprintf(&b, "ISRC \"%s\"\n", track.ISRC)
+		}
+		b.WriteString("CD_TEXT {\n  LANGUAGE 0 {\n")
+		fmt.Fprintf(&b, "    TITLE %s\n    PERFORMER %s\n", cdrdaoText(track.Title), cdrdaoText(track.Performer))
+		if track.Songwriter != "" {
+			fmt.Fprintf(&b, "    SONGWRITER %s\n", cdrdaoText(track.Songwriter))
+		}
+		b.WriteString("  }\n}\n")
+		if track.Pregap > 0 {
+			fmt.Fprintf(&b, "PREGAP %s\n", msf(track.Pregap))
+		}
+		fmt.Fprintf(&b, "AUDIOFILE %s %s %s\n", cdrdaoText(cw.ImageFile), msf(track.Start), msf(track.Length))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cdrdaoText quotes s as a cdrdao TOC file string, on a single line.
+func cdrdaoText(s string) string {
+	s = strings.ReplaceAll(singleLine(s), `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,213 @@
+package cue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+)
+
+const (
+	coverArtURL             = "https://coverartarchive.org/release"
+	releaseGroupCoverArtURL = "https://coverartarchive.org/release-group"
+)
+
+// caaListing is the Cover Art Archive JSON index of a release.
+type caaListing struct {
+	Release string     `json:"release"`
+	Images  []caaImage `json:"images"`
+}
+
+// caaImage is an image of the Cover Art Archive JSON index.
+type caaImage struct {
+	ID         json.Number       `json:"id"`
+	Types      []string          `json:"types"`
+	Front      bool              `json:"front"`
+	Back       bool              `json:"back"`
+	Image      string            `json:"image"`
+	Thumbnails map[string]string `json:"thumbnails"`
+	Comment    string            `json:"comment"`
+}
+
+// hasType reports whether the image has the given Cover Art Archive type, e.g. "Booklet".
+func (i caaImage) hasType(imageType string) bool {
+	for _, t := range i.Types {
+		if t == imageType {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyThumbnails maps the thumbnail sizes to the names used by older Cover Art Archive indexes.
+var legacyThumbnails = map[string]string{"250": "small", "500": "large"}
+
+// url returns the URL of the image in the requested size and the size actually available.
+func (i caaImage) url(size string) (string, string) {
+	if size != "" && size != "full" {
+		if thumbnail := i.Thumbnails[size]; thumbnail != "" {
+			return thumbnail, size
+		}
+		if thumbnail := i.Thumbnails[legacyThumbnails[size]]; thumbnail != "" {
+			return thumbnail, size
+		}
+	}
+	return i.Image, "full"
+}
+
+// coverDownload is a cover art image to download and store.
+type coverDownload struct {
+	cache.CoverImage
+	URL string
+}
+
+// newCoverDownload names the stored file of image after its type, with the extension of its URL.
+func newCoverDownload(image caaImage, imageType, name, size string) coverDownload {
+	imageURL, size := image.url(size)
+	parsed, _ := url.Parse(imageURL)
+	return coverDownload{
+		CoverImage: cache.CoverImage{
+			Type:    imageType,
+			File:    name + imageExt(parsed),
+			ID:      image.ID.String(),
+			Size:    size,
+			Comment: image.Comment,
+		},
+		URL: imageURL,
+	}
+}
+
+// selectCoverImages returns the images to download: the front cover first, then the back cover
+// and the booklet pages if requested.
+func selectCoverImages(images []caaImage, opts CoverArtOptions) []coverDownload {
+	var front, back *caaImage
+	var booklet []caaImage
+	for i, image := range images {
+		switch {
+		case front == nil && (image.Front || image.hasType("Front")):
+			front = &images[i]
+		case back == nil && (image.Back || image.hasType("Back")):
+			back = &images[i]
+		case image.hasType("Booklet"):
+			booklet = append(booklet, image)
+		}
+	}
+
+	downloads := []coverDownload{}
+	if front != nil {
+		downloads = append(downloads, newCoverDownload(*front, cache.CoverFront, cache.CoverFront, opts.Size))
+	}
+	if opts.Back && back != nil {
+		downloads = append(downloads, newCoverDownload(*back, cache.CoverBack, cache.CoverBack, opts.Size))
+	}
+	if opts.Booklet {
+		for i, page := range booklet {
+			name := fmt.Sprintf("%s-%02d", cache.CoverBooklet, i+1)
+			downloads = append(downloads, newCoverDownload(page, cache.CoverBooklet, name, opts.Size))
+		}
+	}
+	return downloads
+}
+
+// releaseCoverSource fetches the cover art of the disc MusicBrainz release from the Cover Art Archive.
+type releaseCoverSource struct {
+	httpClient *http.Client
+	opts       CoverArtOptions
+	logger     *slog.Logger
+}
+
+// NewReleaseCoverSource creates a CoverSource querying the Cover Art Archive index of the disc
+// MusicBrainz release and downloading the images selected by opts.
+//
+// Parameters:
+//   - httpClient: The client used to query the Cover Art Archive.
+//   - opts: The size and types of images to download.
+//   - logger: The logger reporting the back cover and booklet pages that cannot be downloaded.
+//
+// Returns:
+//   - CoverSource: The release cover source.
+func NewReleaseCoverSource(httpClient *http.Client, opts CoverArtOptions, logger *slog.Logger) CoverSource {
+	return &releaseCoverSource{httpClient: httpClient, opts: opts, logger: logger}
+}
+
+// Name returns CoverSourceRelease.
+func (s *releaseCoverSource) Name() string {
+	return CoverSourceRelease
+}
+
+// Fetch downloads the selected images of the release. Failing to download the back cover
+// or a booklet page is only logged.
+func (s *releaseCoverSource) Fetch(ctx context.Context, query CoverQuery) ([]CoverArt, error) {
+	if query.DiscInfo.ID == "" {
+		return nil, fmt.Errorf("%w: no MusicBrainz release", ErrNoCoverArt)
+	}
+	data, _, err := fetchCoverArt(ctx, s.httpClient, fmt.Sprintf("%s/%s", coverArtURL, query.DiscInfo.ID))
+	if err != nil {
+		return nil, err
+	}
+	var listing caaListing
+	if err := json.Unmarshal(data, &listing); err != nil {
+		return nil, fmt.Errorf("Failed to parse cover art index: %w", err)
+	}
+
+	images := []CoverArt{}
+	for _, download := range selectCoverImages(listing.Images, s.opts) {
+		data, _, err := fetchCoverArt(ctx, s.httpClient, download.URL)
+		if err != nil {
+			if download.Type == cache.CoverFront {
+				return nil, err
+			}
+			s.logger.Warn("failed to fetch cover art", "disc_id", query.DiscID, "type", download.Type, "url", download.URL, "error", err)
+			continue
+		}
+		images = append(images, CoverArt{CoverImage: download.CoverImage, Data: data})
+	}
+	return images, nil
+}
+
+// releaseGroupCoverSource fetches the front cover of the disc MusicBrainz release group from the Cover Art Archive.
+type releaseGroupCoverSource struct {
+	httpClient *http.Client
+	size       string
+}
+
+// NewReleaseGroupCoverSource creates a CoverSource downloading the front cover the Cover Art Archive
+// selected for the disc release group, i.e. the cover of another release of the same album.
+//
+// Parameters:
+//   - httpClient: The client used to query the Cover Art Archive.
+//   - size: "250", "500" or "1200" to download a thumbnail, "full" or empty for the original image.
+//
+// Returns:
+//   - CoverSource: The release group cover source.
+func NewReleaseGroupCoverSource(httpClient *http.Client, size string) CoverSource {
+	return &releaseGroupCoverSource{httpClient: httpClient, size: size}
+}
+
+// Name returns CoverSourceReleaseGroup.
+func (s *releaseGroupCoverSource) Name() string {
+	return CoverSourceReleaseGroup
+}
+
+// Fetch downloads the release group front cover.
+func (s *releaseGroupCoverSource) Fetch(ctx context.Context, query CoverQuery) ([]CoverArt, error) {
+	if query.DiscInfo.ReleaseGroupID == "" {
+		return nil, fmt.Errorf("%w: no MusicBrainz release group", ErrNoCoverArt)
+	}
+	resource, size := "front", "full"
+	if s.size != "" && s.size != "full" {
+		resource, size = "front-"+s.size, s.size
+	}
+	data, finalURL, err := fetchCoverArt(ctx, s.httpClient, fmt.Sprintf("%s/%s/%s", releaseGroupCoverArtURL, query.DiscInfo.ReleaseGroupID, resource))
+	if err != nil {
+		return nil, err
+	}
+	return []CoverArt{{
+		CoverImage: cache.CoverImage{Type: cache.CoverFront, File: cache.CoverFront + imageExt(finalURL), Size: size},
+		Data:       data,
+	}}, nil
+}
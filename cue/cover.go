@@ -0,0 +1,286 @@
+package cue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// Cover art source names, as recorded in the disc metadata and selected by the coverArtSources setting.
+const (
+	CoverSourceRelease      = "release"
+	CoverSourceReleaseGroup = "release-group"
+	CoverSourceLocal        = "local"
+)
+
+// maxCoverArtDownload bounds the memory used by a cover art download, whatever the configured limits.
+const maxCoverArtDownload = 64 << 20
+
+// HTTPCacheDir is the folder of the cache location holding the cover art responses, see coverArtClient.
+const HTTPCacheDir = ".http"
+
+// coverArtRedirectTTL is how long the Cover Art Archive redirects to the image files are remembered.
+const coverArtRedirectTTL = 24 * time.Hour
+
+// ErrNoCoverArt is returned by a CoverSource that has no cover art for a disc.
+var ErrNoCoverArt = errors.New("no cover art")
+
+// CoverArtOptions selects the cover art images downloaded from the Cover Art Archive.
+//
+// Fields:
+//   - Size (string): "250", "500" or "1200" to download thumbnails, "full" or empty for the original images.
+//   - Back (bool): Also download the back cover.
+//   - Booklet (bool): Also download the booklet pages.
+//   - Limits (utils.ImageLimits): The size and dimension limits of the images, from every source.
+type CoverArtOptions struct {
+	Size    string
+	Back    bool
+	Booklet bool
+	Limits  utils.ImageLimits
+}
+
+// CoverQuery identifies the disc whose cover art is looked for.
+//
+// Fields:
+//   - DiscID (string): The disc ID used as cache key.
+//   - FreedbID (string): The FreeDB ID of the disc, if known.
+//   - DiscInfo (*types.DiscInfo): The disc metadata, with its MusicBrainz release and release group IDs if known.
+type CoverQuery struct {
+	DiscID   string
+	FreedbID string
+	DiscInfo *types.DiscInfo
+}
+
+// CoverArt is a cover art image found by a CoverSource.
+//
+// Fields:
+//   - CoverImage (cache.CoverImage): The description of the image, including the file name to store it under.
+//   - Data ([]byte): The image content.
+type CoverArt struct {
+	cache.CoverImage
+	Data []byte
+}
+
+// CoverSource finds the cover art of a disc.
+type CoverSource interface {
+	// Name identifies the source in the disc metadata.
+	Name() string
+	// Fetch returns the cover art images of the disc, the front cover first. It returns an error
+	// wrapping ErrNoCoverArt when the source has no cover art for the disc.
+	Fetch(ctx context.Context, query CoverQuery) ([]CoverArt, error)
+}
+
+// coverArtClient returns a client performing the cover art requests with the generator HTTP client,
+// through an on-disk cache of the responses in the cache folder, so that repeated runs revalidate the
+// images instead of downloading them again, and do not ask again for a release without cover art
+// before the configuration CoverArtNegativeTTL. Images larger than CoverArtMaxFileSize, which are
+// rejected anyway, are not cached.
+func (g *Generator) coverArtClient() *http.Client {
+	client := *g.httpClient
+	client.Transport = &utils.CachingTransport{
+		Base:        g.httpClient.Transport,
+		Dir:         filepath.Join(g.cacheLocation, HTTPCacheDir),
+		NegativeTTL: g.config.CoverArtNegativeTTL,
+		RedirectTTL: coverArtRedirectTTL,
+		MaxBodySize: g.config.CoverArtMaxFileSize,
+	}
+	return &client
+}
+
+// defaultCoverSources returns the cover art sources selected by the configuration, in order.
+// The Cover Art Archive sources share a client caching the responses, see coverArtClient.
+func (g *Generator) defaultCoverSources() ([]CoverSource, error) {
+	coverArtClient := g.coverArtClient()
+	sources := []CoverSource{}
+	for _, name := range g.config.CoverArtSources {
+		switch name {
+		case CoverSourceRelease:
+			sources = append(sources, NewReleaseCoverSource(coverArtClient, g.coverArt, g.logger))
+		case CoverSourceReleaseGroup:
+			sources = append(sources, NewReleaseGroupCoverSource(coverArtClient, g.coverArt.Size))
+		case CoverSourceLocal:
+			if g.config.CoverArtFolder != "" {
+				sources = append(sources, NewLocalCoverSource(g.config.CoverArtFolder))
+			}
+		default:
+			return nil, fmt.Errorf("unknown cover art source %q", name)
+		}
+	}
+	return sources, nil
+}
+
+// fetchCoverArtIfNeeded ensures that cover art is available for the given disc. If the cover art
+// is missing from the cache store, or was stored for another release, it queries the cover sources
+// in order and saves the images of the first one having a front cover in the cache store, along with
+// a cover index. Regenerating a disc thus keeps its cover art.
+//
+// Parameters:
+//   - ctx (context.Context): The context of the request.
+//   - query (CoverQuery): The disc. The cover art path of its DiscInfo is set to the front cover
+//     when the store keeps regular files.
+//
+// Returns:
+//   - string: The name of the source the cover art comes from, empty if it was already available.
+//   - error: An error if no source has cover art or the images cannot be saved; nil otherwise.
+func (g *Generator) fetchCoverArtIfNeeded(ctx context.Context, query CoverQuery) (string, error) {
+	if query.DiscInfo.CoverArtPath != "" {
+		return "", nil
+	}
+	if front := g.cachedFrontCover(query); front != "" {
+		if locator, ok := g.store.(cache.Locator); ok {
+			query.DiscInfo.CoverArtPath = locator.Path(query.DiscID, front)
+		}
+		return "", nil
+	}
+	var errs []error
+	for _, source := range g.coverSources {
+		images, err := source.Fetch(ctx, query)
+		if err == nil && (len(images) == 0 || images[0].Type != cache.CoverFront) {
+			err = fmt.Errorf("%w: no front cover", ErrNoCoverArt)
+		}
+		if err == nil {
+			images, err = g.normalizeCoverArt(query.DiscID, images)
+		}
+		if err != nil {
+			g.logger.Debug("cover source failed", "source", source.Name(), "disc_id", query.DiscID, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			continue
+		}
+		return source.Name(), g.saveCoverArt(query, source.Name(), images)
+	}
+	if len(errs) == 0 {
+		return "", fmt.Errorf("error getting cover: %w: no cover source", ErrNoCoverArt)
+	}
+	return "", fmt.Errorf("error getting cover: %w", errors.Join(errs...))
+}
+
+// cachedFrontCover returns the front cover file stored for the disc, or "" if there is none or it
+// belongs to another release than the one of the query.
+func (g *Generator) cachedFrontCover(query CoverQuery) string {
+	front := cache.FrontCover(g.store, query.DiscID)
+	if front == "" {
+		return ""
+	}
+	if index, err := cache.GetCoverIndex(g.store, query.DiscID); err == nil && index.Release != "" && index.Release != query.DiscInfo.ID {
+		return ""
+	}
+	return front
+}
+
+// normalizeCoverArt validates the images found by a source against the configured limits, resizes
+// them if needed and names their file after their actual format. Invalid back cover or booklet
+// pages are dropped.
+//
+// Parameters:
+//   - discID: The disc ID, for logging.
+//   - images: The images found by the source, front cover first.
+//
+// Returns:
+//   - []CoverArt: The valid images.
+//   - error: An error if the front cover is invalid.
+func (g *Generator) normalizeCoverArt(discID string, images []CoverArt) ([]CoverArt, error) {
+	valid := make([]CoverArt, 0, len(images))
+	for _, image := range images {
+		data, format, size, err := utils.NormalizeImage(image.Data, g.coverArt.Limits)
+		if err != nil {
+			if image.Type == cache.CoverFront {
+				return nil, fmt.Errorf("invalid front cover: %w", err)
+			}
+			g.logger.Warn("invalid cover art", "disc_id", discID, "type", image.Type, "file", image.File, "error", err)
+			continue
+		}
+		image.Data = data
+		image.File = strings.TrimSuffix(image.File, path.Ext(image.File)) + utils.ImageExt(format)
+		image.Width, image.Height = size.X, size.Y
+		valid = append(valid, image)
+	}
+	return valid, nil
+}
+
+// saveCoverArt stores the images found by a source and the cover index of the disc, then removes
+// the images of the previous index not replaced, e.g. front.png once the front cover is front.jpg.
+func (g *Generator) saveCoverArt(query CoverQuery, source string, images []CoverArt) error {
+	previous, _ := cache.GetCoverIndex(g.store, query.DiscID)
+	index := &cache.CoverIndex{Source: source, Release: query.DiscInfo.ID}
+	saved := map[string]bool{}
+	for _, image := range images {
+		saved[image.File] = true
+		if err := g.store.Put(query.DiscID, image.File, image.Data); err != nil {
+			return fmt.Errorf("error saving cover: %w", err)
+		}
+		index.Images = append(index.Images, image.CoverImage)
+		g.hooks.coverFetched(query.DiscID, cache.FileRef(g.store, query.DiscID, image.File))
+	}
+	if err := cache.PutCoverIndex(g.store, query.DiscID, index); err != nil {
+		return fmt.Errorf("error saving cover index: %w", err)
+	}
+	if previous != nil {
+		for _, image := range previous.Images {
+			if saved[image.File] {
+				continue
+			}
+			if err := g.store.DeleteFile(query.DiscID, image.File); err != nil {
+				g.logger.Warn("failed to remove stale cover art", "disc_id", query.DiscID, "file", image.File, "error", err)
+			}
+		}
+	}
+	if locator, ok := g.store.(cache.Locator); ok {
+		query.DiscInfo.CoverArtPath = locator.Path(query.DiscID, images[0].File)
+	}
+	g.logger.Info("cover art saved", "disc_id", query.DiscID, "source", source, "images", len(images))
+	return nil
+}
+
+// fetchCoverArt downloads a cover art resource.
+//
+// Parameters:
+//   - ctx (context.Context): The context of the request.
+//   - httpClient (*http.Client): The client performing the request.
+//   - resourceURL (string): The URL of the index or image.
+//
+// Returns:
+//   - []byte: The response body.
+//   - *url.URL: The final URL of the resource, after redirects.
+//   - error: An error if the HTTP request fails or the response status is not OK, wrapping
+//     ErrNoCoverArt on 404; nil otherwise.
+func fetchCoverArt(ctx context.Context, httpClient *http.Client, resourceURL string) ([]byte, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resourceURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("%w at %s", ErrNoCoverArt, resourceURL)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, nil, fmt.Errorf("failed to fetch cover art: received status code %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverArtDownload+1))
+	if err == nil && len(data) > maxCoverArtDownload {
+		err = fmt.Errorf("cover art at %s exceeds %d bytes", resourceURL, maxCoverArtDownload)
+	}
+	return data, resp.Request.URL, err
+}
+
+// imageExt returns the lowercased extension of an image URL path, ".jpg" if it has none.
+func imageExt(imageURL *url.URL) string {
+	if imageURL != nil && path.Ext(imageURL.Path) != "" {
+		return strings.ToLower(path.Ext(imageURL.Path))
+	}
+	return ".jpg"
+}
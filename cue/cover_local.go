@@ -0,0 +1,67 @@
+package cue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+)
+
+// localCoverExts lists the extensions of the images considered in the local cover folder.
+var localCoverExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true}
+
+// localCoverSource finds user-supplied cover art in a local folder.
+type localCoverSource struct {
+	folder string
+}
+
+// NewLocalCoverSource creates a CoverSource looking for user-supplied images in folder, named after the
+// disc ID, the FreeDB ID or "<artist> - <title>" (case-insensitive), with a .jpg, .jpeg, .png or .webp extension.
+//
+// Parameters:
+//   - folder: The folder holding the images.
+//
+// Returns:
+//   - CoverSource: The local cover source.
+func NewLocalCoverSource(folder string) CoverSource {
+	return &localCoverSource{folder: folder}
+}
+
+// Name returns CoverSourceLocal.
+func (s *localCoverSource) Name() string {
+	return CoverSourceLocal
+}
+
+// Fetch reads the first image of the folder matching the disc.
+func (s *localCoverSource) Fetch(ctx context.Context, query CoverQuery) ([]CoverArt, error) {
+	names := map[string]bool{strings.ToLower(query.DiscID): true}
+	if query.FreedbID != "" {
+		names[strings.ToLower(query.FreedbID)] = true
+	}
+	if query.DiscInfo.Artist != "" && query.DiscInfo.Title != "" {
+		names[strings.ToLower(query.DiscInfo.Artist+" - "+query.DiscInfo.Title)] = true
+	}
+
+	dirEntries, err := os.ReadDir(s.folder)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list cover folder %s: %w", s.folder, err)
+	}
+	for _, dirEntry := range dirEntries {
+		ext := strings.ToLower(filepath.Ext(dirEntry.Name()))
+		if dirEntry.IsDir() || !localCoverExts[ext] || !names[strings.ToLower(strings.TrimSuffix(dirEntry.Name(), filepath.Ext(dirEntry.Name())))] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.folder, dirEntry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		return []CoverArt{{
+			CoverImage: cache.CoverImage{Type: cache.CoverFront, File: cache.CoverFront + ext, Size: "full"},
+			Data:       data,
+		}}, nil
+	}
+	return nil, fmt.Errorf("%w in %s", ErrNoCoverArt, s.folder)
+}
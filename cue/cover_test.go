@@ -0,0 +1,79 @@
+package cue
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+// fakeCoverSource returns a front cover named front.png holding image data, counting the fetches.
+type fakeCoverSource struct {
+	data    []byte
+	fetches int
+}
+
+func (f *fakeCoverSource) Name() string {
+	return "fake"
+}
+
+func (f *fakeCoverSource) Fetch(ctx context.Context, query CoverQuery) ([]CoverArt, error) {
+	f.fetches++
+	return []CoverArt{{CoverImage: cache.CoverImage{Type: cache.CoverFront, File: "front.png"}, Data: f.data}}, nil
+}
+
+// encodeImage returns a 10x10 image encoded by encode.
+func encodeImage(t *testing.T, encode func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetchCoverArtIfNeeded(t *testing.T) {
+	store := cache.NewDirStore(t.TempDir())
+	source := &fakeCoverSource{data: encodeImage(t, png.Encode)}
+	g := &Generator{
+		store:        store,
+		coverSources: []CoverSource{source},
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	fetch := func(release string) (string, *types.DiscInfo) {
+		t.Helper()
+		info := &types.DiscInfo{ID: release}
+		name, err := g.fetchCoverArtIfNeeded(context.Background(), CoverQuery{DiscID: "disc", DiscInfo: info})
+		if err != nil {
+			t.Fatalf("fetchCoverArtIfNeeded() error = %v", err)
+		}
+		return name, info
+	}
+
+	if name, info := fetch("rel"); name != "fake" || info.CoverArtPath != store.Path("disc", "front.png") {
+		t.Fatalf("first fetch = %q, %q, want the source and front.png", name, info.CoverArtPath)
+	}
+	// A regeneration keeps the stored cover art
+	if name, info := fetch("rel"); name != "" || info.CoverArtPath != store.Path("disc", "front.png") || source.fetches != 1 {
+		t.Errorf("second fetch = %q, %q with %d fetches, want the stored cover without fetching", name, info.CoverArtPath, source.fetches)
+	}
+
+	// Another release fetches again; the JPEG data is saved as front.jpg, replacing front.png
+	source.data = encodeImage(t, func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) })
+	if name, info := fetch("other"); name != "fake" || info.CoverArtPath != store.Path("disc", "front.jpg") || source.fetches != 2 {
+		t.Errorf("fetch of another release = %q, %q with %d fetches, want front.jpg fetched again", name, info.CoverArtPath, source.fetches)
+	}
+	if store.Has("disc", "front.png") {
+		t.Error("stale front.png left in the store")
+	}
+	if front := cache.FrontCover(store, "disc"); front != "front.jpg" {
+		t.Errorf("FrontCover() = %q, want front.jpg", front)
+	}
+}
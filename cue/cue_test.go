@@ -0,0 +1,48 @@
+package cue
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+)
+
+func TestGenerateRequiresPlaylistPath(t *testing.T) {
+	cuerConfig := &config.Config{
+		CacheLocation: t.TempDir(),
+		CacheBackend:  cache.BackendBolt,
+		Formats:       []string{"cue"},
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	path, err := generate(cuerConfig, Request{Toc: testToc})
+	if !errors.Is(err, ErrNoPlaylistPath) {
+		t.Errorf("generate() with the bolt backend = %q, %v, want ErrNoPlaylistPath", path, err)
+	}
+}
+
+func TestGenerateWaitsForHookCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook command uses sh")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "event")
+	cuerConfig := &config.Config{
+		CacheLocation: dir,
+		Formats:       []string{"cue"},
+		OnError:       "sleep 0.2 && echo $DISC_CUER_EVENT > " + out,
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if _, err := generate(cuerConfig, Request{Toc: "invalid"}); err == nil {
+		t.Fatal("generate() with an invalid TOC: error = nil")
+	}
+	if data, err := os.ReadFile(out); err != nil || strings.TrimSpace(string(data)) != "error" {
+		t.Errorf("hook command output when generate() returns = %q, %v, want \"error\"", data, err)
+	}
+}
@@ -1,15 +1,12 @@
 package cue
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 
-	"github.com/b0bbywan/go-disc-cuer/config"
 	"github.com/b0bbywan/go-disc-cuer/gnudb"
 	"github.com/b0bbywan/go-disc-cuer/musicbrainz"
 	"github.com/b0bbywan/go-disc-cuer/types"
@@ -17,130 +14,158 @@ import (
 )
 
 const (
-	coverArtURL = "https://coverartarchive.org/release"
+	// SourceGnudb is the name of the GNUDB provider.
+	SourceGnudb = "gnudb"
+	// SourceMusicBrainz is the name of the MusicBrainz provider.
+	SourceMusicBrainz = "musicbrainz"
 )
 
-// fetchCoverArtIfNeeded ensures that cover art is available for the given disc.
-// If the cover art is missing, it attempts to fetch it from the Cover Art Archive
-// based on the MusicBrainz ID of the disc and saves it in the appropriate cache folder.
-//
-// Parameters:
-//   - discInfo (*types.DiscInfo): Metadata for the disc, including its MusicBrainz ID and cover art path.
-//   - cueFilePath (string): The path to the CUE file, used to determine the cache directory.
-//
-// Returns:
-//   - error: An error if the cover art cannot be fetched or saved; nil otherwise.
-func fetchCoverArtIfNeeded(discInfo *types.DiscInfo, cacheLocation, cueFilePath string) error {
-	if discInfo.CoverArtPath == "" {
-		coverFilePath := utils.CacheCoverArtPath(cacheLocation, filepath.Base(filepath.Dir(cueFilePath)))
-		if err := fetchCoverArt(discInfo.ID, coverFilePath); err == nil {
-			discInfo.CoverArtPath = coverFilePath
-		} else {
-			return fmt.Errorf("error getting cover: %w", err)
-		}
-	}
-	return nil
+// ErrNotFound is returned by Generate and Lookup when every provider answered that it does not know
+// the disc, as opposed to failing to answer. Providers return it, or wrap it, when the disc is unknown.
+var ErrNotFound = errors.New("disc not found")
+
+// Provider looks up disc metadata from its TOC.
+type Provider interface {
+	// Name identifies the provider in results and logs.
+	Name() string
+	// Lookup returns the metadata of the disc described by toc, or an error wrapping ErrNotFound
+	// if the disc is unknown.
+	Lookup(ctx context.Context, toc *utils.Toc) (*types.DiscInfo, error)
 }
 
-// fetchCoverArt downloads cover art from the Cover Art Archive using a MusicBrainz ID.
-//
-// Parameters:
-//   - mbID (string): The MusicBrainz release ID for the disc.
-//   - coverFile (string): The file path where the cover art will be saved.
+// Candidate is the outcome of a provider lookup.
 //
-// Returns:
-//   - error: An error if the HTTP request fails, the response status is not OK,
-//     or the file cannot be saved; nil otherwise.
-func fetchCoverArt(mbID, coverFile string) error {
-	url := fmt.Sprintf("%s/%s/front", coverArtURL, mbID)
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("failed to fetch cover art: received status code %d", resp.StatusCode)
-	}
+// Fields:
+//   - Source (string): The provider name.
+//   - DiscInfo (*types.DiscInfo): The metadata found, nil on error.
+//   - Err (error): The lookup error, if any.
+type Candidate struct {
+	Source   string
+	DiscInfo *types.DiscInfo
+	Err      error
+}
 
-	file, err := os.Create(coverFile)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+type gnudbProvider struct {
+	client *gnudb.Client
+}
+
+// NewGnudbProvider creates a Provider querying GNUDB.
+func NewGnudbProvider(client *gnudb.Client) Provider {
+	return gnudbProvider{client: client}
+}
+
+func (p gnudbProvider) Name() string {
+	return SourceGnudb
+}
 
-	_, err = io.Copy(file, resp.Body)
-	return err
+func (p gnudbProvider) Lookup(ctx context.Context, toc *utils.Toc) (*types.DiscInfo, error) {
+	return p.client.FetchDiscInfo(ctx, strings.ReplaceAll(toc.GnuString(), " ", "+"))
 }
 
-// fetchDiscInfoConcurrently fetches metadata about a disc from both GNUDB and MusicBrainz concurrently.
-// This function uses goroutines and a WaitGroup to perform the operations in parallel.
+type musicBrainzProvider struct {
+	client *musicbrainz.Client
+}
+
+// NewMusicBrainzProvider creates a Provider querying MusicBrainz.
+func NewMusicBrainzProvider(client *musicbrainz.Client) Provider {
+	return musicBrainzProvider{client: client}
+}
+
+func (p musicBrainzProvider) Name() string {
+	return SourceMusicBrainz
+}
+
+func (p musicBrainzProvider) Lookup(ctx context.Context, toc *utils.Toc) (*types.DiscInfo, error) {
+	return p.client.FetchReleaseByToc(ctx, strings.ReplaceAll(toc.MusicBrainzString(), " ", "+"))
+}
+
+// lookup queries every provider concurrently.
 //
 // Parameters:
-//   - gnuToc (string): The disc's TOC formatted for GNUDB queries.
-//   - mbToc (string): The disc's TOC formatted for MusicBrainz queries.
+//   - ctx: The context of the lookups.
+//   - discID: The disc ID, passed to the hooks.
+//   - toc: The disc TOC.
 //
 // Returns:
-//   - *types.DiscInfo: Consolidated metadata about the disc, prioritizing GNUDB data when available.
-//   - error: An error if both sources fail to provide valid data; nil otherwise.
-//
-// Function to fetch disc info from both services using goroutines and WaitGroup
-func fetchDiscInfoConcurrently(cuerConfig *config.Config, gnuToc, mbToc string) (*types.DiscInfo, error) {
+//   - []Candidate: The outcome of each provider, in provider order.
+func (g *Generator) lookup(ctx context.Context, discID string, toc *utils.Toc) []Candidate {
 	var wg sync.WaitGroup
-	var gndbDiscInfo, mbDiscInfo *types.DiscInfo
-	var gndbErr, mbErr error
-	formattedGnuTOC := strings.ReplaceAll(gnuToc, " ", "+")
-	formattedMBTOC := strings.ReplaceAll(mbToc, " ", "+")
-
-	wg.Add(2)
-
-	// Fetch from GNUDB
-	go func() {
-		defer wg.Done()
-		gndbDiscInfo, gndbErr = gnudb.FetchDiscInfo(cuerConfig, formattedGnuTOC)
-	}()
-
-	// Fetch from MusicBrainz
-	go func() {
-		defer wg.Done()
-		mbDiscInfo, mbErr = musicbrainz.FetchReleaseByToc(formattedMBTOC)
-	}()
-
-	// Wait for both fetches to complete
+	candidates := make([]Candidate, len(g.providers))
+
+	for i, provider := range g.providers {
+		wg.Add(1)
+		go func(i int, provider Provider) {
+			defer wg.Done()
+			g.hooks.providerStart(discID, provider.Name())
+			g.logger.Debug("querying provider", "provider", provider.Name())
+			discInfo, err := provider.Lookup(ctx, toc)
+			if err == nil && discInfo == nil {
+				err = fmt.Errorf("%w: no disc info", ErrNotFound)
+			}
+			if err != nil {
+				g.logger.Info("provider lookup failed", "provider", provider.Name(), "error", err)
+			} else {
+				g.logger.Debug("provider lookup succeeded", "provider", provider.Name(), "artist", discInfo.Artist, "title", discInfo.Title)
+			}
+			candidates[i] = Candidate{Source: provider.Name(), DiscInfo: discInfo, Err: err}
+			g.hooks.providerDone(discID, candidates[i])
+		}(i, provider)
+	}
+
 	wg.Wait()
+	return candidates
+}
 
-	return selectDiscInfo(gndbDiscInfo, gndbErr, mbDiscInfo, mbErr)
+// isNotFound reports whether a provider error means the disc is unknown to the provider.
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, musicbrainz.ErrNotFound) || errors.Is(err, gnudb.ErrNotFound)
 }
 
-// selectDiscInfo determines the final disc metadata to use based on the results from GNUDB and MusicBrainz.
+// selectCandidate determines the final disc metadata to use from the provider results.
 //
 // Parameters:
-//   - gndbDiscInfo (*types.DiscInfo): Metadata fetched from GNUDB (if available).
-//   - gndbErr (error): Any error encountered during the GNUDB fetch.
-//   - mbDiscInfo (*types.DiscInfo): Metadata fetched from MusicBrainz (if available).
-//   - mbErr (error): Any error encountered during the MusicBrainz fetch.
+//   - candidates ([]Candidate): The provider results, in priority order.
 //
 // Returns:
-//   - *types.DiscInfo: The chosen disc metadata, prioritizing GNUDB data when both sources are successful.
-//   - error: An error if both sources fail, containing details about both failures.
-func selectDiscInfo(gndbDiscInfo *types.DiscInfo, gndbErr error, mbDiscInfo *types.DiscInfo, mbErr error) (*types.DiscInfo, error) {
-
-	// Decide on the final discInfo, prioritizing GNUDB data where available
-	finalDiscInfo := &types.DiscInfo{}
-	if gndbErr == nil {
-		*finalDiscInfo = *gndbDiscInfo
-	} else if mbErr == nil {
-		*finalDiscInfo = *mbDiscInfo
+//   - *types.DiscInfo: A copy of the first successful candidate's metadata. If it has no ID, the ID of the
+//     first successful candidate having one (i.e. the MusicBrainz release ID) is used.
+//   - string: The source of the chosen metadata.
+//   - error: An error if every provider failed, containing details about each failure, and wrapping
+//     ErrNotFound if none of them knows the disc.
+func selectCandidate(candidates []Candidate) (*types.DiscInfo, string, error) {
+	var chosen *Candidate
+	notFound := true
+	for i := range candidates {
+		if candidates[i].Err != nil {
+			notFound = notFound && isNotFound(candidates[i].Err)
+		} else if chosen == nil {
+			chosen = &candidates[i]
+		}
 	}
-
-	// Use MusicBrainz ID regardless of source priority
-	if mbDiscInfo != nil {
-		finalDiscInfo.ID = mbDiscInfo.ID
+	if chosen == nil {
+		var errs []error
+		for _, candidate := range candidates {
+			if notFound || !isNotFound(candidate.Err) {
+				errs = append(errs, fmt.Errorf("%s error: %w", candidate.Source, candidate.Err))
+			} else {
+				// Not wrapped, so that the error only matches ErrNotFound when every source missed the disc
+				errs = append(errs, fmt.Errorf("%s error: %v", candidate.Source, candidate.Err))
+			}
+		}
+		if notFound && len(errs) > 0 {
+			return nil, "", fmt.Errorf("%w in every source: %w", ErrNotFound, errors.Join(errs...))
+		}
+		return nil, "", fmt.Errorf("failed to fetch from every source: %w", errors.Join(errs...))
 	}
 
-	// If both failed, return an error
-	if gndbErr != nil && mbErr != nil {
-		return nil, fmt.Errorf("failed to fetch from both sources: GNUDB error: %w; MusicBrainz error: %w", gndbErr, mbErr)
+	finalDiscInfo := *chosen.DiscInfo
+	if finalDiscInfo.ID == "" {
+		for _, candidate := range candidates {
+			if candidate.Err == nil && candidate.DiscInfo.ID != "" {
+				finalDiscInfo.ID = candidate.DiscInfo.ID
+				break
+			}
+		}
 	}
-
-	return finalDiscInfo, nil
+	return &finalDiscInfo, chosen.Source, nil
 }
@@ -0,0 +1,829 @@
+package cue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uploadedlobster.com/discid"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/gnudb"
+	"github.com/b0bbywan/go-disc-cuer/musicbrainz"
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// SourceRelease is the Result source when the metadata comes from a forced MusicBrainz release.
+const SourceRelease = "musicbrainz-release"
+
+// SourceManual is the Result source when the metadata was edited with UpdateDiscInfo.
+const SourceManual = cache.SourceManual
+
+// Generator generates playlists for audio discs. It is safe for concurrent use
+// as long as its hooks are.
+type Generator struct {
+	config        *config.Config
+	cacheLocation string
+	store         cache.Store
+	httpClient    *http.Client
+	musicbrainz   *musicbrainz.Client
+	providers     []Provider
+	writers       []Writer
+	coverArt      CoverArtOptions
+	coverSources  []CoverSource
+	logger        *slog.Logger
+	hooks         Hooks
+	hookCommands  HookCommands
+	hookRuns      sync.WaitGroup
+
+	ttl                  cache.TTLPolicy
+	staleWhileRevalidate bool
+	refreshes            sync.WaitGroup
+	refreshMu            sync.Mutex
+	refreshing           map[string]bool
+
+	// now returns the current time, recorded as the fetch time of the metadata and compared to their TTL
+	now func() time.Time
+}
+
+// Option configures a Generator.
+type Option func(*Generator)
+
+// WithProviders replaces the default GNUDB and MusicBrainz providers. Providers are
+// queried concurrently and their results are preferred in the given order.
+func WithProviders(providers ...Provider) Option {
+	return func(g *Generator) {
+		g.providers = providers
+	}
+}
+
+// WithCacheLocation overrides the cache folder of the configuration.
+func WithCacheLocation(cacheLocation string) Option {
+	return func(g *Generator) {
+		g.cacheLocation = cacheLocation
+	}
+}
+
+// WithStore sets the storage of the cached discs. Defaults to the store of the configuration
+// CacheBackend, in the cache folder.
+func WithStore(store cache.Store) Option {
+	return func(g *Generator) {
+		g.store = store
+	}
+}
+
+// WithWriters replaces the writers of the configuration Formats. A disc is cached once its metadata
+// sidecar, or the first writer's file, exists; the files of the other writers are then rendered
+// from the sidecar if missing.
+func WithWriters(writers ...Writer) Option {
+	return func(g *Generator) {
+		g.writers = writers
+	}
+}
+
+// WithCoverArt selects the cover art images downloaded. Defaults to the configuration
+// CoverArtSize, CoverArtBack and CoverArtBooklet.
+func WithCoverArt(opts CoverArtOptions) Option {
+	return func(g *Generator) {
+		g.coverArt = opts
+	}
+}
+
+// WithCoverSources replaces the cover art sources of the configuration CoverArtSources. Sources
+// are queried in the given order until one has a front cover.
+func WithCoverSources(sources ...CoverSource) Option {
+	return func(g *Generator) {
+		g.coverSources = sources
+	}
+}
+
+// WithLogger sets the logger used to report progress. Defaults to the configuration logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(g *Generator) {
+		g.logger = logger
+	}
+}
+
+// WithHTTPClient sets the HTTP client used by the default providers and to fetch cover art.
+// Defaults to a client limited to one request per host every configuration RequestInterval.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(g *Generator) {
+		g.httpClient = httpClient
+	}
+}
+
+// WithCacheTTL sets the time to live of cached discs per metadata source.
+// Defaults to the configuration CacheTTL.
+func WithCacheTTL(policy cache.TTLPolicy) Option {
+	return func(g *Generator) {
+		g.ttl = policy
+	}
+}
+
+// WithStaleWhileRevalidate makes Generate return expired cached discs immediately and refresh
+// them in the background. Defaults to the configuration StaleWhileRevalidate.
+func WithStaleWhileRevalidate(enabled bool) Option {
+	return func(g *Generator) {
+		g.staleWhileRevalidate = enabled
+	}
+}
+
+// WithHooks sets the callbacks invoked at each stage of the generation.
+func WithHooks(hooks Hooks) Option {
+	return func(g *Generator) {
+		g.hooks = hooks
+	}
+}
+
+// NewGenerator creates a Generator from the application configuration.
+//
+// Parameters:
+//   - cuerConfig: The Config instance to use for generating playlists.
+//   - opts: Functional options overriding the defaults.
+//
+// Returns:
+//   - *Generator: The configured generator.
+//   - error: An error if the configuration is missing or invalid, or the cache store cannot be opened.
+func NewGenerator(cuerConfig *config.Config, opts ...Option) (*Generator, error) {
+	if cuerConfig == nil {
+		return nil, fmt.Errorf("Failed to create generator: empty config")
+	}
+	g := &Generator{
+		config:        cuerConfig,
+		cacheLocation: cuerConfig.GetCacheLocation(),
+		httpClient:    utils.NewRateLimitedClient(cuerConfig.RequestInterval),
+		logger:        cuerConfig.GetLogger(),
+		coverArt: CoverArtOptions{
+			Size:    cuerConfig.CoverArtSize,
+			Back:    cuerConfig.CoverArtBack,
+			Booklet: cuerConfig.CoverArtBooklet,
+			Limits: utils.ImageLimits{
+				MaxBytes:     cuerConfig.CoverArtMaxFileSize,
+				MinDimension: cuerConfig.CoverArtMinDimension,
+				MaxDimension: cuerConfig.CoverArtMaxDimension,
+			},
+		},
+
+		ttl:                  cache.TTLPolicy(cuerConfig.CacheTTL),
+		staleWhileRevalidate: cuerConfig.StaleWhileRevalidate,
+		now:                  time.Now,
+		refreshing:           map[string]bool{},
+		hookCommands: HookCommands{
+			OnGenerated: cuerConfig.OnGenerated,
+			OnNotFound:  cuerConfig.OnNotFound,
+			OnError:     cuerConfig.OnError,
+			Timeout:     cuerConfig.HookTimeout,
+		},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.store == nil {
+		store, err := cache.OpenStore(cuerConfig.CacheBackend, g.cacheLocation, cuerConfig.CacheStore)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create generator: %w", err)
+		}
+		g.store = store
+	}
+	g.musicbrainz = musicbrainz.NewClient(g.httpClient, g.logger)
+	if g.providers == nil {
+		g.providers = g.defaultProviders()
+	}
+	if len(g.writers) == 0 {
+		writers, err := NewWriters(cuerConfig.Formats, cuerConfig.ImageFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create generator: %w", err)
+		}
+		g.writers = writers
+	}
+	if len(g.writers) == 0 {
+		g.writers = []Writer{CueWriter{}}
+	}
+	if g.coverSources == nil {
+		sources, err := g.defaultCoverSources()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create generator: %w", err)
+		}
+		g.coverSources = sources
+	}
+	return g, nil
+}
+
+// defaultProviders returns the GNUDB provider, when configured, followed by the MusicBrainz provider.
+func (g *Generator) defaultProviders() []Provider {
+	providers := []Provider{}
+	if client, err := gnudb.NewClient(g.config, g.httpClient, g.logger); err == nil {
+		providers = append(providers, NewGnudbProvider(client))
+	} else {
+		g.logger.Debug("GNUDB provider disabled", "error", err)
+	}
+	return append(providers, NewMusicBrainzProvider(g.musicbrainz))
+}
+
+// Result describes the outcome of a generation.
+//
+// Fields:
+//   - Mode (Mode): The generation mode selected by the request.
+//   - DiscID (string): The disc ID used as cache key, the MusicBrainz disc ID when the TOC is known.
+//   - FreedbID (string): The FreeDB ID of the disc, aliased to the cache key, if known.
+//   - Toc (*utils.Toc): The disc TOC, nil when only a disc ID was provided.
+//   - DiscInfo (*types.DiscInfo): The metadata written, or read from the cache sidecar on a cache hit.
+//   - Source (string): The provider name the metadata comes from, or SourceRelease.
+//   - Candidates ([]Candidate): The results of every queried provider.
+//   - Files ([]string): The paths of the playlist files, in writer order, or their cache.FileRef
+//     when the store does not keep regular files.
+//   - CoverPath (string): The path or cache.FileRef of the cover art, if any.
+//   - CoverSource (string): The cover source the cover art comes from, if any.
+//   - CacheHit (bool): True if the playlist was already cached and nothing was written.
+//   - Stale (bool): True if the cached playlist returned has expired.
+//   - Refreshing (bool): True if a background refresh of the stale playlist is running.
+type Result struct {
+	Mode        Mode
+	DiscID      string
+	FreedbID    string
+	Toc         *utils.Toc
+	DiscInfo    *types.DiscInfo
+	Source      string
+	Candidates  []Candidate
+	Files       []string
+	CoverPath   string
+	CoverSource string
+	CacheHit    bool
+	Stale       bool
+	Refreshing  bool
+}
+
+// PlaylistPath returns the path of the file written by the first writer.
+func (r Result) PlaylistPath() string {
+	if len(r.Files) == 0 {
+		return ""
+	}
+	return r.Files[0]
+}
+
+// Generate generates the playlists described by req.
+//
+// Parameters:
+//   - ctx: The context of the network requests.
+//   - req: The generation request.
+//
+// Returns:
+//   - Result: The outcome of the generation, partially filled on error.
+//   - error: Any error encountered during the process, including an invalid request.
+//
+// Workflow:
+//  1. Validate the request and select the generation mode.
+//  2. If a MusicBrainz release is forced, fetch its disc info and disc IDs.
+//  3. Determine the disc ID and TOC, from the overrides, the release or by reading the drive.
+//  4. Lock the disc, then check if the playlist is cached. If so, return it unless `Overwrite` is set or it has expired.
+//     Expired playlists are returned anyway and refreshed in the background in stale-while-revalidate mode.
+//  5. Otherwise, query the providers concurrently unless the release was forced.
+//  6. Fetch the cover art and write every playlist.
+//  7. Call the outcome hook, then start the hook command of the outcome in the background, see Wait.
+func (g *Generator) Generate(ctx context.Context, req Request) (Result, error) {
+	result, err := g.generate(ctx, req)
+	g.hooks.generated(result, err)
+	g.startHookCommand(req, result, err)
+	return result, err
+}
+
+// generate performs the steps 1 to 6 of Generate.
+func (g *Generator) generate(ctx context.Context, req Request) (Result, error) {
+	result, discInfo, err := g.identify(ctx, req)
+	if err != nil {
+		return result, err
+	}
+
+	// Serialize generations of the same disc, including from other processes
+	lock, err := utils.LockDisc(ctx, g.cacheLocation, result.DiscID)
+	if err != nil {
+		return result, err
+	}
+	defer lock.Unlock()
+
+	if result.FreedbID != "" && result.FreedbID != result.DiscID && cache.IsLegacyFolder(g.cacheLocation, result.FreedbID) {
+		g.logger.Warn("legacy cache folder keyed by FreeDB ID found, run the cache migrate command", "freedb_id", result.FreedbID, "disc_id", result.DiscID)
+	}
+
+	metadata, _ := cache.GetMetadata(g.store, result.DiscID)
+	if (metadata != nil || g.store.Has(result.DiscID, g.writers[0].FileName())) && !req.Overwrite {
+		stale := metadata != nil && g.ttl.Expired(metadata.Source, metadata.FetchedAt, g.now())
+		if !stale || g.staleWhileRevalidate {
+			result.CacheHit = true
+			result.Stale = stale
+			if metadata != nil {
+				result.DiscInfo = &metadata.DiscInfo
+				result.Source = metadata.Source
+				result.CoverSource = metadata.CoverSource
+				if result.Toc == nil && metadata.Toc != "" {
+					result.Toc, _ = utils.ParseMusicBrainzToc(metadata.Toc)
+				}
+			}
+			for _, writer := range g.writers {
+				if metadata != nil && !g.store.Has(result.DiscID, writer.FileName()) {
+					// Format added since the disc was cached, rendered from the sidecar
+					if err := g.writePlaylist(&result, writer); err != nil {
+						return result, err
+					}
+					continue
+				}
+				result.Files = append(result.Files, cache.FileRef(g.store, result.DiscID, writer.FileName()))
+			}
+			if front := cache.FrontCover(g.store, result.DiscID); front != "" {
+				result.CoverPath = cache.FileRef(g.store, result.DiscID, front)
+			}
+			g.logger.Info("playlist already exists", "path", result.PlaylistPath(), "stale", stale)
+			if stale {
+				result.Refreshing = g.refreshInBackground(ctx, metadata)
+			}
+			return result, nil
+		}
+		g.logger.Info("cached playlist expired, refreshing", "disc_id", result.DiscID, "fetched_at", metadata.FetchedAt)
+	}
+
+	if discInfo, err = g.selectMetadata(ctx, &result, discInfo); err != nil {
+		return result, err
+	}
+
+	query := CoverQuery{DiscID: result.DiscID, FreedbID: result.FreedbID, DiscInfo: discInfo}
+	if result.CoverSource, err = g.fetchCoverArtIfNeeded(ctx, query); err != nil {
+		g.logger.Warn("failed to fetch cover art", "disc_id", result.DiscID, "error", err)
+	}
+	if front := cache.FrontCover(g.store, result.DiscID); front != "" {
+		result.CoverPath = cache.FileRef(g.store, result.DiscID, front)
+		if index, err := cache.GetCoverIndex(g.store, result.DiscID); result.CoverSource == "" && err == nil {
+			// Cover art kept from a previous generation
+			result.CoverSource = index.Source
+		}
+	}
+
+	if err := g.writePlaylists(&result); err != nil {
+		return result, err
+	}
+
+	metadata = &cache.Metadata{
+		DiscID:        result.DiscID,
+		FreedbID:      result.FreedbID,
+		Source:        result.Source,
+		MusicBrainzID: discInfo.ID,
+		CoverSource:   result.CoverSource,
+		FetchedAt:     g.now(),
+		DiscInfo:      *discInfo,
+	}
+	if result.Toc != nil {
+		metadata.Toc = result.Toc.MusicBrainzString()
+	}
+	if err := cache.PutMetadata(g.store, metadata); err != nil {
+		g.logger.Warn("failed to save disc metadata", "disc_id", result.DiscID, "error", err)
+	}
+	if err := g.store.Alias(result.FreedbID, result.DiscID, metadata.Toc); err != nil {
+		g.logger.Warn("failed to alias FreeDB ID", "freedb_id", result.FreedbID, "disc_id", result.DiscID, "error", err)
+	}
+	return result, nil
+}
+
+// Lookup identifies the disc described by req and fetches its metadata like Generate, but neither
+// reads nor writes the cache: no playlist, cover art or sidecar is written and the providers are
+// always queried.
+//
+// Parameters:
+//   - ctx: The context of the network requests.
+//   - req: The generation request. Overwrite is ignored.
+//
+// Returns:
+//   - Result: The outcome of the lookup, partially filled on error. Files lists the paths the playlists
+//     would be written to, CoverPath and CoverSource are empty.
+//   - error: Any error encountered during the process, including an invalid request.
+func (g *Generator) Lookup(ctx context.Context, req Request) (Result, error) {
+	result, discInfo, err := g.identify(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	if _, err = g.selectMetadata(ctx, &result, discInfo); err != nil {
+		return result, err
+	}
+	for _, writer := range g.writers {
+		result.Files = append(result.Files, cache.FileRef(g.store, result.DiscID, writer.FileName()))
+	}
+	return result, nil
+}
+
+// identify validates req and determines the disc ID, FreeDB ID and TOC of the disc.
+//
+// Returns:
+//   - Result: The result with Mode, DiscID, FreedbID and Toc set.
+//   - *types.DiscInfo: The metadata of the forced MusicBrainz release, nil if none.
+//   - error: Any error encountered while validating the request, fetching the release or reading the disc.
+func (g *Generator) identify(ctx context.Context, req Request) (Result, *types.DiscInfo, error) {
+	mode, err := req.Validate()
+	if err != nil {
+		return Result{}, nil, fmt.Errorf("Invalid request: %w", err)
+	}
+	result := Result{Mode: mode}
+
+	media, err := g.fetchRelease(ctx, req)
+	if err != nil {
+		return result, nil, err
+	}
+
+	if result.FreedbID, result.Toc, err = g.resolveDisc(mode, req, media); err != nil {
+		return result, nil, err
+	}
+	discInfo, err := selectMedium(req.MusicBrainzID, media, result.Toc)
+	if err != nil {
+		return result, nil, err
+	}
+	result.DiscID = g.cacheKey(result.FreedbID, result.Toc)
+	if !cache.IsFreedbID(result.FreedbID) {
+		result.FreedbID = ""
+	}
+	g.hooks.tocRead(result.DiscID, result.Toc)
+	return result, discInfo, nil
+}
+
+// selectMetadata sets the metadata and source of result, from the forced release if any, else from
+// the first successful provider.
+//
+// Returns:
+//   - *types.DiscInfo: The metadata chosen.
+//   - error: An error if no TOC is known to query the providers, or every provider failed.
+func (g *Generator) selectMetadata(ctx context.Context, result *Result, discInfo *types.DiscInfo) (*types.DiscInfo, error) {
+	if discInfo != nil {
+		result.Source = SourceRelease
+	} else {
+		if result.Toc == nil {
+			return nil, fmt.Errorf("Failed to get disc metadata: no TOC")
+		}
+		result.Candidates = g.lookup(ctx, result.DiscID, result.Toc)
+		var err error
+		if discInfo, result.Source, err = selectCandidate(result.Candidates); err != nil {
+			return nil, fmt.Errorf("Failed to get disc metadata: %w", err)
+		}
+	}
+	result.DiscInfo = discInfo
+	return discInfo, nil
+}
+
+// cacheKey returns the cache key of a disc: its MusicBrainz disc ID when the TOC is known, as FreeDB IDs
+// collide, or the provided disc ID with FreeDB ID aliases resolved.
+func (g *Generator) cacheKey(discID string, toc *utils.Toc) string {
+	if toc != nil {
+		return toc.MusicBrainzDiscID()
+	}
+	return g.store.Resolve(discID)
+}
+
+// Refresh fetches the metadata of a cached disc again and overwrites its playlists. The request is
+// rebuilt from the disc sidecar, so the disc does not need to be inserted.
+//
+// Parameters:
+//   - ctx: The context of the network requests.
+//   - discID: The disc ID used as cache key, or a FreeDB ID alias.
+//
+// Returns:
+//   - Result: The outcome of the generation.
+//   - error: An error if the sidecar is missing or the generation fails.
+func (g *Generator) Refresh(ctx context.Context, discID string) (Result, error) {
+	discID = g.store.Resolve(discID)
+	metadata, err := cache.GetMetadata(g.store, discID)
+	if err != nil {
+		return Result{}, fmt.Errorf("Failed to read %s metadata: %w", discID, err)
+	}
+	req, err := refreshRequest(metadata)
+	if err != nil {
+		return Result{}, err
+	}
+	return g.Generate(ctx, req)
+}
+
+// Store returns the storage of the cached discs.
+func (g *Generator) Store() cache.Store {
+	return g.store
+}
+
+// Writers returns the writers of the playlists written for each disc, the first one deciding if a disc is cached.
+func (g *Generator) Writers() []Writer {
+	return append([]Writer(nil), g.writers...)
+}
+
+// Wait blocks until the background refreshes and hook commands started by Generate are done. Call it
+// before exiting, so that the hook commands of the last generations complete.
+func (g *Generator) Wait() {
+	g.refreshes.Wait()
+	// Refreshes run Generate, starting hook commands
+	g.hookRuns.Wait()
+}
+
+// refreshInBackground starts refreshing a stale disc unless a refresh is already running for it.
+//
+// Parameters:
+//   - ctx: The context of the request that found the disc stale. Its values are kept but not its cancellation.
+//   - metadata: The stale disc metadata.
+//
+// Returns:
+//   - bool: True if a refresh is running for the disc.
+func (g *Generator) refreshInBackground(ctx context.Context, metadata *cache.Metadata) bool {
+	req, err := refreshRequest(metadata)
+	if err != nil {
+		g.logger.Warn("cannot refresh stale playlist", "disc_id", metadata.DiscID, "error", err)
+		return false
+	}
+
+	g.refreshMu.Lock()
+	defer g.refreshMu.Unlock()
+	if g.refreshing[metadata.DiscID] {
+		return true
+	}
+	g.refreshing[metadata.DiscID] = true
+	g.refreshes.Add(1)
+
+	go func() {
+		defer g.refreshes.Done()
+		defer func() {
+			g.refreshMu.Lock()
+			delete(g.refreshing, metadata.DiscID)
+			g.refreshMu.Unlock()
+		}()
+		if _, err := g.Generate(context.WithoutCancel(ctx), req); err != nil {
+			g.logger.Warn("background refresh failed", "disc_id", metadata.DiscID, "error", err)
+		}
+	}()
+	return true
+}
+
+// refreshRequest rebuilds the request regenerating a cached disc from its sidecar.
+func refreshRequest(metadata *cache.Metadata) (Request, error) {
+	req := Request{Overwrite: true}
+	if metadata.Source == SourceRelease {
+		req.MusicBrainzID = metadata.MusicBrainzID
+	}
+	if req.MusicBrainzID != "" {
+		req.Medium = metadata.DiscInfo.Medium
+	}
+	switch {
+	case metadata.Toc != "":
+		req.Toc = metadata.Toc
+	case req.MusicBrainzID != "":
+		req.DiscID = metadata.DiscID
+	default:
+		return req, fmt.Errorf("cannot refresh %s: no TOC in metadata", metadata.DiscID)
+	}
+	return req, nil
+}
+
+// Regenerate writes the playlists of a cached disc again from its metadata sidecar,
+// without querying the network. It is meant to produce files in newly configured formats.
+//
+// Parameters:
+//   - discID: The disc ID used as cache key, or a FreeDB ID alias.
+//
+// Returns:
+//   - Result: The outcome of the regeneration, with CacheHit set.
+//   - error: An error if the sidecar is missing or a playlist cannot be written.
+func (g *Generator) Regenerate(discID string) (Result, error) {
+	return g.rewrite(discID, nil)
+}
+
+// UpdateDiscInfo replaces the metadata of a cached disc, e.g. to correct it, and writes its playlists
+// again without querying the network. The cover art is kept, and the metadata source becomes
+// cache.SourceManual, which does not expire with the default cache TTL.
+//
+// Parameters:
+//   - discID: The disc ID used as cache key, or a FreeDB ID alias.
+//   - info: The new metadata of the disc.
+//
+// Returns:
+//   - Result: The outcome of the update, with CacheHit set.
+//   - error: An error if the sidecar is missing, or the sidecar or a playlist cannot be written.
+func (g *Generator) UpdateDiscInfo(discID string, info types.DiscInfo) (Result, error) {
+	return g.rewrite(discID, func(metadata *cache.Metadata) {
+		info.CoverArtPath = metadata.DiscInfo.CoverArtPath
+		metadata.DiscInfo = info
+		metadata.MusicBrainzID = info.ID
+		metadata.Source = cache.SourceManual
+		metadata.FetchedAt = g.now()
+	})
+}
+
+// rewrite writes the playlists of a cached disc again from its sidecar, after applying update to the
+// sidecar if not nil.
+func (g *Generator) rewrite(discID string, update func(*cache.Metadata)) (Result, error) {
+	discID = g.store.Resolve(discID)
+	lock, err := utils.LockDisc(context.Background(), g.cacheLocation, discID)
+	if err != nil {
+		return Result{}, err
+	}
+	defer lock.Unlock()
+
+	metadata, err := cache.GetMetadata(g.store, discID)
+	if err != nil {
+		return Result{}, fmt.Errorf("Failed to read %s metadata: %w", discID, err)
+	}
+	if update != nil {
+		update(metadata)
+		if err := cache.PutMetadata(g.store, metadata); err != nil {
+			return Result{}, fmt.Errorf("Failed to save %s metadata: %w", discID, err)
+		}
+	}
+	result := Result{
+		DiscID:      discID,
+		FreedbID:    metadata.FreedbID,
+		DiscInfo:    &metadata.DiscInfo,
+		Source:      metadata.Source,
+		CoverPath:   metadata.DiscInfo.CoverArtPath,
+		CoverSource: metadata.CoverSource,
+		CacheHit:    true,
+	}
+	if metadata.Toc != "" {
+		if result.Toc, err = utils.ParseMusicBrainzToc(metadata.Toc); err != nil {
+			return result, fmt.Errorf("Invalid %s cached TOC: %w", discID, err)
+		}
+	}
+	return result, g.writePlaylists(&result)
+}
+
+// writePlaylists renders result.DiscInfo with every writer into the store and records the written files in result.
+func (g *Generator) writePlaylists(result *Result) error {
+	for _, writer := range g.writers {
+		if err := g.writePlaylist(result, writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePlaylist renders result.DiscInfo with writer into the store and records the written file in result.
+// Formats needing the TOC are skipped when it is unknown.
+func (g *Generator) writePlaylist(result *Result, writer Writer) error {
+	data, err := renderPlaylist(writer, result.DiscInfo, result.Toc)
+	if errors.Is(err, ErrTocRequired) {
+		g.logger.Warn("playlist format skipped", "file", writer.FileName(), "disc_id", result.DiscID, "error", err)
+		return nil
+	}
+	if err == nil {
+		err = g.store.Put(result.DiscID, writer.FileName(), data)
+	}
+	path := cache.FileRef(g.store, result.DiscID, writer.FileName())
+	if err != nil {
+		return fmt.Errorf("Failed To Generate playlist %s: %w", path, err)
+	}
+	result.Files = append(result.Files, path)
+	g.hooks.fileWritten(result.DiscID, path)
+	g.logger.Info("playlist generated", "path", path)
+	return nil
+}
+
+// fetchRelease returns the media of the forced MusicBrainz release, only the one selected by
+// req.Medium if set, or nil if no release is forced.
+func (g *Generator) fetchRelease(ctx context.Context, req Request) ([]musicbrainz.Medium, error) {
+	if req.MusicBrainzID == "" {
+		return nil, nil
+	}
+	media, err := g.musicbrainz.FetchReleaseMedia(ctx, req.MusicBrainzID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get MusicBrainz %s Release: %w", req.MusicBrainzID, err)
+	}
+	if req.Medium == 0 {
+		return media, nil
+	}
+	for _, medium := range media {
+		if medium.Position == req.Medium {
+			return []musicbrainz.Medium{medium}, nil
+		}
+	}
+	return nil, fmt.Errorf("MusicBrainz release %s has no medium %d, expected 1 to %d", req.MusicBrainzID, req.Medium, len(media))
+}
+
+// resolveDisc determines the disc ID and TOC according to the generation mode.
+//
+// Parameters:
+//   - mode: The mode returned by Request.Validate.
+//   - req: The generation request.
+//   - media: The media of the forced release, whose disc IDs are used in ModeRelease.
+//
+// Returns:
+//   - string: The FreeDB ID of the disc, or the provided disc ID in ModeDiscID.
+//   - *utils.Toc: The disc TOC, nil in ModeDiscID.
+//   - error: Any error encountered while reading the drive or parsing the TOC, or ErrAmbiguousRelease
+//     if several media have disc IDs in ModeRelease.
+func (g *Generator) resolveDisc(mode Mode, req Request, media []musicbrainz.Medium) (string, *utils.Toc, error) {
+	switch mode {
+	case ModeRelease:
+		var withDiscs []musicbrainz.Medium
+		var positions []string
+		for _, medium := range media {
+			if len(medium.Discs) > 0 {
+				withDiscs = append(withDiscs, medium)
+				positions = append(positions, strconv.Itoa(medium.Position))
+			}
+		}
+		if len(withDiscs) == 0 {
+			if req.Device == "" {
+				return "", nil, fmt.Errorf("MusicBrainz release %s has no disc ID: %w", req.MusicBrainzID, ErrNoDevice)
+			}
+			g.logger.Info("MusicBrainz release has no disc ID, reading drive", "release", req.MusicBrainzID, "device", req.Device)
+			return g.readDisc(req.Device)
+		}
+		if len(withDiscs) > 1 {
+			return "", nil, fmt.Errorf("%w: media %s have disc IDs, select the disc with --medium, --toc or --from-drive",
+				ErrAmbiguousRelease, strings.Join(positions, ", "))
+		}
+		// The disc IDs of a medium are pressings sharing its tracklist, the first one stands for them
+		medium := withDiscs[0]
+		disc := medium.Discs[0]
+		if len(medium.Discs) > 1 {
+			others := make([]string, 0, len(medium.Discs)-1)
+			for _, d := range medium.Discs[1:] {
+				others = append(others, d.ID)
+			}
+			g.logger.Info("MusicBrainz medium has several disc IDs, using the first", "release", req.MusicBrainzID,
+				"medium", medium.Position, "disc_id", disc.ID, "other_disc_ids", strings.Join(others, ", "))
+		}
+		toc, err := utils.NewTocFromMusicBrainzDisc(disc)
+		if err != nil {
+			return "", nil, err
+		}
+		g.logger.Debug("TOC from MusicBrainz release", "mb_toc", toc.MusicBrainzString())
+		return toc.FreedbID(), toc, nil
+	case ModeDiscID:
+		return req.DiscID, nil, nil
+	case ModeToc, ModeTocRelease:
+		toc, err := utils.ParseMusicBrainzToc(req.Toc)
+		if err != nil {
+			return "", nil, err
+		}
+		return toc.FreedbID(), toc, nil
+	default:
+		return g.readDisc(req.Device)
+	}
+}
+
+// selectMedium returns the metadata of the medium of a forced release holding the disc: the only
+// medium, else the one with the disc ID of toc, else the only one with as many tracks as toc.
+//
+// Parameters:
+//   - release: The forced release ID, for errors.
+//   - media: The candidate media, nil if no release is forced.
+//   - toc: The disc TOC, nil if only the disc ID is known.
+//
+// Returns:
+//   - *types.DiscInfo: The metadata of the medium, nil if no release is forced.
+//   - error: ErrAmbiguousRelease if the medium cannot be determined.
+func selectMedium(release string, media []musicbrainz.Medium, toc *utils.Toc) (*types.DiscInfo, error) {
+	switch len(media) {
+	case 0:
+		return nil, nil
+	case 1:
+		return media[0].DiscInfo, nil
+	}
+	if toc == nil {
+		return nil, fmt.Errorf("%w: release %s has %d media, select the disc with --medium", ErrAmbiguousRelease, release, len(media))
+	}
+	discID := toc.MusicBrainzDiscID()
+	for _, medium := range media {
+		for _, disc := range medium.Discs {
+			if disc.ID == discID {
+				return medium.DiscInfo, nil
+			}
+		}
+	}
+	var match *types.DiscInfo
+	for _, medium := range media {
+		if len(medium.DiscInfo.Tracks) != toc.TrackCount() {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("%w: several media of release %s have %d tracks, select the disc with --medium",
+				ErrAmbiguousRelease, release, toc.TrackCount())
+		}
+		match = medium.DiscInfo
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no medium of MusicBrainz release %s matches the %d tracks disc", release, toc.TrackCount())
+	}
+	return match, nil
+}
+
+// readDisc reads the disc in device and returns its FreeDB ID and TOC.
+func (g *Generator) readDisc(device string) (string, *utils.Toc, error) {
+	disc, err := discid.Read(device)
+	if err != nil {
+		return "", nil, err
+	}
+	defer disc.Close()
+
+	toc, err := utils.NewTocFromDisc(disc)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed to read %s TOC: %w", device, err)
+	}
+	g.logger.Debug("TOC read from drive", "device", device, "gnu_toc", toc.GnuString(), "mb_toc", toc.MusicBrainzString())
+	return disc.FreedbID(), toc, nil
+}
@@ -0,0 +1,227 @@
+package cue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/musicbrainz"
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// Discs with their libdiscid disc IDs, see utils/toc_test.go.
+var (
+	tenTracksDisc = types.MBDisc{
+		ID:          "Wn8eRBtfLDfM0qjYPdxrz.Zjs_U-",
+		Sectors:     206535,
+		OffsetCount: 10,
+		Offsets:     []int{150, 18901, 39738, 59557, 79152, 100126, 124833, 147278, 166336, 182560},
+	}
+	fifteenTracksDisc = types.MBDisc{
+		ID:          "TqvKjMu7dMliSfmVEBtrL7sBSno-",
+		Sectors:     258725,
+		OffsetCount: 15,
+		Offsets:     []int{150, 17510, 33275, 45910, 57805, 78310, 94650, 109580, 132010, 149160, 165115, 177710, 203325, 215555, 235590},
+	}
+)
+
+// testMedium returns a medium of trackCount tracks with the given disc IDs.
+func testMedium(position, trackCount int, discs ...types.MBDisc) musicbrainz.Medium {
+	return musicbrainz.Medium{
+		Position: position,
+		DiscInfo: &types.DiscInfo{Title: "Album", Tracks: make([]string, trackCount), Medium: position},
+		Discs:    discs,
+	}
+}
+
+// mustToc returns the TOC of a MusicBrainz disc.
+func mustToc(t *testing.T, disc types.MBDisc) *utils.Toc {
+	t.Helper()
+	toc, err := utils.NewTocFromMusicBrainzDisc(disc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toc
+}
+
+func TestSelectMedium(t *testing.T) {
+	twoMedia := []musicbrainz.Medium{testMedium(1, 10, tenTracksDisc), testMedium(2, 15, fifteenTracksDisc)}
+	noDiscIDs := []musicbrainz.Medium{testMedium(1, 10), testMedium(2, 15)}
+	sameLength := []musicbrainz.Medium{testMedium(1, 10), testMedium(2, 10)}
+
+	tests := []struct {
+		name    string
+		media   []musicbrainz.Medium
+		toc     *utils.Toc
+		want    int
+		wantErr error
+	}{
+		{name: "no release", want: -1},
+		{name: "single medium", media: twoMedia[:1], want: 1},
+		{name: "single medium without toc", media: twoMedia[1:], want: 2},
+		{name: "disc ID of second medium", media: twoMedia, toc: mustToc(t, fifteenTracksDisc), want: 2},
+		{name: "disc ID of first medium", media: twoMedia, toc: mustToc(t, tenTracksDisc), want: 1},
+		{name: "track count", media: noDiscIDs, toc: mustToc(t, fifteenTracksDisc), want: 2},
+		{name: "no toc", media: twoMedia, wantErr: ErrAmbiguousRelease},
+		{name: "same track count", media: sameLength, toc: mustToc(t, tenTracksDisc), wantErr: ErrAmbiguousRelease},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := selectMedium("rel", tt.media, tt.toc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("selectMedium() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			switch {
+			case tt.want < 0 && info != nil:
+				t.Errorf("selectMedium() = %+v, want nil", info)
+			case tt.want >= 0 && (info == nil || info.Medium != tt.want):
+				t.Errorf("selectMedium() = %+v, want medium %d", info, tt.want)
+			}
+		})
+	}
+
+	if _, err := selectMedium("rel", noDiscIDs, mustToc(t, types.MBDisc{ID: "x", Sectors: 1000, OffsetCount: 1, Offsets: []int{150}})); err == nil {
+		t.Error("selectMedium() without matching track count: error = nil")
+	}
+}
+
+func TestResolveDiscRelease(t *testing.T) {
+	g := &Generator{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	req := Request{MusicBrainzID: "rel"}
+
+	freedbID, toc, err := g.resolveDisc(ModeRelease, req, []musicbrainz.Medium{testMedium(1, 10), testMedium(2, 15, fifteenTracksDisc)})
+	if err != nil {
+		t.Fatalf("resolveDisc() error = %v", err)
+	}
+	if toc.MusicBrainzDiscID() != fifteenTracksDisc.ID || freedbID != "b60d770f" {
+		t.Errorf("resolveDisc() = %s, %s, want the disc of the second medium", freedbID, toc.MusicBrainzDiscID())
+	}
+
+	// The disc IDs of a medium are pressings of the same tracklist, the first one is used
+	if _, toc, err = g.resolveDisc(ModeRelease, req, []musicbrainz.Medium{testMedium(1, 10, tenTracksDisc, fifteenTracksDisc)}); err != nil {
+		t.Fatalf("resolveDisc() of a medium with two disc IDs: error = %v", err)
+	}
+	if toc.MusicBrainzDiscID() != tenTracksDisc.ID {
+		t.Errorf("resolveDisc() of a medium with two disc IDs = %s, want the first disc ID", toc.MusicBrainzDiscID())
+	}
+
+	// Disc IDs on several media are ambiguous
+	media := []musicbrainz.Medium{testMedium(1, 10, tenTracksDisc), testMedium(2, 15, fifteenTracksDisc)}
+	if _, _, err := g.resolveDisc(ModeRelease, req, media); !errors.Is(err, ErrAmbiguousRelease) {
+		t.Errorf("resolveDisc() error = %v, want ErrAmbiguousRelease", err)
+	}
+
+	if _, _, err := g.resolveDisc(ModeRelease, req, []musicbrainz.Medium{testMedium(1, 10)}); !errors.Is(err, ErrNoDevice) {
+		t.Errorf("resolveDisc() without disc ID nor device: error = %v, want ErrNoDevice", err)
+	}
+}
+
+func TestRefreshRequestKeepsMedium(t *testing.T) {
+	req, err := refreshRequest(&cache.Metadata{
+		DiscID:        "disc",
+		Source:        SourceRelease,
+		MusicBrainzID: "rel",
+		DiscInfo:      types.DiscInfo{Medium: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Medium != 2 || req.DiscID != "disc" || req.MusicBrainzID != "rel" {
+		t.Errorf("refreshRequest() = %+v, want the disc ID, release and medium 2", req)
+	}
+	if _, err := req.Validate(); err != nil {
+		t.Errorf("refreshRequest() is invalid: %v", err)
+	}
+}
+
+// releaseTransport answers every request with a MusicBrainz release.
+type releaseTransport string
+
+func (body releaseTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(body))),
+		Request:    r,
+	}, nil
+}
+
+func TestLookupReleaseSeveralDiscIDs(t *testing.T) {
+	// A one-CD release with the disc IDs of two pressings
+	offsets, _ := json.Marshal(tenTracksDisc.Offsets)
+	release := fmt.Sprintf(`{
+  "id": "rel",
+  "title": "Album",
+  "artist-credit": [{"name": "Artist"}],
+  "media": [{"position": 1, "tracks": [%s], "discs": [
+    {"id": %q, "sectors": %d, "offset-count": 10, "offsets": %s},
+    {"id": "other-pressing", "sectors": %d, "offset-count": 10, "offsets": %s}
+  ]}]
+}`, strings.TrimSuffix(strings.Repeat(`{"title": "Track"},`, 10), ","),
+		tenTracksDisc.ID, tenTracksDisc.Sectors, offsets, tenTracksDisc.Sectors+75, offsets)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	g, err := NewGenerator(&config.Config{CacheLocation: t.TempDir(), Formats: []string{"cue"}, Logger: logger},
+		WithHTTPClient(&http.Client{Transport: releaseTransport(release)}),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := g.Lookup(context.Background(), Request{MusicBrainzID: "rel"})
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if result.Mode != ModeRelease || result.Toc.MusicBrainzDiscID() != tenTracksDisc.ID || len(result.DiscInfo.Tracks) != 10 {
+		t.Errorf("Lookup() = mode %v, disc ID %s, %d tracks, want the first disc ID and the 10 tracks",
+			result.Mode, result.Toc.MusicBrainzDiscID(), len(result.DiscInfo.Tracks))
+	}
+}
+
+func TestRefreshAlias(t *testing.T) {
+	for _, backend := range []string{cache.BackendDir, cache.BackendBolt} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := cache.OpenStore(backend, dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			provider := &fakeProvider{}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			g, err := NewGenerator(&config.Config{CacheLocation: dir, Formats: []string{"cue"}, Logger: logger},
+				WithStore(store),
+				WithProviders(provider),
+				WithCoverSources(noCoverSource{}),
+				WithLogger(logger),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := g.Generate(context.Background(), Request{Toc: testToc})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			refreshed, err := g.Refresh(context.Background(), first.FreedbID)
+			if err != nil {
+				t.Fatalf("Refresh() of the FreeDB ID alias: error = %v", err)
+			}
+			if refreshed.DiscID != first.DiscID || provider.count() != 2 || refreshed.DiscInfo.Title != "Album 2" {
+				t.Errorf("Refresh() = %s %q after %d lookups, want %s refreshed", refreshed.DiscID, refreshed.DiscInfo.Title, provider.count(), first.DiscID)
+			}
+		})
+	}
+}
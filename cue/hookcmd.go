@@ -0,0 +1,152 @@
+package cue
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+)
+
+// HookCommands holds the shell commands run after each generation, with the environment of HookEnv.
+// Empty commands are skipped.
+//
+// Fields:
+//   - OnGenerated (string): Run when Generate succeeds, including on a cache hit.
+//   - OnNotFound (string): Run when no provider knows the disc.
+//   - OnError (string): Run when Generate fails for another reason.
+//   - Timeout (time.Duration): Kills a command running longer. No limit if zero.
+type HookCommands struct {
+	OnGenerated string
+	OnNotFound  string
+	OnError     string
+	Timeout     time.Duration
+}
+
+// command returns the command of an outcome, see HookEvent.
+func (c HookCommands) command(event string) string {
+	switch event {
+	case HookGenerated:
+		return c.OnGenerated
+	case HookNotFound:
+		return c.OnNotFound
+	case HookError:
+		return c.OnError
+	default:
+		return ""
+	}
+}
+
+// WithHookCommands sets the shell commands run after each generation. Defaults to the configuration
+// OnGenerated, OnNotFound, OnError and HookTimeout.
+func WithHookCommands(commands HookCommands) Option {
+	return func(g *Generator) {
+		g.hookCommands = commands
+	}
+}
+
+// HookEnv returns the environment variables describing a generation to the hook commands. Variables
+// of unknown values are set empty.
+//
+// Parameters:
+//   - req: The generation request.
+//   - result: The result returned by Generate, possibly partial.
+//   - err: The error returned by Generate, or nil.
+//
+// Returns:
+//   - []string: The variables, as "KEY=value":
+//     DISC_CUER_EVENT (generated, not-found or error), DISC_ID, FREEDB_ID, MUSICBRAINZ_ID, DEVICE, TOC,
+//     SOURCE, CACHE_HIT (true or false), CUE_PATH, FILES (newline separated), COVER_PATH, ARTIST,
+//     ALBUM, RELEASE_DATE, GENRE, TRACK_COUNT and ERROR.
+func HookEnv(req Request, result Result, err error) []string {
+	env := map[string]string{
+		"DISC_CUER_EVENT": HookEvent(err),
+		"DISC_ID":         result.DiscID,
+		"FREEDB_ID":       result.FreedbID,
+		"DEVICE":          req.Device,
+		"SOURCE":          result.Source,
+		"CACHE_HIT":       strconv.FormatBool(result.CacheHit),
+		"FILES":           strings.Join(result.Files, "\n"),
+		"COVER_PATH":      result.CoverPath,
+	}
+	if result.Toc != nil {
+		env["TOC"] = result.Toc.MusicBrainzString()
+	} else {
+		env["TOC"] = req.Toc
+	}
+	for _, file := range result.Files {
+		if filepath.Base(file) == cache.PlaylistFile {
+			env["CUE_PATH"] = file
+		}
+	}
+	if info := result.DiscInfo; info != nil {
+		env["MUSICBRAINZ_ID"] = info.ID
+		env["ARTIST"] = info.Artist
+		env["ALBUM"] = info.Title
+		env["RELEASE_DATE"] = info.ReleaseDate
+		env["GENRE"] = info.Genre
+		env["TRACK_COUNT"] = strconv.Itoa(len(info.Tracks))
+	}
+	if err != nil {
+		env["ERROR"] = err.Error()
+	}
+
+	keys := []string{"DISC_CUER_EVENT", "DISC_ID", "FREEDB_ID", "MUSICBRAINZ_ID", "DEVICE", "TOC", "SOURCE",
+		"CACHE_HIT", "CUE_PATH", "FILES", "COVER_PATH", "ARTIST", "ALBUM", "RELEASE_DATE", "GENRE", "TRACK_COUNT", "ERROR"}
+	vars := make([]string, len(keys))
+	for i, key := range keys {
+		vars[i] = key + "=" + env[key]
+	}
+	return vars
+}
+
+// startHookCommand runs the hook command of the outcome of a generation, if any, in a goroutine
+// tracked by Generator.Wait, so that a slow command does not delay the caller of Generate.
+func (g *Generator) startHookCommand(req Request, result Result, err error) {
+	if g.hookCommands.command(HookEvent(err)) == "" {
+		return
+	}
+	g.hookRuns.Add(1)
+	go func() {
+		defer g.hookRuns.Done()
+		g.runHookCommand(req, result, err)
+	}()
+}
+
+// runHookCommand runs the hook command of the outcome of a generation, if any. Its output goes to
+// stderr, keeping stdout for the JSON output, and failures are only logged.
+func (g *Generator) runHookCommand(req Request, result Result, err error) {
+	event := HookEvent(err)
+	command := g.hookCommands.command(event)
+	if command == "" {
+		return
+	}
+	ctx := context.Background()
+	if g.hookCommands.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.hookCommands.Timeout)
+		defer cancel()
+	}
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), HookEnv(req, result, err)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	g.logger.Debug("running hook command", "event", event, "command", command, "disc_id", result.DiscID)
+	if err := cmd.Run(); err != nil {
+		g.logger.Warn("hook command failed", "event", event, "command", command, "disc_id", result.DiscID, "error", err)
+	}
+}
+
+// shellCommand returns the command running command in the system shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
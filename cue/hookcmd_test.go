@@ -0,0 +1,47 @@
+package cue
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/config"
+)
+
+func TestHookCommandRunsInBackground(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook command uses sh")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "event")
+	g, err := NewGenerator(&config.Config{
+		CacheLocation: dir,
+		Formats:       []string{"cue"},
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}, WithHookCommands(HookCommands{OnError: `sleep 0.5 && echo "$DISC_CUER_EVENT $TOC" > ` + out}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := g.Generate(context.Background(), Request{Toc: "invalid"}); err == nil {
+		t.Fatal("Generate() with an invalid TOC: error = nil")
+	}
+	if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+		t.Errorf("Generate() took %v, waiting for the hook command", elapsed)
+	}
+	g.Wait()
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook command output after Wait(): %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "error invalid" {
+		t.Errorf("hook command output = %q, want %q", got, "error invalid")
+	}
+}
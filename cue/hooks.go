@@ -0,0 +1,108 @@
+package cue
+
+import (
+	"context"
+	"errors"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// Hooks holds optional callbacks invoked at each stage of a generation. Any of them may be nil.
+// Provider callbacks are invoked from the goroutine querying the provider. Every callback receives
+// the disc ID, to tell concurrent generations apart.
+//
+// Fields:
+//   - OnTocRead: Called once the disc ID and TOC are known (toc is nil when only a disc ID was provided).
+//   - OnProviderStart: Called before a provider is queried.
+//   - OnProviderDone: Called with the outcome of a provider query.
+//   - OnCoverFetched: Called for each cover art image saved.
+//   - OnFileWritten: Called for each playlist file written.
+//   - OnGenerated: Called when Generate succeeds, including on a cache hit.
+//   - OnNotFound: Called when Generate fails because no provider knows the disc, see ErrNotFound.
+//   - OnError: Called when Generate fails for another reason, except when its context is canceled.
+type Hooks struct {
+	OnTocRead       func(discID string, toc *utils.Toc)
+	OnProviderStart func(discID, provider string)
+	OnProviderDone  func(discID string, candidate Candidate)
+	OnCoverFetched  func(discID, path string)
+	OnFileWritten   func(discID, path string)
+	OnGenerated     func(result Result)
+	OnNotFound      func(result Result, err error)
+	OnError         func(result Result, err error)
+}
+
+func (h Hooks) tocRead(discID string, toc *utils.Toc) {
+	if h.OnTocRead != nil {
+		h.OnTocRead(discID, toc)
+	}
+}
+
+func (h Hooks) providerStart(discID, provider string) {
+	if h.OnProviderStart != nil {
+		h.OnProviderStart(discID, provider)
+	}
+}
+
+func (h Hooks) providerDone(discID string, candidate Candidate) {
+	if h.OnProviderDone != nil {
+		h.OnProviderDone(discID, candidate)
+	}
+}
+
+func (h Hooks) coverFetched(discID, path string) {
+	if h.OnCoverFetched != nil {
+		h.OnCoverFetched(discID, path)
+	}
+}
+
+func (h Hooks) fileWritten(discID, path string) {
+	if h.OnFileWritten != nil {
+		h.OnFileWritten(discID, path)
+	}
+}
+
+// Outcomes of a generation, see HookEvent.
+const (
+	HookGenerated = "generated"
+	HookNotFound  = "not-found"
+	HookError     = "error"
+)
+
+// HookEvent returns the outcome of a generation: "generated", "not-found" or "error", or "" if the
+// generation was interrupted by its context.
+//
+// Parameters:
+//   - err: The error returned by Generate, or nil.
+//
+// Returns:
+//   - string: The outcome, naming the hook called.
+func HookEvent(err error) string {
+	switch {
+	case err == nil:
+		return HookGenerated
+	case errors.Is(err, context.Canceled):
+		return ""
+	case errors.Is(err, ErrNotFound):
+		return HookNotFound
+	default:
+		return HookError
+	}
+}
+
+// generated calls the outcome callback of a generation.
+func (h Hooks) generated(result Result, err error) {
+	switch HookEvent(err) {
+	case HookGenerated:
+		if h.OnGenerated != nil {
+			h.OnGenerated(result)
+		}
+	case HookNotFound:
+		if h.OnNotFound != nil {
+			h.OnNotFound(result, err)
+		}
+	case HookError:
+		if h.OnError != nil {
+			h.OnError(result, err)
+		}
+	}
+}
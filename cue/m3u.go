@@ -0,0 +1,56 @@
+package cue
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// M3U8Writer writes extended M3U playlists, UTF-8 encoded, referencing the disc tracks as cdda:// URLs.
+type M3U8Writer struct{}
+
+// FileName returns "playlist.m3u8".
+func (M3U8Writer) FileName() string {
+	return "playlist.m3u8"
+}
+
+// Write renders the M3U8 playlist for info, with an #EXTINF line per track holding its
+// duration in seconds, -1 if the TOC is unknown, and "artist - title".
+//
+// Parameters:
+//   - w: The destination of the playlist.
+//   - info: Metadata about the disc.
+//   - toc: The disc TOC, used for the track durations. May be nil.
+//
+// Returns:
+//   - error: Any error encountered while writing.
+func (M3U8Writer) Write(w io.Writer, info *types.DiscInfo, toc *utils.Toc) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#PLAYLIST:%s - %s\n", m3uText(info.Artist), m3uText(info.Title))
+	fmt.Fprintf(&b, "#EXTART:%s\n", m3uText(info.Artist))
+	fmt.Fprintf(&b, "#EXTALB:%s\n", m3uText(info.Title))
+	if info.Genre != "" {
+		fmt.Fprintf(&b, "#EXTGENRE:%s\n", m3uText(info.Genre))
+	}
+	if info.CoverArtPath != "" {
+		fmt.Fprintf(&b, "#EXTIMG:%s\n", info.CoverArtPath)
+	}
+	for i, track := range info.Tracks {
+		seconds := -1
+		if duration, ok := trackDuration(toc, i); ok {
+			seconds = int(duration.Seconds())
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%s - %s\n%s\n", seconds, m3uText(info.Artist), m3uText(track), trackLocation(i))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// m3uText keeps a value on a single line of the playlist.
+func m3uText(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
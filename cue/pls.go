@@ -0,0 +1,44 @@
+package cue
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// PLSWriter writes PLS playlists referencing the disc tracks as cdda:// URLs.
+type PLSWriter struct{}
+
+// FileName returns "playlist.pls".
+func (PLSWriter) FileName() string {
+	return "playlist.pls"
+}
+
+// Write renders the PLS playlist for info, with the "artist - title" and length in seconds
+// of each track, -1 if the TOC is unknown.
+//
+// Parameters:
+//   - w: The destination of the playlist.
+//   - info: Metadata about the disc.
+//   - toc: The disc TOC, used for the track lengths. May be nil.
+//
+// Returns:
+//   - error: Any error encountered while writing.
+func (PLSWriter) Write(w io.Writer, info *types.DiscInfo, toc *utils.Toc) error {
+	var b strings.Builder
+	b.WriteString("[playlist]\n")
+	for i, track := range info.Tracks {
+		seconds := -1
+		if duration, ok := trackDuration(toc, i); ok {
+			seconds = int(duration.Seconds())
+		}
+		fmt.Fprintf(&b, "File%d=%s\nTitle%d=%s - %s\nLength%d=%d\n",
+			i+1, trackLocation(i), i+1, m3uText(info.Artist), m3uText(track), i+1, seconds)
+	}
+	fmt.Fprintf(&b, "NumberOfEntries=%d\nVersion=2\n", len(info.Tracks))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
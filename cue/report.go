@@ -0,0 +1,226 @@
+package cue
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// ReportVersion is the version of the Report JSON schema. It is only increased when a field
+// is removed or changes meaning; new fields may be added within a version.
+const ReportVersion = 1
+
+// Report is the stable JSON representation of a Result, for scripts wrapping the CLI.
+//
+// Fields:
+//   - Version (int): The schema version, ReportVersion.
+//   - Mode (string): The generation mode, see Mode.String.
+//   - DiscID (string): The disc ID used as cache key.
+//   - FreedbID (string): The FreeDB ID of the disc, if known.
+//   - Toc (*ReportToc): The disc TOC, nil when only a disc ID was provided.
+//   - Source (string): The source of the chosen metadata.
+//   - Release (*ReportRelease): The chosen metadata, nil if none was found.
+//   - Candidates ([]ReportCandidate): The outcome of every queried provider, empty on a cache hit or a forced release.
+//   - Files ([]string): The playlist files, in format order.
+//   - Cover (*ReportCover): The front cover art, nil if none.
+//   - CacheHit (bool): True if the playlists were already cached.
+//   - Stale (bool): True if the cached playlists returned have expired.
+//   - Refreshing (bool): True if a background refresh is running.
+//   - Error (string): The error message when the generation failed.
+type Report struct {
+	Version    int               `json:"version"`
+	Mode       string            `json:"mode"`
+	DiscID     string            `json:"discId,omitempty"`
+	FreedbID   string            `json:"freedbId,omitempty"`
+	Toc        *ReportToc        `json:"toc,omitempty"`
+	Source     string            `json:"source,omitempty"`
+	Release    *ReportRelease    `json:"release,omitempty"`
+	Candidates []ReportCandidate `json:"candidates"`
+	Files      []string          `json:"files"`
+	Cover      *ReportCover      `json:"cover,omitempty"`
+	CacheHit   bool              `json:"cacheHit"`
+	Stale      bool              `json:"stale"`
+	Refreshing bool              `json:"refreshing"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// ReportToc is the disc TOC, in both query formats and as numbers.
+//
+// Fields:
+//   - MusicBrainz (string): The TOC in MusicBrainz format ("first last leadout offset1 ... offsetN").
+//   - Freedb (string): The TOC in FreeDB/GNUDB format ("freedbID count offset1 ... offsetN seconds").
+//   - FirstTrack, LastTrack (int): The track number range.
+//   - LeadOut (int): The lead-out offset in frames.
+//   - Offsets ([]int): The start offset of each track in frames.
+type ReportToc struct {
+	MusicBrainz string `json:"musicbrainz"`
+	Freedb      string `json:"freedb"`
+	FirstTrack  int    `json:"firstTrack"`
+	LastTrack   int    `json:"lastTrack"`
+	LeadOut     int    `json:"leadOut"`
+	Offsets     []int  `json:"offsets"`
+}
+
+// ReportRelease is the metadata of a disc.
+//
+// Fields:
+//   - MusicBrainzID (string): The MusicBrainz release ID, if known.
+//   - ReleaseGroupID (string): The MusicBrainz release group ID, if known.
+//   - Artist, Title, ReleaseDate, Genre (string): The album metadata.
+//   - Barcode (string): The UPC/EAN barcode, if known.
+//   - Tracks ([]ReportTrack): The tracks, in disc order.
+type ReportRelease struct {
+	MusicBrainzID  string        `json:"musicbrainzId,omitempty"`
+	ReleaseGroupID string        `json:"releaseGroupId,omitempty"`
+	Artist         string        `json:"artist"`
+	Title          string        `json:"title"`
+	ReleaseDate    string        `json:"releaseDate,omitempty"`
+	Genre          string        `json:"genre,omitempty"`
+	Barcode        string        `json:"barcode,omitempty"`
+	Tracks         []ReportTrack `json:"tracks"`
+}
+
+// ReportTrack is a track of a disc. The offset and length are only set when the TOC is known.
+//
+// Fields:
+//   - Number (int): The track number.
+//   - Title (string): The track title.
+//   - Artist (string): The track artist, the album artist.
+//   - Songwriter (string): The track songwriters, if known.
+//   - ISRC (string): The track ISRC, if known.
+//   - Offset (int): The start offset in frames.
+//   - Frames (int): The length in frames.
+//   - Duration (float64): The length in seconds.
+type ReportTrack struct {
+	Number     int     `json:"number"`
+	Title      string  `json:"title"`
+	Artist     string  `json:"artist"`
+	Songwriter string  `json:"songwriter,omitempty"`
+	ISRC       string  `json:"isrc,omitempty"`
+	Offset     int     `json:"offset,omitempty"`
+	Frames     int     `json:"frames,omitempty"`
+	Duration   float64 `json:"duration,omitempty"`
+}
+
+// ReportCandidate is the outcome of a provider lookup.
+//
+// Fields:
+//   - Source (string): The provider name.
+//   - Release (*ReportRelease): The metadata found, nil on error.
+//   - Error (string): The lookup error, if any.
+type ReportCandidate struct {
+	Source  string         `json:"source"`
+	Release *ReportRelease `json:"release,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// ReportCover is the front cover art of a disc.
+//
+// Fields:
+//   - Path (string): The path or cache.FileRef of the image.
+//   - Source (string): The cover source it comes from, if known.
+type ReportCover struct {
+	Path   string `json:"path"`
+	Source string `json:"source,omitempty"`
+}
+
+// NewReport converts a Result, and the error of the generation if any, to a Report.
+//
+// Parameters:
+//   - result: The result returned by Generate or Lookup, possibly partial.
+//   - err: The error returned along with result, or nil.
+//
+// Returns:
+//   - Report: The JSON representation of the result.
+func NewReport(result Result, err error) Report {
+	report := Report{
+		Version:    ReportVersion,
+		Mode:       result.Mode.String(),
+		DiscID:     result.DiscID,
+		FreedbID:   result.FreedbID,
+		Source:     result.Source,
+		Release:    newReportRelease(result.DiscInfo, result.Toc),
+		Candidates: []ReportCandidate{},
+		Files:      []string{},
+		CacheHit:   result.CacheHit,
+		Stale:      result.Stale,
+		Refreshing: result.Refreshing,
+	}
+	if result.Toc != nil {
+		report.Toc = &ReportToc{
+			MusicBrainz: result.Toc.MusicBrainzString(),
+			Freedb:      result.Toc.GnuString(),
+			FirstTrack:  result.Toc.FirstTrack,
+			LastTrack:   result.Toc.LastTrack,
+			LeadOut:     result.Toc.LeadOut,
+			Offsets:     result.Toc.Offsets,
+		}
+		if report.FreedbID == "" {
+			report.FreedbID = result.Toc.FreedbID()
+		}
+	}
+	for _, candidate := range result.Candidates {
+		reportCandidate := ReportCandidate{Source: candidate.Source}
+		if candidate.Err != nil {
+			reportCandidate.Error = candidate.Err.Error()
+		} else {
+			reportCandidate.Release = newReportRelease(candidate.DiscInfo, result.Toc)
+		}
+		report.Candidates = append(report.Candidates, reportCandidate)
+	}
+	report.Files = append(report.Files, result.Files...)
+	if result.CoverPath != "" {
+		report.Cover = &ReportCover{Path: result.CoverPath, Source: result.CoverSource}
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// WriteJSON writes the report as indented JSON followed by a newline.
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// newReportRelease converts disc metadata to a ReportRelease, with the track lengths from toc if not nil.
+func newReportRelease(discInfo *types.DiscInfo, toc *utils.Toc) *ReportRelease {
+	if discInfo == nil {
+		return nil
+	}
+	release := &ReportRelease{
+		MusicBrainzID:  discInfo.ID,
+		ReleaseGroupID: discInfo.ReleaseGroupID,
+		Artist:         discInfo.Artist,
+		Title:          discInfo.Title,
+		ReleaseDate:    discInfo.ReleaseDate,
+		Genre:          discInfo.Genre,
+		Barcode:        discInfo.Barcode,
+		Tracks:         make([]ReportTrack, len(discInfo.Tracks)),
+	}
+	first := 1
+	if toc != nil {
+		first = toc.FirstTrack
+	}
+	for i, title := range discInfo.Tracks {
+		track := ReportTrack{
+			Number:     first + i,
+			Title:      title,
+			Artist:     discInfo.Artist,
+			Songwriter: trackField(discInfo.Songwriters, i),
+			ISRC:       trackField(discInfo.ISRCs, i),
+		}
+		if toc != nil && i < len(toc.Offsets) {
+			track.Offset = toc.Offsets[i]
+			track.Frames = toc.TrackFrames(track.Number)
+			track.Duration = toc.TrackDuration(track.Number).Seconds()
+		}
+		release.Tracks[i] = track
+	}
+	return release
+}
@@ -0,0 +1,72 @@
+package cue
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+func TestReport(t *testing.T) {
+	info, toc := writerDisc(t)
+	info.ID = "rel"
+	info.ReleaseGroupID = "group"
+	tests := []struct {
+		golden string
+		result Result
+		err    error
+	}{
+		{golden: "report.json", result: Result{
+			Mode:        ModeDisc,
+			DiscID:      toc.MusicBrainzDiscID(),
+			Toc:         toc,
+			DiscInfo:    info,
+			Source:      "musicbrainz",
+			Candidates:  []Candidate{{Source: "musicbrainz", DiscInfo: info}, {Source: "gnudb", Err: ErrNotFound}},
+			Files:       []string{"/cache/disc/playlist.cue", "/cache/disc/playlist.m3u8"},
+			CoverPath:   "/cache/disc/front.jpg",
+			CoverSource: "coverartarchive",
+			Stale:       true,
+			Refreshing:  true,
+		}},
+		{golden: "report-notoc.json", result: Result{
+			Mode:     ModeDiscID,
+			DiscID:   "disc",
+			FreedbID: "0a000001",
+			DiscInfo: &types.DiscInfo{Artist: "Artist", Title: "Album", Tracks: []string{"One"}},
+			Source:   SourceManual,
+			Files:    []string{"/cache/disc/playlist.cue"},
+			CacheHit: true,
+		}},
+		{golden: "report-error.json", result: Result{
+			Mode:       ModeToc,
+			DiscID:     toc.MusicBrainzDiscID(),
+			Toc:        toc,
+			Candidates: []Candidate{{Source: "gnudb", Err: ErrNotFound}},
+		}, err: fmt.Errorf("%w: %s", ErrNotFound, toc.MusicBrainzDiscID())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewReport(tt.result, tt.err).WriteJSON(&buf); err != nil {
+				t.Fatalf("WriteJSON() error = %v", err)
+			}
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("WriteJSON() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package cue
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+var (
+	// ErrDiscIDRequiresRelease is returned when a disc ID override is given without a MusicBrainz release.
+	ErrDiscIDRequiresRelease = errors.New("--disc-id option requires --musicbrainz to be set")
+	// ErrDiscIDWithToc is returned when both a disc ID and a TOC override are given.
+	ErrDiscIDWithToc = errors.New("--disc-id and --toc options are mutually exclusive")
+	// ErrFromDriveConflict is returned when reading the drive is forced along with a disc ID or TOC override.
+	ErrFromDriveConflict = errors.New("--from-drive cannot be combined with --disc-id or --toc")
+	// ErrMediumRequiresRelease is returned when a medium is selected without a MusicBrainz release.
+	ErrMediumRequiresRelease = errors.New("--medium option requires --musicbrainz to be set")
+	// ErrAmbiguousRelease is returned when the disc of a forced release of several discs, or with
+	// several disc IDs, cannot be determined.
+	ErrAmbiguousRelease = errors.New("the MusicBrainz release has several discs")
+	// ErrNoDevice is returned when the disc must be read from a drive but no device is set.
+	ErrNoDevice = errors.New("no device to read the disc from")
+)
+
+// Mode describes where the disc identity and the metadata of a generation come from.
+type Mode int
+
+const (
+	// ModeDisc reads the TOC from the drive and looks the disc up on GNUDB and MusicBrainz.
+	ModeDisc Mode = iota
+	// ModeDiscRelease reads the TOC from the drive and uses the given MusicBrainz release.
+	ModeDiscRelease
+	// ModeToc uses the provided TOC and looks the disc up on GNUDB and MusicBrainz.
+	ModeToc
+	// ModeTocRelease uses the provided TOC and the given MusicBrainz release.
+	ModeTocRelease
+	// ModeDiscID uses the provided disc ID as cache key and the given MusicBrainz release.
+	ModeDiscID
+	// ModeRelease uses the given MusicBrainz release and derives the TOC from its attached disc IDs,
+	// so no disc needs to be inserted. Falls back to reading the drive if the release has no disc ID.
+	ModeRelease
+)
+
+// String returns a human readable name for the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeDisc:
+		return "disc"
+	case ModeDiscRelease:
+		return "disc+release"
+	case ModeToc:
+		return "toc"
+	case ModeTocRelease:
+		return "toc+release"
+	case ModeDiscID:
+		return "disc-id+release"
+	case ModeRelease:
+		return "release"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// Request holds the parameters of a CUE file generation.
+//
+// Fields:
+//   - Device (string): The drive to read the disc from when neither DiscID nor Toc is set.
+//   - DiscID (string): Overrides the disc ID used as cache key. Requires MusicBrainzID.
+//   - MusicBrainzID (string): Forces the MusicBrainz release used for metadata.
+//   - Toc (string): Overrides the drive TOC, in MusicBrainz format ("first last leadout offset1 ... offsetN").
+//   - FromDrive (bool): Reads the TOC from Device even when MusicBrainzID alone could provide it.
+//   - Medium (int): Selects the disc of a MusicBrainz release of several discs, by position from 1.
+//     Zero selects the disc matching the TOC, or the only one.
+//   - Overwrite (bool): Forces regenerating the CUE file even if it exists.
+type Request struct {
+	Device        string
+	DiscID        string
+	MusicBrainzID string
+	Toc           string
+	FromDrive     bool
+	Medium        int
+	Overwrite     bool
+}
+
+// Validate checks that the combination of request parameters is supported and returns the resulting mode.
+//
+// Returns:
+//   - Mode: The generation mode selected by the request.
+//   - error: An error if the parameters are inconsistent; nil otherwise.
+func (r Request) Validate() (Mode, error) {
+	if r.FromDrive && (r.DiscID != "" || r.Toc != "") {
+		return 0, ErrFromDriveConflict
+	}
+	if r.Medium < 0 {
+		return 0, fmt.Errorf("invalid medium %d", r.Medium)
+	}
+	if r.Medium > 0 && r.MusicBrainzID == "" {
+		return 0, ErrMediumRequiresRelease
+	}
+
+	if r.DiscID != "" {
+		if r.MusicBrainzID == "" {
+			return 0, ErrDiscIDRequiresRelease
+		}
+		if r.Toc != "" {
+			return 0, ErrDiscIDWithToc
+		}
+		if filepath.Base(r.DiscID) != r.DiscID || r.DiscID == "." || r.DiscID == ".." {
+			return 0, fmt.Errorf("invalid disc ID %q", r.DiscID)
+		}
+		return ModeDiscID, nil
+	}
+
+	if r.Toc != "" {
+		if _, err := utils.ParseMusicBrainzToc(r.Toc); err != nil {
+			return 0, err
+		}
+		if r.MusicBrainzID != "" {
+			return ModeTocRelease, nil
+		}
+		return ModeToc, nil
+	}
+
+	if r.MusicBrainzID != "" && !r.FromDrive {
+		return ModeRelease, nil
+	}
+	if r.Device == "" {
+		return 0, ErrNoDevice
+	}
+	if r.MusicBrainzID != "" {
+		return ModeDiscRelease, nil
+	}
+	return ModeDisc, nil
+}
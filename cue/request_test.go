@@ -0,0 +1,99 @@
+package cue
+
+import (
+	"errors"
+	"testing"
+)
+
+const testToc = "1 3 100000 150 30000 60000"
+
+func TestRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     Request
+		mode    Mode
+		wantErr error
+	}{
+		{name: "device", req: Request{Device: "/dev/sr0"}, mode: ModeDisc},
+		{name: "device and release", req: Request{Device: "/dev/sr0", MusicBrainzID: "rel", FromDrive: true}, mode: ModeDiscRelease},
+		{name: "release", req: Request{MusicBrainzID: "rel"}, mode: ModeRelease},
+		{name: "release ignores device", req: Request{Device: "/dev/sr0", MusicBrainzID: "rel"}, mode: ModeRelease},
+		{name: "toc", req: Request{Toc: testToc}, mode: ModeToc},
+		{name: "toc ignores device", req: Request{Device: "/dev/sr0", Toc: testToc}, mode: ModeToc},
+		{name: "toc and release", req: Request{Toc: testToc, MusicBrainzID: "rel"}, mode: ModeTocRelease},
+		{name: "disc ID and release", req: Request{DiscID: "abc", MusicBrainzID: "rel"}, mode: ModeDiscID},
+		{name: "medium", req: Request{MusicBrainzID: "rel", Medium: 2}, mode: ModeRelease},
+		{name: "disc ID and release ignore device", req: Request{Device: "/dev/sr0", DiscID: "abc", MusicBrainzID: "rel"}, mode: ModeDiscID},
+
+		{name: "no device", req: Request{}, wantErr: ErrNoDevice},
+		{name: "from drive without device", req: Request{MusicBrainzID: "rel", FromDrive: true}, wantErr: ErrNoDevice},
+		{name: "disc ID alone", req: Request{Device: "/dev/sr0", DiscID: "abc"}, wantErr: ErrDiscIDRequiresRelease},
+		{name: "disc ID and toc", req: Request{DiscID: "abc", MusicBrainzID: "rel", Toc: testToc}, wantErr: ErrDiscIDWithToc},
+		{name: "disc ID and toc without release", req: Request{DiscID: "abc", Toc: testToc}, wantErr: ErrDiscIDRequiresRelease},
+		{name: "from drive and disc ID", req: Request{Device: "/dev/sr0", DiscID: "abc", MusicBrainzID: "rel", FromDrive: true}, wantErr: ErrFromDriveConflict},
+		{name: "medium without release", req: Request{Device: "/dev/sr0", Medium: 2}, wantErr: ErrMediumRequiresRelease},
+		{name: "from drive and toc", req: Request{Device: "/dev/sr0", Toc: testToc, FromDrive: true}, wantErr: ErrFromDriveConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, err := tt.req.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && mode != tt.mode {
+				t.Errorf("Validate() mode = %v, want %v", mode, tt.mode)
+			}
+		})
+	}
+}
+
+func TestRequestValidateInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+	}{
+		{name: "malformed toc", req: Request{Toc: "1 3 100000"}},
+		{name: "disc ID with separator", req: Request{DiscID: "../abc", MusicBrainzID: "rel"}},
+		{name: "disc ID dot dot", req: Request{DiscID: "..", MusicBrainzID: "rel"}},
+		{name: "negative medium", req: Request{MusicBrainzID: "rel", Medium: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.req.Validate(); err == nil {
+				t.Errorf("Validate() error = nil, want an error")
+			}
+		})
+	}
+}
+
+// TestOptionsRequest checks that GenerateWithOptions takes the disc ID before the release, which
+// the CLI used to pass swapped.
+func TestOptionsRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		discID        string
+		musicbrainzID string
+		mode          Mode
+		wantErr       error
+	}{
+		{name: "disc only", mode: ModeDisc},
+		{name: "release reads the drive", musicbrainzID: "rel", mode: ModeDiscRelease},
+		{name: "disc ID and release", discID: "abc", musicbrainzID: "rel", mode: ModeDiscID},
+		{name: "disc ID alone", discID: "abc", wantErr: ErrDiscIDRequiresRelease},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := optionsRequest("/dev/sr0", tt.discID, tt.musicbrainzID, true)
+			if req.DiscID != tt.discID || req.MusicBrainzID != tt.musicbrainzID || !req.Overwrite {
+				t.Fatalf("optionsRequest() = %+v, arguments not kept in place", req)
+			}
+			mode, err := req.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && mode != tt.mode {
+				t.Errorf("Validate() mode = %v, want %v", mode, tt.mode)
+			}
+		})
+	}
+}
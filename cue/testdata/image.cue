@@ -0,0 +1,20 @@
+CATALOG 0074640911611
+PERFORMER "Simon & 'Garfunkel'"
+TITLE "Bridge <Over> Troubled\Water"
+FILE "disc.wav" WAVE
+  TRACK 01 AUDIO
+    TITLE "Bridge 'Over'"
+    PERFORMER "Simon & 'Garfunkel'"
+    SONGWRITER "Paul Simon"
+    ISRC USSM17000001
+    PREGAP 00:02:00
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "El Condor Pasa"
+    PERFORMER "Simon & 'Garfunkel'"
+    INDEX 01 05:00:00
+  TRACK 03 AUDIO
+    TITLE "Cecilia\"
+    PERFORMER "Simon & 'Garfunkel'"
+    SONGWRITER "Paul Simon"
+    INDEX 01 10:00:00
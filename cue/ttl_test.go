@@ -0,0 +1,164 @@
+package cue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// fakeProvider knows every disc, naming the album after the number of lookups.
+type fakeProvider struct {
+	mu      sync.Mutex
+	lookups int
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Lookup(ctx context.Context, toc *utils.Toc) (*types.DiscInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lookups++
+	return &types.DiscInfo{Artist: "Artist", Title: fmt.Sprintf("Album %d", p.lookups), Tracks: []string{"1", "2", "3"}}, nil
+}
+
+// count returns the number of lookups.
+func (p *fakeProvider) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lookups
+}
+
+// noCoverSource has no cover art.
+type noCoverSource struct{}
+
+func (noCoverSource) Name() string {
+	return "none"
+}
+
+func (noCoverSource) Fetch(ctx context.Context, query CoverQuery) ([]CoverArt, error) {
+	return nil, ErrNoCoverArt
+}
+
+// testClock is a settable clock.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTTLGenerator returns a generator looking discs up with provider, under the clock and TTL policy.
+func newTTLGenerator(t *testing.T, provider Provider, clock *testClock, policy cache.TTLPolicy, staleWhileRevalidate bool) *Generator {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	g, err := NewGenerator(&config.Config{CacheLocation: t.TempDir(), Formats: []string{"cue"}, Logger: logger},
+		WithProviders(provider),
+		WithCoverSources(noCoverSource{}),
+		WithCacheTTL(policy),
+		WithStaleWhileRevalidate(staleWhileRevalidate),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.now = clock.Now
+	return g
+}
+
+// generateToc runs a TOC request, failing the test on error.
+func generateToc(t *testing.T, g *Generator) Result {
+	t.Helper()
+	result, err := g.Generate(context.Background(), Request{Toc: testToc})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	return result
+}
+
+func TestGenerateTTL(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	provider := &fakeProvider{}
+	g := newTTLGenerator(t, provider, clock, cache.TTLPolicy{"fake": time.Hour}, false)
+
+	generateToc(t, g)
+	clock.Advance(time.Hour)
+	if result := generateToc(t, g); !result.CacheHit || result.Stale || provider.count() != 1 {
+		t.Errorf("within the TTL: CacheHit = %v, Stale = %v, lookups = %d, want a fresh cache hit", result.CacheHit, result.Stale, provider.count())
+	}
+
+	clock.Advance(time.Second)
+	result := generateToc(t, g)
+	if result.CacheHit || provider.count() != 2 || result.DiscInfo.Title != "Album 2" {
+		t.Errorf("after the TTL: CacheHit = %v, lookups = %d, title %q, want the disc looked up again", result.CacheHit, provider.count(), result.DiscInfo.Title)
+	}
+	metadata, err := cache.GetMetadata(g.Store(), result.DiscID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !metadata.FetchedAt.Equal(clock.Now()) {
+		t.Errorf("FetchedAt = %v, want the clock time %v", metadata.FetchedAt, clock.Now())
+	}
+}
+
+func TestGenerateStaleWhileRevalidate(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	provider := &fakeProvider{}
+	g := newTTLGenerator(t, provider, clock, cache.TTLPolicy{cache.DefaultTTLKey: time.Hour}, true)
+
+	generateToc(t, g)
+	clock.Advance(2 * time.Hour)
+	result := generateToc(t, g)
+	if !result.CacheHit || !result.Stale || !result.Refreshing || result.DiscInfo.Title != "Album 1" {
+		t.Errorf("stale: CacheHit = %v, Stale = %v, Refreshing = %v, title %q, want the stale disc while refreshing",
+			result.CacheHit, result.Stale, result.Refreshing, result.DiscInfo.Title)
+	}
+	g.Wait()
+	if provider.count() != 2 {
+		t.Errorf("lookups after the refresh = %d, want 2", provider.count())
+	}
+
+	result = generateToc(t, g)
+	if !result.CacheHit || result.Stale || result.DiscInfo.Title != "Album 2" {
+		t.Errorf("after the refresh: CacheHit = %v, Stale = %v, title %q, want the refreshed disc", result.CacheHit, result.Stale, result.DiscInfo.Title)
+	}
+}
+
+func TestGenerateManualNeverExpires(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	provider := &fakeProvider{}
+	g := newTTLGenerator(t, provider, clock, cache.TTLPolicy{cache.DefaultTTLKey: time.Hour}, false)
+
+	result := generateToc(t, g)
+	edited := *result.DiscInfo
+	edited.Title = "Edited"
+	if _, err := g.UpdateDiscInfo(result.DiscID, edited); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(365 * 24 * time.Hour)
+	result = generateToc(t, g)
+	if !result.CacheHit || result.Stale || result.Source != SourceManual || provider.count() != 1 {
+		t.Errorf("manual disc after a year: CacheHit = %v, Stale = %v, Source = %q, lookups = %d, want a fresh cache hit",
+			result.CacheHit, result.Stale, result.Source, provider.count())
+	}
+}
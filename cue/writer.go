@@ -0,0 +1,165 @@
+package cue
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// Writer renders disc metadata into a playlist format.
+type Writer interface {
+	// FileName returns the name of the file written in the disc cache folder.
+	FileName() string
+	// Write renders the playlist. toc may be nil when only a disc ID is known.
+	Write(w io.Writer, info *types.DiscInfo, toc *utils.Toc) error
+}
+
+// Playlist formats selectable by name.
+const (
+	FormatCUE  = "cue"
+	FormatM3U8 = "m3u8"
+	FormatXSPF = "xspf"
+	FormatPLS  = "pls"
+	// FormatBurnCUE is a CUE sheet for burning a copy of the disc from its image.
+	FormatBurnCUE = "burn-cue"
+	// FormatCdrdao is a cdrdao TOC file for burning a copy of the disc from its image.
+	FormatCdrdao = "toc"
+)
+
+// DefaultImageFile is the disc image referenced by the burning formats when none is given.
+const DefaultImageFile = "disc.wav"
+
+// ErrTocRequired is returned by the writers needing the disc TOC when it is unknown.
+var ErrTocRequired = errors.New("the disc TOC is required")
+
+// Formats lists the playlist formats supported by NewWriter.
+var Formats = []string{FormatCUE, FormatM3U8, FormatXSPF, FormatPLS, FormatBurnCUE, FormatCdrdao}
+
+// NewWriter returns the writer of a playlist format.
+//
+// Parameters:
+//   - format: One of Formats.
+//   - imageFile: The disc image referenced by the burning formats, DefaultImageFile if empty.
+//
+// Returns:
+//   - Writer: The playlist writer.
+//   - error: An error if the format is unknown.
+func NewWriter(format, imageFile string) (Writer, error) {
+	if imageFile == "" {
+		imageFile = DefaultImageFile
+	}
+	switch format {
+	case FormatBurnCUE:
+		return BurnCueWriter{ImageFile: imageFile}, nil
+	case FormatCdrdao:
+		return CdrdaoWriter{ImageFile: imageFile}, nil
+	case FormatCUE:
+		return CueWriter{}, nil
+	case FormatM3U8:
+		return M3U8Writer{}, nil
+	case FormatXSPF:
+		return XSPFWriter{}, nil
+	case FormatPLS:
+		return PLSWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown playlist format %q: expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// NewWriters returns the writers of several playlist formats, in order.
+//
+// Parameters:
+//   - formats: Names from Formats.
+//   - imageFile: The disc image referenced by the burning formats, DefaultImageFile if empty.
+//
+// Returns:
+//   - []Writer: The playlist writers.
+//   - error: An error if a format is unknown.
+func NewWriters(formats []string, imageFile string) ([]Writer, error) {
+	writers := make([]Writer, 0, len(formats))
+	for _, format := range formats {
+		writer, err := NewWriter(strings.TrimSpace(format), imageFile)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, writer)
+	}
+	return writers, nil
+}
+
+// trackLocation returns the cdda:// URL of the track at index i of the disc info.
+func trackLocation(i int) string {
+	return fmt.Sprintf("cdda:///%d", i+1)
+}
+
+// trackDuration returns the duration of the track at index i of the disc info, false if the TOC is unknown.
+func trackDuration(toc *utils.Toc, i int) (time.Duration, bool) {
+	if toc == nil {
+		return 0, false
+	}
+	duration := toc.TrackDuration(toc.FirstTrack + i)
+	return duration, duration > 0
+}
+
+// CueWriter writes CUE sheets referencing the disc tracks as cdda:// files.
+type CueWriter struct{}
+
+// FileName returns "playlist.cue".
+func (CueWriter) FileName() string {
+	return "playlist.cue"
+}
+
+// Write renders the CUE sheet for info.
+//
+// Parameters:
+//   - w: The destination of the CUE sheet.
+//   - info: Metadata about the disc.
+//   - toc: The disc TOC (unused, tracks are referenced by number).
+//
+// Returns:
+//   - error: Any error encountered while writing.
+func (CueWriter) Write(w io.Writer, info *types.DiscInfo, toc *utils.Toc) error {
+	var content string
+	if info.ReleaseDate != "" {
+		content += fmt.Sprintf("REM DATE \"%s\"\n", info.ReleaseDate)
+	}
+	if info.Genre != "" {
+		content += fmt.Sprintf("REM GENRE \"%s\"\n", info.Genre)
+	}
+	if info.CoverArtPath != "" {
+		content += fmt.Sprintf("REM COVER \"%s\"\n", info.CoverArtPath)
+	}
+	content += fmt.Sprintf("PERFORMER \"%s\"\nTITLE \"%s\"\n", info.Artist, info.Title)
+
+	for i, track := range info.Tracks {
+		content += fmt.Sprintf("FILE \"cdda:///%d\" WAVE\n  TRACK %02d AUDIO\n    TITLE \"%s\"\n",
+			i+1, i+1, track)
+	}
+
+	_, err := io.WriteString(w, content)
+	return err
+}
+
+// renderPlaylist renders info with writer.
+//
+// Parameters:
+//   - writer: The playlist format.
+//   - info: Metadata about the disc.
+//   - toc: The disc TOC, possibly nil.
+//
+// Returns:
+//   - []byte: The playlist content.
+//   - error: Any error encountered while rendering.
+func renderPlaylist(writer Writer, info *types.DiscInfo, toc *utils.Toc) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writer.Write(&buf, info, toc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,220 @@
+package cue
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// update rewrites the golden files of TestWriters and TestReport with the current output: go test ./cue -update
+var update = flag.Bool("update", false, "update the golden files")
+
+// writerDisc is a disc whose metadata need escaping in every format, starting 2 seconds after the
+// standard pregap.
+func writerDisc(t *testing.T) (*types.DiscInfo, *utils.Toc) {
+	t.Helper()
+	toc, err := utils.ParseMusicBrainzToc("1 3 60000 300 22800 45300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.DiscInfo{
+		Artist:       `Simon & "Garfunkel"`,
+		Title:        `Bridge <Over> Troubled\Water`,
+		ReleaseDate:  "1970-01-26",
+		Genre:        "Folk  Rock",
+		Barcode:      "074640911611",
+		CoverArtPath: "/cache/disc/front.jpg",
+		Tracks:       []string{`Bridge "Over"`, "El Condor\nPasa", `Cecilia\`},
+		Songwriters:  []string{"Paul Simon", "", "Paul Simon"},
+		ISRCs:        []string{"us-sm1-70-00001", "invalid", ""},
+	}
+	return info, toc
+}
+
+func TestWriters(t *testing.T) {
+	info, toc := writerDisc(t)
+	tests := []struct {
+		golden string
+		writer Writer
+		toc    *utils.Toc
+	}{
+		{"playlist.m3u8", M3U8Writer{}, toc},
+		{"playlist-notoc.m3u8", M3U8Writer{}, nil},
+		{"playlist.xspf", XSPFWriter{}, toc},
+		{"playlist-notoc.xspf", XSPFWriter{}, nil},
+		{"playlist.pls", PLSWriter{}, toc},
+		{"playlist-notoc.pls", PLSWriter{}, nil},
+		{"image.cue", BurnCueWriter{ImageFile: "disc.wav"}, toc},
+		{"image-bin.cue", BurnCueWriter{ImageFile: "disc.bin"}, toc},
+		{"image-quoted.cue", BurnCueWriter{ImageFile: "my \"disc\"\n.wav"}, toc},
+		{"image.toc", CdrdaoWriter{ImageFile: `my "disc".wav`}, toc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.writer.Write(&buf, info, tt.toc); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestBurnWritersRequireToc(t *testing.T) {
+	info, _ := writerDisc(t)
+	for _, writer := range []Writer{BurnCueWriter{ImageFile: "disc.wav"}, CdrdaoWriter{ImageFile: "disc.wav"}} {
+		if err := writer.Write(&bytes.Buffer{}, info, nil); !errors.Is(err, ErrTocRequired) {
+			t.Errorf("%T.Write() without TOC: error = %v, want ErrTocRequired", writer, err)
+		}
+	}
+}
+
+func TestMSF(t *testing.T) {
+	tests := []struct {
+		frames int
+		want   string
+	}{
+		{0, "00:00:00"},
+		{74, "00:00:74"},
+		{75, "00:01:00"},
+		{150, "00:02:00"},
+		{4500, "01:00:00"},
+		{4500*79 + 75*59 + 74, "79:59:74"},
+		{4500 * 100, "100:00:00"},
+	}
+	for _, tt := range tests {
+		if got := msf(tt.frames); got != tt.want {
+			t.Errorf("msf(%d) = %q, want %q", tt.frames, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogNumber(t *testing.T) {
+	tests := []struct {
+		barcode string
+		want    string
+	}{
+		{"074640911611", "0074640911611"},
+		{"5099902894225", "5099902894225"},
+		{"", ""},
+		{"12345", ""},
+		{"50999028942251", ""},
+		{"50999O2894225", ""},
+		{"5099902-89422", ""},
+	}
+	for _, tt := range tests {
+		if got := catalogNumber(tt.barcode); got != tt.want {
+			t.Errorf("catalogNumber(%q) = %q, want %q", tt.barcode, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeISRC(t *testing.T) {
+	tests := []struct {
+		isrc string
+		want string
+	}{
+		{"USSM17000001", "USSM17000001"},
+		{"us-sm1-70-00001", "USSM17000001"},
+		{"GBAYE6700012", "GBAYE6700012"},
+		{"", ""},
+		{"USSM1700001", ""},
+		{"USSM170000012", ""},
+		{"1SSM17000001", ""},
+		{"USSM1A000001", ""},
+		{"US SM1 70 00001", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeISRC(tt.isrc); got != tt.want {
+			t.Errorf("normalizeISRC(%q) = %q, want %q", tt.isrc, got, tt.want)
+		}
+	}
+}
+
+func TestBurnTracksPregap(t *testing.T) {
+	info := &types.DiscInfo{Artist: "Artist", Tracks: []string{"One", "Two"}}
+	tests := []struct {
+		name  string
+		toc   string
+		want0 burnTrack
+		want1 burnTrack
+	}{
+		{
+			name:  "standard pregap",
+			toc:   "1 2 40000 150 20150",
+			want0: burnTrack{Number: 1, Title: "One", Performer: "Artist", Start: 0, Length: 20000},
+			want1: burnTrack{Number: 2, Title: "Two", Performer: "Artist", Start: 20000, Length: 19850},
+		},
+		{
+			name:  "hidden audio before the first track",
+			toc:   "1 2 40000 450 20450",
+			want0: burnTrack{Number: 1, Title: "One", Performer: "Artist", Pregap: 300, Start: 0, Length: 20000},
+			want1: burnTrack{Number: 2, Title: "Two", Performer: "Artist", Start: 20000, Length: 19550},
+		},
+		{
+			name:  "first track numbered 3",
+			toc:   "3 4 40000 150 20150",
+			want0: burnTrack{Number: 3, Title: "One", Performer: "Artist", Start: 0, Length: 20000},
+			want1: burnTrack{Number: 4, Title: "Two", Performer: "Artist", Start: 20000, Length: 19850},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toc, err := utils.ParseMusicBrainzToc(tt.toc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracks := burnTracks(info, toc)
+			if len(tracks) != 2 || tracks[0] != tt.want0 || tracks[1] != tt.want1 {
+				t.Errorf("burnTracks() = %+v, want [%+v %+v]", tracks, tt.want0, tt.want1)
+			}
+		})
+	}
+}
+
+func TestCdrdaoText(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"plain", `"plain"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`back\slash`, `"back\\slash"`},
+		{`\"`, `"\\\""`},
+		{"two\nlines\t", `"two lines "`},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := cdrdaoText(tt.s); got != tt.want {
+			t.Errorf("cdrdaoText(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestCueText(t *testing.T) {
+	long := string(bytes.Repeat([]byte("é"), cdTextMaxLength+5))
+	if got := cueText(long); len([]rune(got)) != cdTextMaxLength {
+		t.Errorf("cueText() of %d runes = %d runes, want %d", len([]rune(long)), len([]rune(got)), cdTextMaxLength)
+	}
+	if got := cueText("say \"hi\"\r\n"); got != "say 'hi'  " {
+		t.Errorf("cueText() = %q, want the double quotes and line breaks replaced", got)
+	}
+}
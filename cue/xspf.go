@@ -0,0 +1,92 @@
+package cue
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"path/filepath"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// xspfPlaylist is the root element of an XSPF playlist.
+type xspfPlaylist struct {
+	XMLName   xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version   string      `xml:"version,attr"`
+	Title     string      `xml:"title,omitempty"`
+	Creator   string      `xml:"creator,omitempty"`
+	Image     string      `xml:"image,omitempty"`
+	TrackList []xspfTrack `xml:"trackList>track"`
+}
+
+// xspfTrack is a track of an XSPF playlist.
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Album    string `xml:"album,omitempty"`
+	TrackNum int    `xml:"trackNum"`
+	Duration int64  `xml:"duration,omitempty"`
+	Image    string `xml:"image,omitempty"`
+}
+
+// XSPFWriter writes XSPF playlists referencing the disc tracks as cdda:// URLs.
+type XSPFWriter struct{}
+
+// FileName returns "playlist.xspf".
+func (XSPFWriter) FileName() string {
+	return "playlist.xspf"
+}
+
+// Write renders the XSPF playlist for info, with the album, track number, duration in
+// milliseconds when the TOC is known, and cover art of each track.
+//
+// Parameters:
+//   - w: The destination of the playlist.
+//   - info: Metadata about the disc.
+//   - toc: The disc TOC, used for the track durations. May be nil.
+//
+// Returns:
+//   - error: Any error encountered while writing.
+func (XSPFWriter) Write(w io.Writer, info *types.DiscInfo, toc *utils.Toc) error {
+	playlist := xspfPlaylist{
+		Version: "1",
+		Title:   info.Title,
+		Creator: info.Artist,
+		Image:   fileURL(info.CoverArtPath),
+	}
+	for i, title := range info.Tracks {
+		track := xspfTrack{
+			Location: trackLocation(i),
+			Title:    title,
+			Creator:  info.Artist,
+			Album:    info.Title,
+			TrackNum: i + 1,
+			Image:    playlist.Image,
+		}
+		if duration, ok := trackDuration(toc, i); ok {
+			track.Duration = duration.Milliseconds()
+		}
+		playlist.TrackList = append(playlist.TrackList, track)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(playlist); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// fileURL returns the file:// URL of an absolute path, or path itself if it is not absolute.
+func fileURL(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
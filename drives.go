@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/watch"
+)
+
+// errDrivesFailed is returned by generateAll when the generation failed for some drives.
+var errDrivesFailed = errors.New("some drives failed, see their status")
+
+// driveStatus and generateDisc read the status of a drive and generate its disc, replaced by the tests.
+var (
+	driveStatus  = watch.DriveStatus
+	generateDisc = (*cue.Generator).Generate
+)
+
+// generateAll generates the playlists of the disc in each drive of the --all-drives mode, one
+// goroutine per drive sharing the generator, then prints the status of every drive. Drives without
+// audio disc are skipped. With MPDReplace, only the first disc queued on MPD replaces the queue.
+//
+// Returns:
+//   - error: An error if the drives cannot be listed, or the generation failed for some of them.
+func generateAll(cuerConfig *config.Config, generator *cue.Generator) error {
+	devices, err := getDevices(cuerConfig)
+	if err != nil {
+		return err
+	}
+	states := make([]watch.State, len(devices))
+	run := &queueRun{}
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		wg.Add(1)
+		go func(i int, device string) {
+			defer wg.Done()
+			states[i] = generateDrive(cuerConfig, generator, device, run)
+		}(i, device)
+	}
+	wg.Wait()
+	// Let stale-while-revalidate refreshes and hook commands complete before exiting
+	generator.Wait()
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(states); err != nil {
+			return fmt.Errorf("Failed to write output: %w", err)
+		}
+	} else {
+		for _, state := range states {
+			fmt.Println(formatStatus(state))
+		}
+	}
+	for _, state := range states {
+		if state.State == watch.StateError {
+			return errDrivesFailed
+		}
+	}
+	return nil
+}
+
+// generateDrive generates the playlists of the disc in device, then exports or queues it as part of
+// run, see afterGenerate.
+//
+// Returns:
+//   - watch.State: StateEmpty if the drive has no audio disc, else StateReady or StateError with the report.
+func generateDrive(cuerConfig *config.Config, generator *cue.Generator, device string, run *queueRun) watch.State {
+	state := watch.State{Device: device, State: watch.StateEmpty, Since: time.Now()}
+	// Outside Linux the status is unknown: try to read the drive anyway
+	if status, err := driveStatus(device); err == nil && status != watch.StatusAudio {
+		cuerConfig.GetLogger().Info("no audio disc", "device", device, "status", status)
+		return state
+	}
+	req := newRequest(cuerConfig)
+	req.Device = device
+	result, err := generateDisc(generator, context.Background(), req)
+	report := cue.NewReport(result, err)
+	state.State, state.Since, state.Disc = watch.StateReady, time.Now(), &report
+	if err != nil {
+		cuerConfig.GetLogger().Error("failed to generate playlist", "device", device, "error", err)
+		state.State = watch.StateError
+		return state
+	}
+	afterGenerate(cuerConfig, generator, result.DiscID, device, run)
+	return state
+}
+
+// statusPrinter prints the drive states on stdout, one line per change: aligned text, or JSON with
+// --output json. It is safe for concurrent use by the watchers of several drives.
+type statusPrinter struct {
+	mu   sync.Mutex
+	json bool
+}
+
+// print prints a drive state.
+func (p *statusPrinter) print(state watch.State) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.json {
+		fmt.Println(formatStatus(state))
+		return
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(state)
+}
+
+// formatStatus formats a drive state as a status line: the drive, its state and the disc or error.
+func formatStatus(state watch.State) string {
+	line := fmt.Sprintf("%-12s %s", state.Device, state.State)
+	if disc := state.Disc; disc != nil {
+		line = fmt.Sprintf("%-21s", line)
+		switch {
+		case disc.Error != "":
+			line += " " + disc.Error
+		case disc.Release != nil:
+			line += fmt.Sprintf(" %s - %s (%s)", disc.Release.Artist, disc.Release.Title, disc.DiscID)
+		default:
+			line += " " + disc.DiscID
+		}
+	}
+	return line
+}
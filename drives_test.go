@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+	"github.com/b0bbywan/go-disc-cuer/watch"
+)
+
+// unknownToc is a disc testProvider does not know.
+const unknownToc = "1 2 100000 150 30000"
+
+// testProvider knows the discs of three tracks.
+type testProvider struct{}
+
+func (testProvider) Name() string {
+	return "test"
+}
+
+func (testProvider) Lookup(ctx context.Context, toc *utils.Toc) (*types.DiscInfo, error) {
+	if toc.TrackCount() != 3 {
+		return nil, fmt.Errorf("%w: %s", cue.ErrNotFound, toc.MusicBrainzDiscID())
+	}
+	return &types.DiscInfo{Artist: "Artist", Title: "Album", Tracks: []string{"One", "Two", "Three"}}, nil
+}
+
+// noCoverSource has no cover art.
+type noCoverSource struct{}
+
+func (noCoverSource) Name() string {
+	return "none"
+}
+
+func (noCoverSource) Fetch(ctx context.Context, query cue.CoverQuery) ([]cue.CoverArt, error) {
+	return nil, cue.ErrNoCoverArt
+}
+
+// fakeDrives holds a disc per drive, given by its TOC, and checks that the drives are generated
+// concurrently: a generation waits until every drive holding a disc is being generated.
+type fakeDrives struct {
+	tocs       map[string]string
+	mu         sync.Mutex
+	generating int
+	all        chan struct{}
+}
+
+// useFakeDrives replaces the drives until the end of the test by drives holding tocs, keyed by device.
+func useFakeDrives(t *testing.T, tocs map[string]string) {
+	t.Helper()
+	drives := &fakeDrives{tocs: tocs, all: make(chan struct{})}
+	previousStatus, previousGenerate := driveStatus, generateDisc
+	t.Cleanup(func() { driveStatus, generateDisc = previousStatus, previousGenerate })
+	driveStatus, generateDisc = drives.status, drives.generate
+}
+
+func (d *fakeDrives) status(device string) (watch.Status, error) {
+	if _, ok := d.tocs[device]; !ok {
+		return watch.StatusNoDisc, nil
+	}
+	return watch.StatusAudio, nil
+}
+
+func (d *fakeDrives) generate(g *cue.Generator, ctx context.Context, req cue.Request) (cue.Result, error) {
+	d.mu.Lock()
+	d.generating++
+	if d.generating == len(d.tocs) {
+		close(d.all)
+	}
+	d.mu.Unlock()
+	select {
+	case <-d.all:
+	case <-time.After(2 * time.Second):
+		return cue.Result{}, errors.New("drives generated one at a time")
+	}
+	req.Toc = d.tocs[req.Device]
+	return g.Generate(ctx, req)
+}
+
+// fakeMPD accepts connections and answers OK to every command, with a song ID for addid, recording
+// the commands received on every connection in order.
+func fakeMPD(t *testing.T) (address string, commands func() []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	var mu sync.Mutex
+	var received []string
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("OK MPD 0.23.5\n"))
+				scanner := bufio.NewScanner(conn)
+				songs := 0
+				for scanner.Scan() {
+					mu.Lock()
+					received = append(received, scanner.Text())
+					mu.Unlock()
+					switch {
+					case strings.HasPrefix(scanner.Text(), "addid "):
+						songs++
+						conn.Write([]byte("Id: " + strconv.Itoa(songs) + "\nOK\n"))
+					case scanner.Text() == "status":
+						conn.Write([]byte("playlistlength: 3\nOK\n"))
+					default:
+						conn.Write([]byte("OK\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, received...)
+	}
+}
+
+// newTestGenerator returns a generator knowing the discs of testProvider, and its configuration.
+func newTestGenerator(t *testing.T) (*cue.Generator, *config.Config) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cuerConfig := &config.Config{CacheLocation: t.TempDir(), Formats: []string{cue.FormatCUE}, Logger: logger}
+	generator, err := cue.NewGenerator(cuerConfig,
+		cue.WithProviders(testProvider{}),
+		cue.WithCoverSources(noCoverSource{}),
+		cue.WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return generator, cuerConfig
+}
+
+// useDevices makes getDevices return the drives of the devices setting until the end of the test.
+func useDevices(t *testing.T, cuerConfig *config.Config, devices ...string) {
+	t.Helper()
+	previousAll, previousDevice := allDrives, deviceFlag
+	t.Cleanup(func() { allDrives, deviceFlag = previousAll, previousDevice })
+	allDrives, deviceFlag = true, ""
+	cuerConfig.Devices = devices
+}
+
+func TestGenerateDrive(t *testing.T) {
+	tests := []struct {
+		name      string
+		toc       string
+		wantState string
+		wantError bool
+	}{
+		{name: "known disc", toc: "1 3 100000 150 30000 60000", wantState: watch.StateReady},
+		{name: "unknown disc", toc: unknownToc, wantState: watch.StateError, wantError: true},
+		{name: "empty drive", wantState: watch.StateEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tocs := map[string]string{}
+			if tt.toc != "" {
+				tocs["/dev/sr0"] = tt.toc
+			}
+			useFakeDrives(t, tocs)
+			generator, cuerConfig := newTestGenerator(t)
+			state := generateDrive(cuerConfig, generator, "/dev/sr0", &queueRun{})
+			if state.Device != "/dev/sr0" || state.State != tt.wantState {
+				t.Fatalf("generateDrive() = %s %s, want %s", state.Device, state.State, tt.wantState)
+			}
+			if tt.wantState == watch.StateEmpty {
+				if state.Disc != nil {
+					t.Errorf("generateDrive() of an empty drive reported %+v", state.Disc)
+				}
+				return
+			}
+			if state.Disc == nil || state.Disc.DiscID == "" || (state.Disc.Error != "") != tt.wantError {
+				t.Errorf("generateDrive() report = %+v, want error %v", state.Disc, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestGenerateAll(t *testing.T) {
+	address, commands := fakeMPD(t)
+	generator, cuerConfig := newTestGenerator(t)
+	cuerConfig.MPDOnGenerate, cuerConfig.MPDAddress, cuerConfig.MPDMode = true, address, "add"
+	cuerConfig.MPDReplace, cuerConfig.MPDPlay = true, true
+	useDevices(t, cuerConfig, "/dev/sr0", "/dev/sr1", "/dev/sr2")
+	// Two discs of three tracks, and an empty drive
+	useFakeDrives(t, map[string]string{"/dev/sr0": "1 3 100000 150 30000 60000", "/dev/sr1": "1 3 120000 150 40000 80000"})
+
+	if err := generateAll(cuerConfig, generator); err != nil {
+		t.Fatalf("generateAll() error = %v", err)
+	}
+	received := commands()
+	var clears, plays, statuses int
+	for _, command := range received {
+		switch {
+		case command == "clear":
+			clears++
+		case strings.HasPrefix(command, "play "):
+			plays++
+		case command == "status":
+			statuses++
+		}
+	}
+	// The first disc queued replaces the queue and starts playback, the second one is appended
+	if len(received) == 0 || received[0] != "clear" || clears != 1 || plays != 1 || statuses != 1 {
+		t.Errorf("MPD commands = %q, want a single clear first, then one play and one append", received)
+	}
+	for _, device := range []string{"/dev/sr0", "/dev/sr1"} {
+		if !strings.Contains(strings.Join(received, "\n"), "cdda://"+device+"/1") {
+			t.Errorf("MPD commands = %q, want the tracks of %s", received, device)
+		}
+	}
+}
+
+func TestGenerateAllFailure(t *testing.T) {
+	generator, cuerConfig := newTestGenerator(t)
+	useDevices(t, cuerConfig, "/dev/sr0", "/dev/sr1")
+	useFakeDrives(t, map[string]string{"/dev/sr0": "1 3 100000 150 30000 60000", "/dev/sr1": unknownToc})
+	if err := generateAll(cuerConfig, generator); !errors.Is(err, errDrivesFailed) {
+		t.Errorf("generateAll() error = %v, want errDrivesFailed", err)
+	}
+}
@@ -2,8 +2,11 @@ package gnudb
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
@@ -20,6 +23,9 @@ const (
 	keyTrack = "TTITLE"
 )
 
+// ErrNotFound is returned when GNUDB has no exact match for the disc.
+var ErrNotFound = errors.New("no exact match found in GNUDB")
+
 type gnuConfig struct {
 	GnuHello string
 	GnudbURL string
@@ -49,6 +55,41 @@ func newGnuConfig(cuerConfig *config.Config) (*gnuConfig, error) {
 	}, nil
 }
 
+// Client queries GNUDB with a given HTTP client.
+type Client struct {
+	httpClient *http.Client
+	gnuConfig  *gnuConfig
+	logger     *slog.Logger
+}
+
+// NewClient creates a GNUDB client from the application configuration.
+//
+// Parameters:
+//   - cuerConfig: The Config instance containing GNUDB settings.
+//   - httpClient (*http.Client): The HTTP client used for requests. A new client is used if nil.
+//   - logger (*slog.Logger): The logger for request tracing. Uses the configuration logger if nil.
+//
+// Returns:
+//   - *Client: A GNUDB client.
+//   - error: An error if the configuration lacks GNUDB settings.
+func NewClient(cuerConfig *config.Config, httpClient *http.Client, logger *slog.Logger) (*Client, error) {
+	gnuConfig, err := newGnuConfig(cuerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid GNUConfig: %w", err)
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	if logger == nil {
+		logger = cuerConfig.GetLogger()
+	}
+	return &Client{
+		httpClient: httpClient,
+		gnuConfig:  gnuConfig,
+		logger:     logger,
+	}, nil
+}
+
 // FetchDiscInfo queries GNUDB to retrieve metadata about a disc.
 //
 // Parameters:
@@ -59,19 +100,32 @@ func newGnuConfig(cuerConfig *config.Config) (*gnuConfig, error) {
 //   - *types.DiscInfo: Metadata about the disc.
 //   - error: Any error encountered during the operation.
 func FetchDiscInfo(cuerConfig *config.Config, gnuToc string) (*types.DiscInfo, error) {
-	gnuConfig, err := newGnuConfig(cuerConfig)
+	client, err := NewClient(cuerConfig, nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("Invalid GNUConfig: %w", err)
+		return nil, err
 	}
-	client := &http.Client{}
+	return client.FetchDiscInfo(context.Background(), gnuToc)
+}
 
+// FetchDiscInfo queries GNUDB to retrieve metadata about a disc.
+//
+// Parameters:
+//   - ctx: The context of the requests.
+//   - gnuToc: The table of contents (TOC) of the disc, with '+' separated fields.
+//
+// Returns:
+//   - *types.DiscInfo: Metadata about the disc.
+//   - error: Any error encountered during the operation.
+func (c *Client) FetchDiscInfo(ctx context.Context, gnuToc string) (*types.DiscInfo, error) {
 	// First, query GNUDB for a match
-	gnudbID, err := queryGNUDB(client, gnuConfig, gnuToc)
+	c.logger.Debug("GNUDB query", "gnu_toc", gnuToc)
+	gnudbID, err := queryGNUDB(ctx, c.httpClient, c.gnuConfig, gnuToc)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to query %s on gnuDB: %w", gnuToc, err)
 	}
 	// Fetch the full metadata from GNDB
-	discInfo, err := fetchFullMetadata(client, gnuConfig, gnudbID)
+	c.logger.Debug("GNUDB read", "gnudb_id", gnudbID)
+	discInfo, err := fetchFullMetadata(ctx, c.httpClient, c.gnuConfig, gnudbID)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to fetch %s (%s) metadata on gnuDB: %w", gnudbID, gnuToc, err)
 	}
@@ -82,6 +136,7 @@ func FetchDiscInfo(cuerConfig *config.Config, gnuToc string) (*types.DiscInfo, e
 // queryGNUDB performs the initial query to GNUDB to find a matching record for the given TOC.
 //
 // Parameters:
+//   - ctx (context.Context): The context of the request.
 //   - client (*http.Client): HTTP client for making requests.
 //   - gnuConfig (*gnuConfig): The gnuConfig instance containing GNUDB settings.
 //   - gnuToc (string): The disc's TOC, formatted for GNUDB queries.
@@ -89,12 +144,12 @@ func FetchDiscInfo(cuerConfig *config.Config, gnuToc string) (*types.DiscInfo, e
 // Returns:
 //   - string: The GNUDB ID of the matching record.
 //   - error: An error if the query fails, the response cannot be read, or no match is found.
-func queryGNUDB(client *http.Client, gnuConfig *gnuConfig, gnuToc string) (string, error) {
+func queryGNUDB(ctx context.Context, client *http.Client, gnuConfig *gnuConfig, gnuToc string) (string, error) {
 	if gnuConfig == nil {
 		return "", fmt.Errorf("Failed to query gnudb: empty config")
 	}
 	queryURL := fmt.Sprintf("%s?cmd=cddb+query+%s&hello=%s&proto=6", gnuConfig.GnudbURL, gnuToc, gnuConfig.GnuHello)
-	resp, err := makeGnuRequest(client, queryURL)
+	resp, err := makeGnuRequest(ctx, client, queryURL)
 	if err != nil {
 		return "", fmt.Errorf("Failed GnuRequest (%s): %w", queryURL, err)
 	}
@@ -105,7 +160,7 @@ func queryGNUDB(client *http.Client, gnuConfig *gnuConfig, gnuToc string) (strin
 		return "", fmt.Errorf("Failed to read response body: %w", err)
 	}
 	if !strings.Contains(string(body), "Found exact matches") {
-		return "", fmt.Errorf("No exact match found in GNUDB: %s", string(body))
+		return "", fmt.Errorf("%w: %s", ErrNotFound, string(body))
 	}
 	return extractGnuDBID(string(body))
 }
@@ -129,6 +184,7 @@ func extractGnuDBID(response string) (string, error) {
 // fetchFullMetadata retrieves detailed disc metadata from GNUDB using the record's ID.
 //
 // Parameters:
+//   - ctx (context.Context): The context of the request.
 //   - client (*http.Client): HTTP client for making requests.
 //   - gnuConfig (*gnuConfig): The gnuConfig instance containing GNUDB settings.
 //   - gnudbID (string): The ID of the record in GNUDB.
@@ -136,12 +192,12 @@ func extractGnuDBID(response string) (string, error) {
 // Returns:
 //   - *types.DiscInfo: A struct containing the disc's metadata (artist, title, tracks, etc.).
 //   - error: An error if the metadata cannot be retrieved or parsed.
-func fetchFullMetadata(client *http.Client, gnuConfig *gnuConfig, gnudbID string) (*types.DiscInfo, error) {
+func fetchFullMetadata(ctx context.Context, client *http.Client, gnuConfig *gnuConfig, gnudbID string) (*types.DiscInfo, error) {
 	if gnuConfig == nil {
 		return nil, fmt.Errorf("Failed to fetch gnudb metadata: empty config")
 	}
 	readURL := fmt.Sprintf("%s?cmd=cddb+read+data+%s&hello=%s&proto=6", gnuConfig.GnudbURL, gnudbID, gnuConfig.GnuHello)
-	resp, err := makeGnuRequest(client, readURL)
+	resp, err := makeGnuRequest(ctx, client, readURL)
 	if err != nil {
 		return nil, fmt.Errorf("Failed GnuRequest (%s): %w", readURL, err)
 	}
@@ -195,15 +251,16 @@ func parseGNUDBResponse(body io.Reader) (*types.DiscInfo, error) {
 // makeGnuRequest performs an HTTP GET request with a predefined User-Agent header.
 //
 // Parameters:
+//   - ctx (context.Context): The context of the request.
 //   - client (*http.Client): HTTP client for making requests.
 //   - url (string): The URL to send the GET request to.
 //
 // Returns:
 //   - *http.Response: The HTTP response object.
 //   - error: An error if the request fails.
-func makeGnuRequest(client *http.Client, url string) (*http.Response, error) {
+func makeGnuRequest(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
 	userAgent := "curl/8.9.1"
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1,10 +1,12 @@
 module github.com/b0bbywan/go-disc-cuer
 
-go 1.18
+go 1.21
 
 require (
 	github.com/spf13/viper v1.19.0
+	go.etcd.io/bbolt v1.3.10
 	go.uploadedlobster.com/discid v0.7.0
+	golang.org/x/image v0.18.0
 )
 
 require (
@@ -24,7 +26,7 @@ require (
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/exp v0.0.0-20230905200255-921286631fa9 // indirect
 	golang.org/x/sys v0.18.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
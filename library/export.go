@@ -0,0 +1,315 @@
+package library
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// Modes of creation of the exported files.
+const (
+	// ModeCopy copies the cached files, so the library does not depend on the cache.
+	ModeCopy = "copy"
+	// ModeHardlink links the cached files, sharing their storage. Requires the same filesystem.
+	ModeHardlink = "hardlink"
+	// ModeSymlink links to the cached files, which the library then depends on.
+	ModeSymlink = "symlink"
+)
+
+// Collision policies, when an exported path already holds another file.
+const (
+	// CollisionRename exports to a free name, adding " (2)", " (3)"... before the extension.
+	CollisionRename = "rename"
+	// CollisionOverwrite replaces the existing file.
+	CollisionOverwrite = "overwrite"
+	// CollisionSkip keeps the existing file and does not export.
+	CollisionSkip = "skip"
+)
+
+// coverName is the name of the exported front cover, next to the sheet, followed by the image extension.
+const coverName = "cover"
+
+// ExportsFile is the name of the file recording the exports of a disc, stored with the disc in the cache.
+const ExportsFile = "exports.json"
+
+// ErrLinkUnsupported is returned when exporting with links from a store not keeping regular files.
+var ErrLinkUnsupported = errors.New("hardlink and symlink exports require the dir cache backend")
+
+// Exporter writes the sheet and front cover of cached discs into a music library tree.
+type Exporter struct {
+	store     cache.Store
+	root      string
+	template  *Template
+	mode      string
+	collision string
+	file      string
+}
+
+// NewExporter creates an Exporter.
+//
+// Parameters:
+//   - store: The cache store holding the discs.
+//   - root: The library folder the template paths are relative to.
+//   - template: The path template of the exported sheet, see ParseTemplate.
+//   - mode: ModeCopy, ModeHardlink or ModeSymlink.
+//   - collision: CollisionRename, CollisionOverwrite or CollisionSkip.
+//
+// Returns:
+//   - *Exporter: The exporter, exporting cache.PlaylistFile.
+//   - error: An error if the template, mode or collision policy is invalid, or the store cannot link files.
+func NewExporter(store cache.Store, root, template, mode, collision string) (*Exporter, error) {
+	if root == "" {
+		return nil, fmt.Errorf("empty export root")
+	}
+	parsed, err := ParseTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case ModeCopy:
+	case ModeHardlink, ModeSymlink:
+		if _, ok := store.(cache.Locator); !ok {
+			return nil, ErrLinkUnsupported
+		}
+	default:
+		return nil, fmt.Errorf("invalid export mode %q: expected copy, hardlink or symlink", mode)
+	}
+	switch collision {
+	case CollisionRename, CollisionOverwrite, CollisionSkip:
+	default:
+		return nil, fmt.Errorf("invalid export collision policy %q: expected rename, overwrite or skip", collision)
+	}
+	return &Exporter{
+		store:     store,
+		root:      root,
+		template:  parsed,
+		mode:      mode,
+		collision: collision,
+		file:      cache.PlaylistFile,
+	}, nil
+}
+
+// SetFile selects the cached playlist exported, e.g. "image.cue". Defaults to cache.PlaylistFile.
+func (e *Exporter) SetFile(name string) {
+	e.file = name
+}
+
+// Export describes the files exported for a disc.
+//
+// Fields:
+//   - DiscID (string): The disc ID.
+//   - Sheet (string): The path of the exported sheet, "" if skipped.
+//   - Cover (string): The path of the exported front cover, "" if the disc has none or it was skipped.
+//   - Skipped ([]string): The paths kept because they hold other files, with CollisionSkip.
+type Export struct {
+	DiscID  string
+	Sheet   string
+	Cover   string
+	Skipped []string
+}
+
+// Export writes the sheet and front cover of a cached disc at its template path. Files already
+// exported from the same cached files are left as is, so exporting again is a no-op. The exports of
+// the disc are recorded in the cache, so that they are updated in place once the cached files
+// change; the collision policy only applies to the files the exporter did not create.
+//
+// Parameters:
+//   - discID: The disc ID, or a FreeDB ID alias.
+//
+// Returns:
+//   - Export: The exported paths.
+//   - error: An error if the disc metadata or sheet is missing, or a file cannot be written.
+func (e *Exporter) Export(discID string) (Export, error) {
+	discID = e.store.Resolve(discID)
+	result := Export{DiscID: discID}
+	metadata, err := cache.GetMetadata(e.store, discID)
+	if err != nil {
+		return result, fmt.Errorf("Failed to read %s metadata: %w", discID, err)
+	}
+	if !e.store.Has(discID, e.file) {
+		return result, fmt.Errorf("Failed to export %s: no %s in cache", discID, e.file)
+	}
+	records := e.loadRecords(discID)
+
+	sheet := filepath.Join(e.root, e.template.Expand(NewFields(metadata)))
+	if result.Sheet, err = e.place(discID, e.file, sheet, records); err != nil {
+		return result, errors.Join(err, e.saveRecords(discID, records))
+	}
+	if result.Sheet == "" {
+		result.Skipped = append(result.Skipped, sheet)
+		return result, e.saveRecords(discID, records)
+	}
+
+	if front := cache.FrontCover(e.store, discID); front != "" {
+		cover := filepath.Join(filepath.Dir(result.Sheet), coverName+filepath.Ext(front))
+		if result.Cover, err = e.place(discID, front, cover, records); err != nil {
+			return result, errors.Join(err, e.saveRecords(discID, records))
+		}
+		if result.Cover == "" {
+			result.Skipped = append(result.Skipped, cover)
+		}
+	}
+	return result, e.saveRecords(discID, records)
+}
+
+// exportRecords maps the absolute paths of the files exported for a disc to the fingerprint of
+// what was written, see fingerprint.
+type exportRecords map[string]string
+
+// loadRecords reads the exports recorded for a disc. Missing or unreadable records are empty.
+func (e *Exporter) loadRecords(discID string) exportRecords {
+	records := exportRecords{}
+	if data, err := e.store.Get(discID, ExportsFile); err == nil {
+		json.Unmarshal(data, &records)
+	}
+	return records
+}
+
+// saveRecords writes the exports recorded for a disc, forgetting the files removed since.
+func (e *Exporter) saveRecords(discID string, records exportRecords) error {
+	for path := range records {
+		if _, err := os.Lstat(path); err != nil {
+			delete(records, path)
+		}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if existing, err := e.store.Get(discID, ExportsFile); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if err := e.store.Put(discID, ExportsFile, data); err != nil {
+		return fmt.Errorf("Failed to record %s exports: %w", discID, err)
+	}
+	return nil
+}
+
+// created reports whether path is unchanged since the exporter wrote it.
+func (r exportRecords) created(path string) bool {
+	recorded, ok := r[absPath(path)]
+	return ok && recorded == fingerprint(path)
+}
+
+// add records path as written by the exporter.
+func (r exportRecords) add(path string) {
+	r[absPath(path)] = fingerprint(path)
+}
+
+// fingerprint identifies the content of path: the target of a symbolic link, else the SHA-256 hash
+// of the file. It returns "" if path cannot be read.
+func fingerprint(path string) string {
+	if target, err := os.Readlink(path); err == nil {
+		return "symlink:" + target
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// absPath returns the absolute form of path, path itself if it cannot be determined.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// place creates dest from a cached file, applying the collision policy to the files the exporter did
+// not create, and records it. A previous export of the file is replaced in place.
+//
+// Returns:
+//   - string: The path written, which differs from dest when renamed, "" if skipped.
+//   - error: Any error encountered while reading the cache or writing dest.
+func (e *Exporter) place(discID, name, dest string, records exportRecords) (string, error) {
+	data, err := e.store.Get(discID, name)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read %s/%s: %w", discID, name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("Failed to create folder for %s: %w", dest, err)
+	}
+
+	candidate := dest
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(candidate); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if e.exported(discID, name, data, candidate) {
+			records.add(candidate)
+			return candidate, nil
+		}
+		if records.created(candidate) || e.collision == CollisionOverwrite {
+			if err := os.Remove(candidate); err != nil {
+				return "", fmt.Errorf("Failed to replace %s: %w", candidate, err)
+			}
+			return candidate, e.create(discID, name, data, candidate, records)
+		}
+		if e.collision == CollisionSkip {
+			return "", nil
+		}
+		ext := filepath.Ext(dest)
+		candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(dest, ext), n, ext)
+	}
+	return candidate, e.create(discID, name, data, candidate, records)
+}
+
+// exported reports whether path already is the export of a cached file: a link to it, or a copy
+// with the same content.
+func (e *Exporter) exported(discID, name string, data []byte, path string) bool {
+	if src := e.source(discID, name); src != "" {
+		if target, err := os.Readlink(path); err == nil {
+			return target == src
+		}
+		srcInfo, srcErr := os.Stat(src)
+		info, err := os.Stat(path)
+		if srcErr == nil && err == nil && os.SameFile(srcInfo, info) {
+			return true
+		}
+	}
+	existing, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(existing, data)
+}
+
+// source returns the absolute path of a cached file, "" if the store does not keep regular files.
+func (e *Exporter) source(discID, name string) string {
+	locator, ok := e.store.(cache.Locator)
+	if !ok {
+		return ""
+	}
+	return absPath(locator.Path(discID, name))
+}
+
+// create writes path from a cached file according to the export mode, and records it.
+func (e *Exporter) create(discID, name string, data []byte, path string, records exportRecords) error {
+	var err error
+	switch e.mode {
+	case ModeHardlink:
+		err = os.Link(e.source(discID, name), path)
+	case ModeSymlink:
+		err = os.Symlink(e.source(discID, name), path)
+	default:
+		err = utils.WriteFileAtomic(path, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to export %s: %w", path, err)
+	}
+	records.add(path)
+	return nil
+}
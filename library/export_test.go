@@ -0,0 +1,290 @@
+package library
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+// exportTemplate is the template of the tests, exporting the sheet of the disc "disc" to Artist/Album.cue.
+const exportTemplate = "{albumartist}/{album}.cue"
+
+// newExportStore returns a directory store holding the disc "disc", with a sheet and a front cover.
+func newExportStore(t *testing.T) *cache.DirStore {
+	t.Helper()
+	store := cache.NewDirStore(t.TempDir())
+	putExportDisc(t, store)
+	return store
+}
+
+// putExportDisc stores the disc "disc" of Artist, Album, with a sheet and a front cover.
+func putExportDisc(t *testing.T, store cache.Store) {
+	t.Helper()
+	metadata := &cache.Metadata{DiscID: "disc", FetchedAt: time.Now(), DiscInfo: types.DiscInfo{Artist: "Artist", Title: "Album"}}
+	if err := cache.PutMetadata(store, metadata); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("disc", cache.PlaylistFile, []byte("sheet")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("disc", "front.jpg", []byte("cover")); err != nil {
+		t.Fatal(err)
+	}
+	index := &cache.CoverIndex{Images: []cache.CoverImage{{Type: cache.CoverFront, File: "front.jpg"}}}
+	if err := cache.PutCoverIndex(store, "disc", index); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readExport returns the content of an exported file, following links.
+func readExport(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNewExporterErrors(t *testing.T) {
+	dir := cache.NewDirStore(t.TempDir())
+	bolt, err := cache.NewBoltStore(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		store     cache.Store
+		root      string
+		template  string
+		mode      string
+		collision string
+		wantErr   error
+	}{
+		{name: "empty root", store: dir, template: exportTemplate, mode: ModeCopy, collision: CollisionRename},
+		{name: "invalid template", store: dir, root: "lib", template: "/abs.cue", mode: ModeCopy, collision: CollisionRename},
+		{name: "invalid mode", store: dir, root: "lib", template: exportTemplate, mode: "move", collision: CollisionRename},
+		{name: "invalid collision", store: dir, root: "lib", template: exportTemplate, mode: ModeCopy, collision: "merge"},
+		{name: "hardlink from bolt", store: bolt, root: "lib", template: exportTemplate, mode: ModeHardlink, collision: CollisionRename, wantErr: ErrLinkUnsupported},
+		{name: "symlink from bolt", store: bolt, root: "lib", template: exportTemplate, mode: ModeSymlink, collision: CollisionRename, wantErr: ErrLinkUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExporter(tt.store, tt.root, tt.template, tt.mode, tt.collision)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("NewExporter() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExportModes(t *testing.T) {
+	tests := []struct {
+		mode string
+		// check verifies how path was created from the cached file src
+		check func(t *testing.T, src, path string)
+	}{
+		{ModeCopy, func(t *testing.T, src, path string) {
+			info, _ := os.Lstat(path)
+			srcInfo, _ := os.Stat(src)
+			if !info.Mode().IsRegular() || os.SameFile(info, srcInfo) {
+				t.Errorf("%s is not a copy of %s", path, src)
+			}
+		}},
+		{ModeHardlink, func(t *testing.T, src, path string) {
+			info, _ := os.Lstat(path)
+			srcInfo, _ := os.Stat(src)
+			if !os.SameFile(info, srcInfo) {
+				t.Errorf("%s is not a hard link to %s", path, src)
+			}
+		}},
+		{ModeSymlink, func(t *testing.T, src, path string) {
+			if target, err := os.Readlink(path); err != nil || target != src {
+				t.Errorf("%s links to %q, %v, want %s", path, target, err, src)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			store := newExportStore(t)
+			root := t.TempDir()
+			exporter, err := NewExporter(store, root, exportTemplate, tt.mode, CollisionRename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			export, err := exporter.Export("disc")
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			wantSheet := filepath.Join(root, "Artist", "Album.cue")
+			wantCover := filepath.Join(root, "Artist", "cover.jpg")
+			if export.Sheet != wantSheet || export.Cover != wantCover || len(export.Skipped) != 0 {
+				t.Fatalf("Export() = %+v, want %s and %s", export, wantSheet, wantCover)
+			}
+			if readExport(t, export.Sheet) != "sheet" || readExport(t, export.Cover) != "cover" {
+				t.Error("exported files differ from the cached files")
+			}
+			src, _ := filepath.Abs(store.Path("disc", cache.PlaylistFile))
+			tt.check(t, src, export.Sheet)
+
+			// Exporting again is a no-op
+			again, err := exporter.Export("disc")
+			if err != nil || again.Sheet != export.Sheet || again.Cover != export.Cover {
+				t.Errorf("second Export() = %+v, %v, want the same paths", again, err)
+			}
+		})
+	}
+}
+
+func TestExportCollisions(t *testing.T) {
+	tests := []struct {
+		collision   string
+		wantSheet   string
+		wantSkipped bool
+		wantOther   string
+	}{
+		{collision: CollisionRename, wantSheet: "Album (2).cue", wantOther: "other"},
+		{collision: CollisionOverwrite, wantSheet: "Album.cue", wantOther: "sheet"},
+		{collision: CollisionSkip, wantSkipped: true, wantOther: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.collision, func(t *testing.T) {
+			root := t.TempDir()
+			other := filepath.Join(root, "Artist", "Album.cue")
+			os.MkdirAll(filepath.Dir(other), 0755)
+			os.WriteFile(other, []byte("other"), 0644)
+
+			exporter, err := NewExporter(newExportStore(t), root, exportTemplate, ModeCopy, tt.collision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			export, err := exporter.Export("disc")
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if tt.wantSkipped {
+				if export.Sheet != "" || export.Cover != "" || len(export.Skipped) != 1 || export.Skipped[0] != other {
+					t.Errorf("Export() = %+v, want the sheet skipped, and no cover", export)
+				}
+			} else if want := filepath.Join(root, "Artist", tt.wantSheet); export.Sheet != want || readExport(t, export.Sheet) != "sheet" {
+				t.Errorf("Export() sheet = %s, want %s with the cached sheet", export.Sheet, want)
+			}
+			if got := readExport(t, other); got != tt.wantOther {
+				t.Errorf("existing file = %q, want %q", got, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestExportFromBolt(t *testing.T) {
+	store, err := cache.NewBoltStore(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	putExportDisc(t, store)
+	root := t.TempDir()
+	exporter, err := NewExporter(store, root, exportTemplate, ModeCopy, CollisionRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	export, err := exporter.Export("disc")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if readExport(t, export.Sheet) != "sheet" || readExport(t, export.Cover) != "cover" {
+		t.Errorf("Export() = %+v, want copies of the stored files", export)
+	}
+}
+
+func TestExportMissingSheet(t *testing.T) {
+	store := newExportStore(t)
+	exporter, err := NewExporter(store, t.TempDir(), exportTemplate, ModeCopy, CollisionRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter.SetFile("image.cue")
+	if _, err := exporter.Export("disc"); err == nil {
+		t.Error("Export() of a missing sheet: error = nil")
+	}
+	if _, err := exporter.Export("missing"); err == nil {
+		t.Error("Export() of a missing disc: error = nil")
+	}
+}
+
+func TestExportUpdatesOwnExports(t *testing.T) {
+	for _, mode := range []string{ModeCopy, ModeHardlink, ModeSymlink} {
+		for _, collision := range []string{CollisionRename, CollisionSkip} {
+			t.Run(mode+" "+collision, func(t *testing.T) {
+				store := newExportStore(t)
+				root := t.TempDir()
+				exporter, err := NewExporter(store, root, exportTemplate, mode, collision)
+				if err != nil {
+					t.Fatal(err)
+				}
+				first, err := exporter.Export("disc")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// A refresh replaces the cached files
+				store.Put("disc", cache.PlaylistFile, []byte("new sheet"))
+				store.Put("disc", "front.jpg", []byte("new cover"))
+				second, err := exporter.Export("disc")
+				if err != nil {
+					t.Fatalf("Export() after a refresh: error = %v", err)
+				}
+				if second.Sheet != first.Sheet || second.Cover != first.Cover || len(second.Skipped) != 0 {
+					t.Errorf("Export() after a refresh = %+v, want the previous exports %+v updated", second, first)
+				}
+				if readExport(t, second.Sheet) != "new sheet" || readExport(t, second.Cover) != "new cover" {
+					t.Error("exports not updated after a refresh")
+				}
+				if entries, _ := os.ReadDir(filepath.Dir(first.Sheet)); len(entries) != 2 {
+					t.Errorf("library folder holds %d files, want the sheet and the cover", len(entries))
+				}
+			})
+		}
+	}
+}
+
+func TestExportKeepsEditedExports(t *testing.T) {
+	store := newExportStore(t)
+	root := t.TempDir()
+	exporter, err := NewExporter(store, root, exportTemplate, ModeCopy, CollisionRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := exporter.Export("disc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The user edits the exported sheet: it is no longer the exporter's
+	os.WriteFile(first.Sheet, []byte("edited"), 0644)
+	store.Put("disc", cache.PlaylistFile, []byte("new sheet"))
+	second, err := exporter.Export("disc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(root, "Artist", "Album (2).cue"); second.Sheet != want {
+		t.Errorf("Export() sheet = %s, want %s", second.Sheet, want)
+	}
+	if readExport(t, first.Sheet) != "edited" {
+		t.Error("edited export overwritten")
+	}
+
+	// The renamed export is then updated in place
+	store.Put("disc", cache.PlaylistFile, []byte("newer sheet"))
+	third, err := exporter.Export("disc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Sheet != second.Sheet || readExport(t, third.Sheet) != "newer sheet" {
+		t.Errorf("Export() sheet = %s, want %s updated", third.Sheet, second.Sheet)
+	}
+}
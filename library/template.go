@@ -0,0 +1,172 @@
+// Package library exports cached discs to a music library tree, at paths built from a template
+// of their metadata.
+package library
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+)
+
+// unknownField replaces the empty fields of a template, so that no path component is empty.
+const unknownField = "Unknown"
+
+// maxComponentLength is the maximum length in bytes of a file name on common filesystems.
+const maxComponentLength = 255
+
+// Fields holds the values of the template placeholders, by name.
+type Fields map[string]string
+
+// fieldNames lists the placeholders supported by templates.
+var fieldNames = []string{"albumartist", "artist", "album", "year", "date", "genre", "discid", "freedbid", "mbid"}
+
+// NewFields returns the template fields of a cached disc.
+//
+// Parameters:
+//   - metadata: The disc metadata sidecar.
+//
+// Returns:
+//   - Fields: The values of every placeholder, possibly empty.
+func NewFields(metadata *cache.Metadata) Fields {
+	info := metadata.DiscInfo
+	year := info.ReleaseDate
+	if len(year) > 4 {
+		year = year[:4]
+	}
+	return Fields{
+		"albumartist": info.Artist,
+		"artist":      info.Artist,
+		"album":       info.Title,
+		"year":        year,
+		"date":        info.ReleaseDate,
+		"genre":       info.Genre,
+		"discid":      metadata.DiscID,
+		"freedbid":    metadata.FreedbID,
+		"mbid":        metadata.MusicBrainzID,
+	}
+}
+
+// Template builds relative file paths from disc metadata, e.g. "{albumartist}/{year} - {album}/{album}.cue".
+type Template struct {
+	// components holds the path components, each a sequence of literal text and placeholders.
+	components [][]segment
+}
+
+// segment is a literal text, or a placeholder if field is set.
+type segment struct {
+	text  string
+	field string
+}
+
+// ParseTemplate parses a path template. Placeholders are field names in braces, separated by "/".
+//
+// Parameters:
+//   - template: The template to parse.
+//
+// Returns:
+//   - *Template: The parsed template.
+//   - error: An error if the template is empty, absolute, goes up with "..", has an unknown
+//     placeholder or an unclosed brace.
+func ParseTemplate(template string) (*Template, error) {
+	if template == "" {
+		return nil, fmt.Errorf("empty export template")
+	}
+	if filepath.IsAbs(template) {
+		return nil, fmt.Errorf("export template %q must be relative to the library", template)
+	}
+	t := &Template{}
+	for _, component := range strings.Split(template, "/") {
+		if component == "" || component == "." || component == ".." {
+			return nil, fmt.Errorf("invalid export template %q: empty, . or .. path component", template)
+		}
+		var segments []segment
+		for component != "" {
+			start := strings.IndexByte(component, '{')
+			if start < 0 {
+				segments = append(segments, segment{text: component})
+				break
+			}
+			end := strings.IndexByte(component[start:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid export template %q: unclosed {", template)
+			}
+			field := component[start+1 : start+end]
+			if !slices.Contains(fieldNames, field) {
+				return nil, fmt.Errorf("invalid export template %q: unknown field {%s}, expected one of %s",
+					template, field, strings.Join(fieldNames, ", "))
+			}
+			if start > 0 {
+				segments = append(segments, segment{text: component[:start]})
+			}
+			segments = append(segments, segment{field: field})
+			component = component[start+end+1:]
+		}
+		t.components = append(t.components, segments)
+	}
+	return t, nil
+}
+
+// Expand builds the relative path of a disc. Field values are sanitized so that they cannot add path
+// components, and empty values are replaced by "Unknown".
+//
+// Parameters:
+//   - fields: The values of the placeholders.
+//
+// Returns:
+//   - string: The relative path, using the OS separator.
+func (t *Template) Expand(fields Fields) string {
+	components := make([]string, len(t.components))
+	for i, segments := range t.components {
+		var b strings.Builder
+		for _, seg := range segments {
+			if seg.field == "" {
+				b.WriteString(seg.text)
+				continue
+			}
+			value := fields[seg.field]
+			if strings.TrimSpace(value) == "" {
+				value = unknownField
+			}
+			b.WriteString(SanitizeName(value))
+		}
+		components[i] = SanitizeName(b.String())
+	}
+	return filepath.Join(components...)
+}
+
+// SanitizeName makes name a valid file name on common filesystems: path separators, characters
+// reserved on Windows and control characters are replaced by "_", leading dots and trailing dots and
+// spaces are removed, and the name is truncated to 255 bytes.
+//
+// Parameters:
+//   - name: The file name to sanitize.
+//
+// Returns:
+//   - string: The sanitized name, "_" if nothing is left.
+func SanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(strings.TrimLeft(name, "."))
+	for len(name) > maxComponentLength {
+		// Truncate on a rune boundary, keeping the extension
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := []rune(strings.TrimSuffix(name, ext))
+		name = string(base[:len(base)-1]) + ext
+	}
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,121 @@
+package library
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"", "empty"},
+		{"/music/{album}.cue", "relative"},
+		{"{albumartist}//{album}.cue", "path component"},
+		{"{albumartist}/./{album}.cue", "path component"},
+		{"../{album}.cue", "path component"},
+		{"{albumartist}/", "path component"},
+		{"{albumartist}/{album.cue", "unclosed"},
+		{"{albumartist}/{title}.cue", "unknown field {title}"},
+		{"{}/{album}.cue", "unknown field {}"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTemplate(tt.template); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseTemplate(%q) error = %v, want an error containing %q", tt.template, err, tt.want)
+		}
+	}
+}
+
+func TestTemplateExpand(t *testing.T) {
+	fields := NewFields(&cache.Metadata{
+		DiscID:        "disc-id",
+		FreedbID:      "0a000001",
+		MusicBrainzID: "rel",
+		DiscInfo: types.DiscInfo{
+			Artist:      "AC/DC",
+			Title:       "Back in Black",
+			ReleaseDate: "1980-07-25",
+		},
+	})
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{albumartist}/{year} - {album}/{album}.cue", "AC_DC/1980 - Back in Black/Back in Black.cue"},
+		{"{artist}/{date}/{discid} {freedbid} {mbid}.cue", "AC_DC/1980-07-25/disc-id 0a000001 rel.cue"},
+		// Empty fields are replaced, so that no component is empty or hidden
+		{"{genre}/{album}.cue", "Unknown/Back in Black.cue"},
+		{"{genre}{genre}/.cue", "UnknownUnknown/cue"},
+		{"static.cue", "static.cue"},
+	}
+	for _, tt := range tests {
+		template, err := ParseTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q) error = %v", tt.template, err)
+		}
+		if got := template.Expand(fields); got != filepath.FromSlash(tt.want) {
+			t.Errorf("Expand(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestNewFieldsYear(t *testing.T) {
+	for date, want := range map[string]string{"1980-07-25": "1980", "1980": "1980", "80": "80", "": ""} {
+		if got := NewFields(&cache.Metadata{DiscInfo: types.DiscInfo{ReleaseDate: date}})["year"]; got != want {
+			t.Errorf("year of %q = %q, want %q", date, got, want)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Back in Black", "Back in Black"},
+		{`a/b\c:d*e?f"g<h>i|j`, "a_b_c_d_e_f_g_h_i_j"},
+		{"tab\there\x00", "tab_here_"},
+		{"...hidden", "hidden"},
+		{".", "_"},
+		{"..", "_"},
+		{"trailing. . ", "trailing"},
+		{"  spaced  ", "spaced"},
+		{"", "_"},
+		{"Sigur Rós", "Sigur Rós"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeName(tt.name); got != tt.want {
+			t.Errorf("SanitizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeNameTruncates(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantExt string
+	}{
+		{strings.Repeat("a", 300), ""},
+		{strings.Repeat("é", 200), ""},
+		{strings.Repeat("é", 200) + ".cue", ".cue"},
+		{"a" + strings.Repeat("日", 100) + ".flac", ".flac"},
+		// Not an extension, too long to be kept
+		{strings.Repeat("a", 250) + "." + strings.Repeat("b", 20), ""},
+	}
+	for _, tt := range tests {
+		got := SanitizeName(tt.name)
+		if len(got) > maxComponentLength || !utf8.ValidString(got) || !strings.HasSuffix(got, tt.wantExt) {
+			t.Errorf("SanitizeName() of %d bytes = %d bytes, valid UTF-8 %v, %q suffix, want at most %d bytes ending with %q",
+				len(tt.name), len(got), utf8.ValidString(got), filepath.Ext(got), maxComponentLength, tt.wantExt)
+		}
+		if len(got) < maxComponentLength-utf8.UTFMax {
+			t.Errorf("SanitizeName() of %d bytes = %d bytes, truncated more than needed", len(tt.name), len(got))
+		}
+	}
+}
@@ -1,14 +1,20 @@
 // The main package handles command-line flags and orchestrates the generation of CUE files,
-// either from MusicBrainz release IDs or directly provided disc IDs, with an option to overwrite
-// existing files.
+// either from MusicBrainz release IDs, directly provided disc IDs or TOCs, with an option to
+// overwrite existing files.
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
 
 	"github.com/b0bbywan/go-disc-cuer/config"
 	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/library"
+	"github.com/b0bbywan/go-disc-cuer/watch"
 )
 
 // Command-line flags
@@ -24,6 +30,33 @@ var (
 
 	// deviceFlag specifies the drive to read data from
 	deviceFlag string
+
+	// allDrives reads every drive of the devices setting, or every drive detected, instead of a single one.
+	allDrives bool
+
+	// fromDrive forces reading the disc TOC from the drive when a MusicBrainz release is given.
+	fromDrive bool
+
+	// mediumFlag selects the disc of a MusicBrainz release of several discs, by position.
+	mediumFlag int
+
+	// tocFlag specifies a MusicBrainz formatted TOC to use instead of reading the drive.
+	tocFlag string
+
+	// verbose enables debug logs.
+	verbose bool
+
+	// quiet only logs errors.
+	quiet bool
+
+	// formatFlag lists the playlist formats to generate, overriding the formats setting.
+	formatFlag string
+
+	// outputFormat selects what is printed on stdout: nothing but logs ("text") or a Report ("json").
+	outputFormat string
+
+	// logFormat selects the log output format, "text" or "json".
+	logFormat string
 )
 
 // init initializes the command-line flags and their descriptions.
@@ -38,6 +71,58 @@ func init() {
 	flag.StringVar(&providedDiscID, "disc-id", "", "specify disc ID directly")
 
 	flag.StringVar(&deviceFlag, "device", "", "Disc Device")
+
+	// -all-drives flag to handle every drive concurrently
+	flag.BoolVar(&allDrives, "all-drives", false, "handle every drive of the devices setting, else every drive detected, concurrently")
+
+	// -from-drive flag to use the inserted disc instead of the release disc IDs
+	flag.BoolVar(&fromDrive, "from-drive", false, "with --musicbrainz, read the disc ID from the drive instead of the release disc IDs")
+
+	// -medium flag to select the disc of a release of several discs
+	flag.IntVar(&mediumFlag, "medium", 0, "with --musicbrainz, position of the disc in a release of several discs (default: the disc matching the TOC)")
+
+	// -toc flag to specify the disc TOC directly
+	flag.StringVar(&tocFlag, "toc", "", "specify disc TOC directly (MusicBrainz format: \"first last leadout offset1 ... offsetN\")")
+
+	// -format flag to select the playlist formats
+	flag.StringVar(&formatFlag, "format", "", "comma-separated playlist formats to generate: "+strings.Join(cue.Formats, ", ")+" (default: formats setting, else cue)")
+
+	// -output flag to print the result as JSON
+	flag.StringVar(&outputFormat, "output", "text", "output format: text (logs only) or json (print the result on stdout)")
+
+	// -verbose, -quiet and -log-format flags to control logging
+	flag.BoolVar(&verbose, "verbose", false, "log debug messages")
+	flag.BoolVar(&quiet, "quiet", false, "only log errors")
+	flag.StringVar(&logFormat, "log-format", "text", "log format: text or json")
+}
+
+// newLogger creates the logger writing to stderr according to the logging flags.
+func newLogger(verbose, quiet bool, format string) (*slog.Logger, error) {
+	if verbose && quiet {
+		return nil, fmt.Errorf("--verbose and --quiet are mutually exclusive")
+	}
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	} else if quiet {
+		level = slog.LevelError
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// fatal logs msg with err and exits with a non-zero status.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func getDevice(device string, cuerConfig *config.Config) string {
@@ -47,19 +132,127 @@ func getDevice(device string, cuerConfig *config.Config) string {
 	return cuerConfig.Device
 }
 
+// getDevices returns the drives to read: the --device flag if set, else with --all-drives the devices
+// setting or, if empty, every drive detected, else the device setting.
+func getDevices(cuerConfig *config.Config) ([]string, error) {
+	if deviceFlag != "" || !allDrives {
+		return []string{getDevice(deviceFlag, cuerConfig)}, nil
+	}
+	if len(cuerConfig.Devices) > 0 {
+		return cuerConfig.Devices, nil
+	}
+	drives, err := watch.Drives()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to detect drives: %w", err)
+	}
+	if len(drives) == 0 {
+		return nil, fmt.Errorf("Failed to detect drives: %w", cue.ErrNoDevice)
+	}
+	return drives, nil
+}
+
 // main is the entry point for the program. It parses the flags and generates a CUE file
-// based on the provided MusicBrainz ID, disc ID, and overwrite flag.
+// based on the provided MusicBrainz ID, disc ID, TOC and overwrite flag, or runs the
+// given subcommand.
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
-	cuerConfig, err := config.NewDefaultConfig()
+	logger, err := newLogger(verbose, quiet, logFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	cuerConfig, err := config.NewConfigWithLogger(config.AppName, config.AppVersion, "", logger)
+	if err != nil {
+		fatal(logger, fmt.Sprintf("Failed to initialize %s config", config.AppName), err)
+	}
+
+	if outputFormat != "text" && outputFormat != "json" {
+		fatal(logger, "Invalid options", fmt.Errorf("unknown output format %q", outputFormat))
+	}
+	if formatFlag != "" {
+		cuerConfig.Formats = strings.Split(formatFlag, ",")
+	}
+
+	if flag.NArg() > 0 {
+		if err = runCommand(cuerConfig, flag.Args()); err != nil {
+			fatal(logger, fmt.Sprintf("%s failed", flag.Arg(0)), err)
+		}
+		return
+	}
+
+	req := newRequest(cuerConfig)
+	if _, err = req.Validate(); err != nil {
+		fatal(logger, "Invalid options", err)
+	}
+	if allDrives && (req.Toc != "" || req.DiscID != "" || req.MusicBrainzID != "") {
+		fatal(logger, "Invalid options", fmt.Errorf("--all-drives reads the drives and cannot be combined with --toc, --disc-id or --musicbrainz"))
+	}
+
+	generator, err := cue.NewGenerator(cuerConfig)
+	if err != nil {
+		fatal(logger, "Failed to create generator", err)
+	}
+	if allDrives {
+		if err = generateAll(cuerConfig, generator); err != nil {
+			fatal(logger, "Failed to generate playlists", err)
+		}
+		return
+	}
+	result, err := generator.Generate(context.Background(), req)
+	if outputFormat == "json" {
+		if werr := cue.NewReport(result, err).WriteJSON(os.Stdout); werr != nil {
+			fatal(logger, "Failed to write output", werr)
+		}
+	}
 	if err != nil {
-		log.Fatalf("error: Failed to initialize %s config: %v", config.AppName, err)
+		// Let the onError or onNotFound hook command complete before exiting
+		generator.Wait()
+		fatal(logger, "Failed to generate playlist", err)
 	}
+	afterGenerate(cuerConfig, generator, result.DiscID, req.Device, nil)
+	// Let a stale-while-revalidate refresh and the hook command complete before exiting
+	generator.Wait()
+}
 
-	device := getDevice(deviceFlag, cuerConfig)
+// afterGenerate exports a generated disc when ExportOnGenerate is set, then queues it on the MPD
+// server when MPDOnGenerate is set, see queueGenerated for device and run.
+func afterGenerate(cuerConfig *config.Config, generator *cue.Generator, discID, device string, run *queueRun) {
+	if cuerConfig.ExportOnGenerate {
+		exportGenerated(cuerConfig, generator, discID)
+	}
+	if cuerConfig.MPDOnGenerate {
+		queueGenerated(cuerConfig, generator, discID, device, run)
+	}
+}
+
+// exportGenerated exports a generated disc to the music library. Failures are only logged, as the
+// disc is cached anyway.
+func exportGenerated(cuerConfig *config.Config, generator *cue.Generator, discID string) {
+	exporter, err := library.NewExporter(generator.Store(), cuerConfig.ExportRoot, cuerConfig.ExportTemplate,
+		cuerConfig.ExportMode, cuerConfig.ExportCollision)
+	if err == nil {
+		var export library.Export
+		if export, err = exporter.Export(discID); err == nil {
+			cuerConfig.GetLogger().Info("disc exported", "disc_id", discID, "sheet", export.Sheet, "cover", export.Cover, "skipped", export.Skipped)
+			return
+		}
+	}
+	cuerConfig.GetLogger().Error("export failed", "disc_id", discID, "error", err)
+}
 
-	if _, err = cue.GenerateWithOptions(device, cuerConfig, musicbrainzID, providedDiscID, overwrite); err != nil {
-		log.Fatalf("error: Failed to generate playlist from both GNUDB and MusicBrainz: %v", err)
+// newRequest builds the generation request from the command-line flags.
+func newRequest(cuerConfig *config.Config) cue.Request {
+	return cue.Request{
+		Device:        getDevice(deviceFlag, cuerConfig),
+		DiscID:        providedDiscID,
+		MusicBrainzID: musicbrainzID,
+		Toc:           tocFlag,
+		FromDrive:     fromDrive,
+		Medium:        mediumFlag,
+		Overwrite:     overwrite,
 	}
 }
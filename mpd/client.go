@@ -0,0 +1,228 @@
+// Package mpd is a minimal Music Player Daemon client, queuing generated discs for playback.
+package mpd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the port of MPD servers, used when the address has none.
+const DefaultPort = "6600"
+
+// defaultTimeout bounds the session when the context has no deadline.
+const defaultTimeout = 10 * time.Second
+
+// Error is an error reported by the server, in an ACK response.
+//
+// Fields:
+//   - Code (int): The MPD error code, e.g. 50 when a playlist or file does not exist.
+//   - Command (string): The failed command.
+//   - Message (string): The server message.
+type Error struct {
+	Code    int
+	Command string
+	Message string
+}
+
+// Error returns the server message with the failed command.
+func (e *Error) Error() string {
+	return fmt.Sprintf("MPD %s failed: %s (error %d)", e.Command, e.Message, e.Code)
+}
+
+// Pair is a "key: value" line of a response.
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// Client is a connection to an MPD server. It is not safe for concurrent use.
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// Version is the protocol version announced by the server.
+	Version string
+}
+
+// Dial connects to an MPD server and authenticates if a password is given.
+//
+// Parameters:
+//   - ctx: Bounds the connection and the whole session, defaults to 10 seconds without deadline.
+//   - address: A Unix socket path, e.g. /run/mpd/socket, or host[:port].
+//   - password: The MPD password, if the server requires one.
+//
+// Returns:
+//   - *Client: The connected client, to close once done.
+//   - error: An error if the server cannot be reached, is not an MPD server, or rejects the password.
+func Dial(ctx context.Context, address, password string) (*Client, error) {
+	if address == "" {
+		return nil, fmt.Errorf("Failed to connect to MPD: empty address")
+	}
+	network := "tcp"
+	if strings.HasPrefix(address, "/") || strings.HasPrefix(address, "@") {
+		network = "unix"
+	} else if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, DefaultPort)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to MPD: %w", err)
+	}
+	conn.SetDeadline(deadline)
+	c := &Client{conn: conn, reader: bufio.NewReader(conn)}
+	greeting, err := c.reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Failed to connect to MPD: %w", err)
+	}
+	version, ok := strings.CutPrefix(strings.TrimSuffix(greeting, "\n"), "OK MPD ")
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("Failed to connect to MPD: unexpected greeting %q", greeting)
+	}
+	c.Version = version
+	if password != "" {
+		if _, err := c.Command("password", password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Command sends a command and reads its response.
+//
+// Parameters:
+//   - name: The command, e.g. "addid".
+//   - args: The arguments, quoted as needed.
+//
+// Returns:
+//   - []Pair: The lines of the response.
+//   - error: An *Error if the server rejected the command, or a connection error.
+func (c *Client) Command(name string, args ...string) ([]Pair, error) {
+	var line strings.Builder
+	line.WriteString(name)
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "\n\r") {
+			return nil, fmt.Errorf("Failed to send MPD %s: argument %q contains a newline", name, arg)
+		}
+		line.WriteString(" " + quote(arg))
+	}
+	line.WriteString("\n")
+	if _, err := c.conn.Write([]byte(line.String())); err != nil {
+		return nil, fmt.Errorf("Failed to send MPD %s: %w", name, err)
+	}
+
+	var pairs []Pair
+	for {
+		response, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read MPD %s response: %w", name, err)
+		}
+		response = strings.TrimSuffix(response, "\n")
+		switch {
+		case response == "OK":
+			return pairs, nil
+		case strings.HasPrefix(response, "ACK "):
+			return nil, parseAck(name, response)
+		}
+		key, value, _ := strings.Cut(response, ": ")
+		pairs = append(pairs, Pair{Key: key, Value: value})
+	}
+}
+
+// Clear empties the queue.
+func (c *Client) Clear() error {
+	_, err := c.Command("clear")
+	return err
+}
+
+// Load appends a playlist to the queue: a stored playlist name, or a playlist file such as a CUE
+// sheet. Files outside the music directory are only accepted from clients on the local socket.
+func (c *Client) Load(playlist string) error {
+	_, err := c.Command("load", playlist)
+	return err
+}
+
+// AddID appends a song to the queue.
+//
+// Returns:
+//   - int: The queue ID of the song.
+//   - error: Any error reported by the server.
+func (c *Client) AddID(uri string) (int, error) {
+	pairs, err := c.Command("addid", uri)
+	if err != nil {
+		return 0, err
+	}
+	for _, pair := range pairs {
+		if pair.Key == "Id" {
+			return strconv.Atoi(pair.Value)
+		}
+	}
+	return 0, fmt.Errorf("MPD addid returned no song ID")
+}
+
+// AddTagID sets a tag of a queued song. Only songs outside the database, such as cdda:// tracks, can be tagged.
+func (c *Client) AddTagID(id int, tag, value string) error {
+	_, err := c.Command("addtagid", strconv.Itoa(id), tag, value)
+	return err
+}
+
+// Play starts playback at a queue position, or resumes it if position is negative.
+func (c *Client) Play(position int) error {
+	if position < 0 {
+		_, err := c.Command("play")
+		return err
+	}
+	_, err := c.Command("play", strconv.Itoa(position))
+	return err
+}
+
+// PlayID starts playback at the song with the given queue ID.
+func (c *Client) PlayID(id int) error {
+	_, err := c.Command("playid", strconv.Itoa(id))
+	return err
+}
+
+// quote quotes an argument, escaping backslashes and double quotes.
+func quote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// parseAck parses an "ACK [code@index] {command} message" response.
+func parseAck(name, response string) *Error {
+	e := &Error{Command: name, Message: strings.TrimPrefix(response, "ACK ")}
+	rest, ok := strings.CutPrefix(e.Message, "[")
+	if !ok {
+		return e
+	}
+	codeIndex, rest, ok := strings.Cut(rest, "] ")
+	if !ok {
+		return e
+	}
+	code, _, _ := strings.Cut(codeIndex, "@")
+	e.Code, _ = strconv.Atoi(code)
+	if command, message, ok := strings.Cut(strings.TrimPrefix(rest, "{"), "} "); ok && strings.HasPrefix(rest, "{") {
+		if command != "" {
+			e.Command = command
+		}
+		rest = message
+	}
+	e.Message = rest
+	return e
+}
@@ -0,0 +1,138 @@
+package mpd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+)
+
+// Modes of queuing a disc.
+const (
+	// ModeAdd adds the cdda:// tracks of the disc and tags them with the disc metadata using addtagid.
+	// It works with any MPD server having the cdio_paranoia input plugin.
+	ModeAdd = "add"
+	// ModeLoad loads the cached CUE sheet with load. MPD only reads files outside its music directory
+	// for clients connected to its local socket.
+	ModeLoad = "load"
+)
+
+// QueueOptions selects how a disc is queued.
+//
+// Fields:
+//   - Mode (string): ModeAdd or ModeLoad.
+//   - Replace (bool): Clears the queue first, else the disc is appended.
+//   - Play (bool): Starts playback at the first track of the disc.
+//   - Device (string): The drive holding the disc, e.g. /dev/sr1, in the cdda:// URIs of ModeAdd. Empty
+//     uses the default drive of MPD. Sheets loaded with ModeLoad always reference the default drive.
+type QueueOptions struct {
+	Mode    string
+	Replace bool
+	Play    bool
+	Device  string
+}
+
+// Queue queues a cached disc on the MPD server.
+//
+// Parameters:
+//   - c: The connected client.
+//   - store: The cache store holding the disc.
+//   - discID: The disc ID, or a FreeDB ID alias.
+//   - opts: How the disc is queued.
+//
+// Returns:
+//   - int: The number of tracks queued, as known from the metadata.
+//   - error: An error if the mode is invalid, the disc is not cached, or the server rejected a command.
+func Queue(c *Client, store cache.Store, discID string, opts QueueOptions) (int, error) {
+	discID = store.Resolve(discID)
+	metadata, err := cache.GetMetadata(store, discID)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s metadata: %w", discID, err)
+	}
+	var sheet string
+	switch opts.Mode {
+	case ModeAdd:
+	case ModeLoad:
+		locator, ok := store.(cache.Locator)
+		if !ok || !store.Has(discID, cache.PlaylistFile) {
+			return 0, fmt.Errorf("Failed to queue %s: loading requires the CUE sheet in the dir cache backend", discID)
+		}
+		if sheet, err = filepath.Abs(locator.Path(discID, cache.PlaylistFile)); err != nil {
+			return 0, fmt.Errorf("Failed to queue %s: %w", discID, err)
+		}
+	default:
+		return 0, fmt.Errorf("invalid MPD mode %q: expected add or load", opts.Mode)
+	}
+
+	// The position of the first track of the disc, to start playback from
+	start := 0
+	if opts.Replace {
+		if err := c.Clear(); err != nil {
+			return 0, err
+		}
+	} else {
+		pairs, err := c.Command("status")
+		if err != nil {
+			return 0, err
+		}
+		for _, pair := range pairs {
+			if pair.Key == "playlistlength" {
+				start, _ = strconv.Atoi(pair.Value)
+			}
+		}
+	}
+
+	if opts.Mode == ModeLoad {
+		err = c.Load(sheet)
+	} else {
+		err = addTracks(c, metadata, opts.Device)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if opts.Play {
+		if err := c.Play(start); err != nil {
+			return 0, err
+		}
+	}
+	return len(metadata.DiscInfo.Tracks), nil
+}
+
+// addTracks adds a cdda:// song per track of the disc in device, tagged with its metadata.
+func addTracks(c *Client, metadata *cache.Metadata, device string) error {
+	info := metadata.DiscInfo
+	for i, title := range info.Tracks {
+		id, err := c.AddID(TrackURI(device, i+1))
+		if err != nil {
+			return err
+		}
+		tags := [][2]string{
+			{"title", title},
+			{"artist", info.Artist},
+			{"albumartist", info.Artist},
+			{"album", info.Title},
+			{"track", strconv.Itoa(i + 1)},
+			{"date", info.ReleaseDate},
+			{"genre", info.Genre},
+		}
+		if metadata.MusicBrainzID != "" {
+			tags = append(tags, [2]string{"musicbrainz_albumid", metadata.MusicBrainzID})
+		}
+		for _, tag := range tags {
+			if tag[1] == "" {
+				continue
+			}
+			if err := c.AddTagID(id, tag[0], tag[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// TrackURI returns the URI of a track of the disc in device for the MPD cdio_paranoia input plugin,
+// "cdda://DEVICE/TRACK", e.g. cdda:///dev/sr1/3, or cdda:///3 for the default drive if device is empty.
+func TrackURI(device string, track int) string {
+	return fmt.Sprintf("cdda://%s/%d", device, track)
+}
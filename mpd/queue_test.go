@@ -0,0 +1,131 @@
+package mpd
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+// fakeServer accepts a connection and answers OK to every command, with a song ID for addid,
+// recording the commands received.
+func fakeServer(t *testing.T) (address string, commands func() []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 100)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("OK MPD 0.23.5\n"))
+		scanner := bufio.NewScanner(conn)
+		songs := 0
+		for scanner.Scan() {
+			received <- scanner.Text()
+			switch {
+			case strings.HasPrefix(scanner.Text(), "addid "):
+				songs++
+				conn.Write([]byte("Id: " + strconv.Itoa(songs) + "\nOK\n"))
+			case scanner.Text() == "status":
+				conn.Write([]byte("playlistlength: 5\nOK\n"))
+			default:
+				conn.Write([]byte("OK\n"))
+			}
+		}
+	}()
+	return listener.Addr().String(), func() []string {
+		var commands []string
+		for len(received) > 0 {
+			commands = append(commands, <-received)
+		}
+		return commands
+	}
+}
+
+func TestTrackURI(t *testing.T) {
+	tests := []struct {
+		device string
+		track  int
+		want   string
+	}{
+		{"", 1, "cdda:///1"},
+		{"/dev/sr1", 3, "cdda:///dev/sr1/3"},
+		{"/dev/cdrom", 12, "cdda:///dev/cdrom/12"},
+	}
+	for _, tt := range tests {
+		if got := TrackURI(tt.device, tt.track); got != tt.want {
+			t.Errorf("TrackURI(%q, %d) = %q, want %q", tt.device, tt.track, got, tt.want)
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	store := cache.NewDirStore(t.TempDir())
+	if err := cache.PutMetadata(store, &cache.Metadata{
+		Version:  1,
+		DiscID:   "disc",
+		Source:   "manual",
+		DiscInfo: types.DiscInfo{Title: "Album", Tracks: []string{"First", "Second"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts QueueOptions
+		want []string
+	}{
+		{
+			name: "replace and play from the drive",
+			opts: QueueOptions{Mode: ModeAdd, Replace: true, Play: true, Device: "/dev/sr1"},
+			want: []string{
+				"clear",
+				`addid "cdda:///dev/sr1/1"`, `addtagid "1" "title" "First"`, `addtagid "1" "album" "Album"`, `addtagid "1" "track" "1"`,
+				`addid "cdda:///dev/sr1/2"`, `addtagid "2" "title" "Second"`, `addtagid "2" "album" "Album"`, `addtagid "2" "track" "2"`,
+				`play "0"`,
+			},
+		},
+		{
+			name: "append to the default drive",
+			opts: QueueOptions{Mode: ModeAdd, Play: true},
+			want: []string{
+				"status",
+				`addid "cdda:///1"`, `addtagid "1" "title" "First"`, `addtagid "1" "album" "Album"`, `addtagid "1" "track" "1"`,
+				`addid "cdda:///2"`, `addtagid "2" "title" "Second"`, `addtagid "2" "album" "Album"`, `addtagid "2" "track" "2"`,
+				`play "5"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, commands := fakeServer(t)
+			client, err := Dial(context.Background(), address, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			tracks, err := Queue(client, store, "disc", tt.opts)
+			if err != nil {
+				t.Fatalf("Queue() error = %v", err)
+			}
+			if tracks != 2 {
+				t.Errorf("Queue() = %d tracks, want 2", tracks)
+			}
+			if got := commands(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commands =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
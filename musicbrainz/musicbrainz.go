@@ -1,18 +1,64 @@
 package musicbrainz
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/b0bbywan/go-disc-cuer/types"
 )
 
 const (
 	mbURL = "https://musicbrainz.org/ws/2"
+
+	// releaseIncludes are the subqueries of a release lookup: the disc IDs to rebuild the TOC, and
+	// the ISRCs and work relations to fill the burning metadata.
+	releaseIncludes = "artists+recordings+discids+release-groups+isrcs+recording-level-rels+work-rels+work-level-rels+artist-rels"
 )
 
+// ErrNotFound is returned when MusicBrainz has no release matching the query.
+var ErrNotFound = errors.New("no release data found")
+
+// songwriterRelations are the work relationship types whose artists are credited as songwriters.
+var songwriterRelations = map[string]bool{"composer": true, "lyricist": true, "writer": true}
+
+// Client queries the MusicBrainz web service with a given HTTP client.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	logger     *slog.Logger
+}
+
+// defaultClient is used by the package-level functions.
+var defaultClient = NewClient(nil, nil)
+
+// NewClient creates a MusicBrainz client.
+//
+// Parameters:
+//   - httpClient (*http.Client): The HTTP client used for requests. Uses http.DefaultClient if nil.
+//   - logger (*slog.Logger): The logger for request tracing. Discards the traces if nil.
+//
+// Returns:
+//   - *Client: A MusicBrainz client.
+func NewClient(httpClient *http.Client, logger *slog.Logger) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    mbURL,
+		logger:     logger,
+	}
+}
+
 // FetchReleaseByID fetches a MusicBrainz release's information based on its release ID.
 //
 // Parameters:
@@ -22,13 +68,67 @@ const (
 //   - *types.DiscInfo: A struct containing the release's metadata (artist, title, tracks, etc.).
 //   - error: An error if the release data cannot be fetched or parsed.
 func FetchReleaseByID(releaseID string) (*types.DiscInfo, error) {
-	url := fmt.Sprintf("%s/release/%s?inc=artists+recordings&fmt=json", mbURL, releaseID)
-	var release types.MBRelease
-	if err := fetchJSON(url, &release); err != nil {
+	return defaultClient.FetchReleaseByID(context.Background(), releaseID)
+}
+
+// FetchReleaseByID fetches a MusicBrainz release's information based on its release ID.
+// See the package-level FetchReleaseByID. The tracks are those of the first medium.
+func (c *Client) FetchReleaseByID(ctx context.Context, releaseID string) (*types.DiscInfo, error) {
+	media, err := c.FetchReleaseMedia(ctx, releaseID)
+	if err != nil {
 		return nil, err
 	}
-	return convertReleaseToDiscInfo(release)
+	return media[0].DiscInfo, nil
+}
 
+// Medium is a disc of a MusicBrainz release.
+//
+// Fields:
+//   - Position (int): The position of the medium in the release, from 1.
+//   - DiscInfo (*types.DiscInfo): The release metadata, with the tracks of the medium.
+//   - Discs ([]types.MBDisc): The disc IDs attached to the medium, possibly empty.
+type Medium struct {
+	Position int
+	DiscInfo *types.DiscInfo
+	Discs    []types.MBDisc
+}
+
+// FetchReleaseMedia fetches a MusicBrainz release's information for each of its media, along with
+// the disc IDs attached to them, allowing to rebuild the disc TOCs without reading the drive.
+//
+// Parameters:
+//   - releaseID (string): The MusicBrainz release ID (e.g., `ab123456-7890-1234-5678-abcdef123456`).
+//
+// Returns:
+//   - []Medium: The media of the release in order, at least one.
+//   - error: An error if the release data cannot be fetched or parsed.
+func FetchReleaseMedia(releaseID string) ([]Medium, error) {
+	return defaultClient.FetchReleaseMedia(context.Background(), releaseID)
+}
+
+// FetchReleaseMedia fetches a MusicBrainz release's information for each of its media, along with
+// the disc IDs attached to them. See the package-level FetchReleaseMedia.
+func (c *Client) FetchReleaseMedia(ctx context.Context, releaseID string) ([]Medium, error) {
+	url := fmt.Sprintf("%s/release/%s?inc=%s&fmt=json", c.baseURL, releaseID, releaseIncludes)
+	var release types.MBRelease
+	if err := c.fetchJSON(ctx, url, &release); err != nil {
+		return nil, err
+	}
+	if len(release.Media) == 0 {
+		return nil, errors.New("release has no media")
+	}
+	media := make([]Medium, len(release.Media))
+	for i, medium := range release.Media {
+		discInfo, err := convertReleaseToDiscInfo(release, i)
+		if err != nil {
+			return nil, err
+		}
+		media[i] = Medium{Position: discInfo.Medium, DiscInfo: discInfo, Discs: medium.Discs}
+		if media[i].Position == 0 {
+			media[i].Position = i + 1
+		}
+	}
+	return media, nil
 }
 
 // FetchReleaseByToc fetches a MusicBrainz release's information based on its TOC (Table of Contents).
@@ -40,58 +140,128 @@ func FetchReleaseByID(releaseID string) (*types.DiscInfo, error) {
 //   - *types.DiscInfo: A struct containing the release's metadata (artist, title, tracks, etc.).
 //   - error: An error if no release data is found or if the request fails.
 func FetchReleaseByToc(mbToc string) (*types.DiscInfo, error) {
-	url := fmt.Sprintf("%s/discid/-?toc=%s&inc=artists+recordings&fmt=json", mbURL, mbToc)
+	return defaultClient.FetchReleaseByToc(context.Background(), mbToc)
+}
+
+// FetchReleaseByToc fetches a MusicBrainz release's information based on its TOC.
+// See the package-level FetchReleaseByToc.
+func (c *Client) FetchReleaseByToc(ctx context.Context, mbToc string) (*types.DiscInfo, error) {
+	url := fmt.Sprintf("%s/discid/-?toc=%s&inc=artists+recordings+release-groups+isrcs&fmt=json", c.baseURL, mbToc)
 	var result types.ReleaseResult
-	if err := fetchJSON(url, &result); err != nil {
+	if err := c.fetchJSON(ctx, url, &result); err != nil {
 		return nil, err
 	}
 
 	if len(result.Releases) == 0 {
-		return nil, errors.New("no release data found")
+		return nil, ErrNotFound
 	}
 
 	release := result.Releases[0]
-	return convertReleaseToDiscInfo(release)
+	return convertReleaseToDiscInfo(release, 0)
 }
 
-// convertReleaseToDiscInfo converts a MusicBrainz release object to a DiscInfo object.
+// convertReleaseToDiscInfo converts a medium of a MusicBrainz release object to a DiscInfo object.
 //
 // Parameters:
 //   - release (types.MBRelease): A MusicBrainz release object containing the metadata.
+//   - medium (int): The index of the medium whose tracks are used.
 //
 // Returns:
 //   - *types.DiscInfo: A struct with the converted disc information (artist, title, release date, tracks).
 //   - error: An error if any data is missing or cannot be converted.
-func convertReleaseToDiscInfo(release types.MBRelease) (*types.DiscInfo, error) {
-	tracks := make([]string, len(release.Media[0].Tracks))
-	for i, track := range release.Media[0].Tracks {
+func convertReleaseToDiscInfo(release types.MBRelease, medium int) (*types.DiscInfo, error) {
+	if medium >= len(release.Media) {
+		return nil, errors.New("release has no media")
+	}
+	if len(release.ArtistCredit) == 0 {
+		return nil, errors.New("release has no artist credit")
+	}
+	mbTracks := release.Media[medium].Tracks
+	tracks := make([]string, len(mbTracks))
+	isrcs := make([]string, len(mbTracks))
+	songwriters := make([]string, len(mbTracks))
+	var hasISRC, hasSongwriter bool
+	for i, track := range mbTracks {
 		tracks[i] = track.Title
+		if len(track.Recording.ISRCs) > 0 {
+			isrcs[i] = track.Recording.ISRCs[0]
+			hasISRC = true
+		}
+		if songwriters[i] = trackSongwriters(track); songwriters[i] != "" {
+			hasSongwriter = true
+		}
 	}
 
-	return &types.DiscInfo{
-		ID:          release.ID,
-		Title:       release.Title,
-		Artist:      release.ArtistCredit[0].Name,
-		ReleaseDate: release.Date,
-		Tracks:      tracks,
-	}, nil
+	discInfo := &types.DiscInfo{
+		ID:             release.ID,
+		ReleaseGroupID: release.ReleaseGroup.ID,
+		Title:          release.Title,
+		Artist:         release.ArtistCredit[0].Name,
+		ReleaseDate:    release.Date,
+		Tracks:         tracks,
+		Barcode:        release.Barcode,
+	}
+	if len(release.Media) > 1 {
+		discInfo.Medium = release.Media[medium].Position
+		if discInfo.Medium == 0 {
+			discInfo.Medium = medium + 1
+		}
+	}
+	if hasISRC {
+		discInfo.ISRCs = isrcs
+	}
+	if hasSongwriter {
+		discInfo.Songwriters = songwriters
+	}
+	return discInfo, nil
+}
+
+// trackSongwriters returns the composers, lyricists and writers of the works performed in a track,
+// joined by ", ", or "" if the track has no work relation.
+func trackSongwriters(track types.MBTrack) string {
+	var names []string
+	seen := map[string]bool{}
+	for _, relation := range track.Recording.Relations {
+		if relation.Type != "performance" || relation.Work == nil {
+			continue
+		}
+		for _, workRelation := range relation.Work.Relations {
+			if !songwriterRelations[workRelation.Type] || workRelation.Artist == nil || seen[workRelation.Artist.Name] {
+				continue
+			}
+			seen[workRelation.Artist.Name] = true
+			names = append(names, workRelation.Artist.Name)
+		}
+	}
+	return strings.Join(names, ", ")
 }
 
 // fetchJSON performs an HTTP GET request to fetch JSON data from a URL and decodes it into the target structure.
 //
 // Parameters:
+//   - ctx (context.Context): The context of the request.
 //   - url (string): The URL to fetch the JSON data from.
 //   - target (interface{}): A pointer to the target structure where the JSON response will be decoded.
 //
 // Returns:
 //   - error: An error if the request fails or if the response cannot be parsed.
-func fetchJSON(url string, target interface{}) error {
-	resp, err := http.Get(url)
+func (c *Client) fetchJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	c.logger.Debug("MusicBrainz request", "url", url)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w at URL %s", ErrNotFound, url)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("error: failed to fetch from URL %s, status code: %d", url, resp.StatusCode)
 	}
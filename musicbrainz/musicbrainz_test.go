@@ -0,0 +1,104 @@
+package musicbrainz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const twoMediaRelease = `{
+  "id": "rel",
+  "title": "Album",
+  "artist-credit": [{"name": "Artist"}],
+  "media": [
+    {"position": 1, "tracks": [{"title": "A1"}, {"title": "A2"}], "discs": [{"id": "disc1", "sectors": 1000, "offset-count": 2, "offsets": [150, 500]}]},
+    {"position": 2, "tracks": [{"title": "B1"}], "discs": [{"id": "disc2a", "sectors": 900, "offset-count": 1, "offsets": [150]}, {"id": "disc2b", "sectors": 950, "offset-count": 1, "offsets": [150]}]}
+  ]
+}`
+
+// newTestClient returns a client of a server answering every request with status and body.
+func newTestClient(t *testing.T, status int, body string) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient(server.Client(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	client.baseURL = server.URL
+	return client
+}
+
+func TestFetchReleaseMedia(t *testing.T) {
+	client := newTestClient(t, http.StatusOK, twoMediaRelease)
+	media, err := client.FetchReleaseMedia(context.Background(), "rel")
+	if err != nil {
+		t.Fatalf("FetchReleaseMedia() error = %v", err)
+	}
+	if len(media) != 2 {
+		t.Fatalf("FetchReleaseMedia() returned %d media, want 2", len(media))
+	}
+	tests := []struct {
+		position int
+		tracks   []string
+		discs    []string
+	}{
+		{position: 1, tracks: []string{"A1", "A2"}, discs: []string{"disc1"}},
+		{position: 2, tracks: []string{"B1"}, discs: []string{"disc2a", "disc2b"}},
+	}
+	for i, tt := range tests {
+		medium := media[i]
+		if medium.Position != tt.position || medium.DiscInfo.Medium != tt.position {
+			t.Errorf("medium %d: Position = %d, DiscInfo.Medium = %d, want %d", i, medium.Position, medium.DiscInfo.Medium, tt.position)
+		}
+		if !reflect.DeepEqual(medium.DiscInfo.Tracks, tt.tracks) {
+			t.Errorf("medium %d: Tracks = %v, want %v", i, medium.DiscInfo.Tracks, tt.tracks)
+		}
+		var discs []string
+		for _, disc := range medium.Discs {
+			discs = append(discs, disc.ID)
+		}
+		if !reflect.DeepEqual(discs, tt.discs) {
+			t.Errorf("medium %d: Discs = %v, want %v", i, discs, tt.discs)
+		}
+		if medium.DiscInfo.Artist != "Artist" || medium.DiscInfo.Title != "Album" {
+			t.Errorf("medium %d: DiscInfo = %+v, want the release artist and title", i, medium.DiscInfo)
+		}
+	}
+
+	info, err := client.FetchReleaseByID(context.Background(), "rel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Tracks, []string{"A1", "A2"}) {
+		t.Errorf("FetchReleaseByID() tracks = %v, want those of the first medium", info.Tracks)
+	}
+}
+
+func TestFetchReleaseMediaSingleMedium(t *testing.T) {
+	client := newTestClient(t, http.StatusOK, `{"id": "rel", "title": "Album", "artist-credit": [{"name": "Artist"}],
+		"media": [{"position": 1, "tracks": [{"title": "A1"}]}]}`)
+	media, err := client.FetchReleaseMedia(context.Background(), "rel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(media) != 1 || media[0].Position != 1 || media[0].DiscInfo.Medium != 0 {
+		t.Errorf("FetchReleaseMedia() = %+v, want one medium at position 1 without DiscInfo.Medium", media)
+	}
+}
+
+func TestFetchReleaseMediaErrors(t *testing.T) {
+	client := newTestClient(t, http.StatusOK, `{"id": "rel", "title": "Album", "artist-credit": [{"name": "Artist"}], "media": []}`)
+	if _, err := client.FetchReleaseMedia(context.Background(), "rel"); err == nil {
+		t.Error("FetchReleaseMedia() without media: error = nil")
+	}
+	client = newTestClient(t, http.StatusNotFound, `{"error": "Not Found"}`)
+	if _, err := client.FetchReleaseMedia(context.Background(), "rel"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FetchReleaseMedia() on 404: error = %v, want ErrNotFound", err)
+	}
+}
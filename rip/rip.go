@@ -0,0 +1,306 @@
+// Package rip rips the tracks of a disc with an external ripper, encodes them with an external
+// encoder, and writes them into the music library next to a CUE sheet referencing them.
+package rip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/library"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// coverName is the name of the front cover written next to the sheet, followed by the image extension.
+const coverName = "cover"
+
+// Options selects how and where a disc is ripped.
+//
+// Fields:
+//   - Root (string): The library folder the template paths are relative to.
+//   - Template (string): The path template of the sheet, see library.ParseTemplate. The tracks are
+//     written in the folder of the sheet.
+//   - Ripper (Ripper): Extracts the tracks.
+//   - Encoder (Encoder): Encodes the extracted tracks.
+//   - Overwrite (bool): Rips again the tracks already in the library, else they are kept.
+//   - Progress (func(track, total int, path string)): Called, if set, after each track written or kept.
+type Options struct {
+	Root      string
+	Template  string
+	Ripper    Ripper
+	Encoder   Encoder
+	Overwrite bool
+	Progress  func(track, total int, path string)
+}
+
+// Result describes the files written for a disc.
+//
+// Fields:
+//   - DiscID (string): The disc ID.
+//   - Sheet (string): The path of the CUE sheet referencing the tracks.
+//   - Cover (string): The path of the front cover, "" if the disc has none.
+//   - Tracks ([]string): The paths of the tracks, in order.
+//   - Skipped ([]string): The tracks kept because they were already in the library.
+type Result struct {
+	DiscID  string
+	Sheet   string
+	Cover   string
+	Tracks  []string
+	Skipped []string
+}
+
+// Rip rips a cached disc into the music library: each track is extracted from the drive, encoded and
+// tagged with the disc metadata, then the cached CUE sheet is written next to the tracks with its
+// FILE entries pointing to them. The disc in the drive must be the cached one.
+//
+// Parameters:
+//   - ctx: Interrupts the ripper and the encoder when canceled.
+//   - store: The cache store holding the disc metadata.
+//   - discID: The disc ID, or a FreeDB ID alias.
+//   - device: The drive holding the disc.
+//   - opts: How and where the disc is ripped.
+//
+// Returns:
+//   - Result: The written paths, possibly partial on error.
+//   - error: An error if the disc is not cached, the template is invalid, or a track cannot be ripped,
+//     encoded or written.
+func Rip(ctx context.Context, store cache.Store, discID, device string, opts Options) (Result, error) {
+	discID = store.Resolve(discID)
+	result := Result{DiscID: discID}
+	if opts.Ripper == nil || opts.Encoder == nil {
+		return result, fmt.Errorf("Failed to rip %s: missing ripper or encoder", discID)
+	}
+	metadata, err := cache.GetMetadata(store, discID)
+	if err != nil {
+		return result, fmt.Errorf("Failed to read %s metadata: %w", discID, err)
+	}
+	info := metadata.DiscInfo
+	if len(info.Tracks) == 0 {
+		return result, fmt.Errorf("Failed to rip %s: no tracks in metadata", discID)
+	}
+	template, err := library.ParseTemplate(opts.Template)
+	if err != nil {
+		return result, err
+	}
+	sheet := filepath.Join(opts.Root, template.Expand(library.NewFields(metadata)))
+	dir := filepath.Dir(sheet)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return result, fmt.Errorf("Failed to create folder for %s: %w", sheet, err)
+	}
+
+	if front := cache.FrontCover(store, discID); front != "" {
+		cover := filepath.Join(dir, coverName+filepath.Ext(front))
+		if err := writeCached(store, discID, front, cover); err != nil {
+			return result, err
+		}
+		result.Cover = cover
+	}
+
+	// The tracks are extracted and encoded in a temporary folder on the same filesystem, then
+	// renamed, so that an interrupted rip leaves no partial track
+	tmp, err := os.MkdirTemp(dir, ".rip-")
+	if err != nil {
+		return result, fmt.Errorf("Failed to create temporary folder: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	picture, err := embeddedPicture(result.Cover, tmp)
+	if err != nil {
+		return result, err
+	}
+
+	first := 1
+	if toc, err := utils.ParseMusicBrainzToc(metadata.Toc); err == nil {
+		first = toc.FirstTrack
+	}
+	total := len(info.Tracks)
+	names := make([]string, total)
+	for i, title := range info.Tracks {
+		number := first + i
+		names[i] = trackName(number, title, opts.Encoder.Extension())
+		dest := filepath.Join(dir, names[i])
+		result.Tracks = append(result.Tracks, dest)
+		if _, err := os.Stat(dest); err == nil && !opts.Overwrite {
+			result.Skipped = append(result.Skipped, dest)
+		} else if err := ripTrack(ctx, opts, device, number, tmp, dest, trackTags(metadata, i, first, total), picture); err != nil {
+			return result, fmt.Errorf("Failed to rip track %d of %s: %w", number, discID, err)
+		}
+		if opts.Progress != nil {
+			opts.Progress(i+1, total, dest)
+		}
+	}
+
+	var data []byte
+	if store.Has(discID, cache.PlaylistFile) {
+		if data, err = store.Get(discID, cache.PlaylistFile); err != nil {
+			return result, fmt.Errorf("Failed to read %s/%s: %w", discID, cache.PlaylistFile, err)
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := (cue.CueWriter{}).Write(&buf, &info, nil); err != nil {
+			return result, fmt.Errorf("Failed to render %s sheet: %w", discID, err)
+		}
+		data = buf.Bytes()
+	}
+	var cover string
+	if result.Cover != "" {
+		cover = filepath.Base(result.Cover)
+	}
+	data = RewriteSheet(data, names, cover)
+	if err := utils.WriteFileAtomic(sheet, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return result, fmt.Errorf("Failed to write %s: %w", sheet, err)
+	}
+	result.Sheet = sheet
+	return result, nil
+}
+
+// ripTrack extracts a track to a WAV file in tmp, encodes it next to it, then moves it to dest.
+func ripTrack(ctx context.Context, opts Options, device string, number int, tmp, dest string, tags []Tag, picture string) error {
+	wav := filepath.Join(tmp, fmt.Sprintf("%02d.wav", number))
+	if err := opts.Ripper.Rip(ctx, device, number, wav); err != nil {
+		return err
+	}
+	defer os.Remove(wav)
+	encoded := filepath.Join(tmp, filepath.Base(dest))
+	if err := opts.Encoder.Encode(ctx, wav, encoded, tags, picture); err != nil {
+		return err
+	}
+	return os.Rename(encoded, dest)
+}
+
+// trackName returns the file name of a track, "NN - Title.ext", or "NN.ext" without title.
+func trackName(number int, title, ext string) string {
+	if strings.TrimSpace(title) == "" {
+		return fmt.Sprintf("%02d%s", number, ext)
+	}
+	return library.SanitizeName(fmt.Sprintf("%02d - %s%s", number, title, ext))
+}
+
+// embeddedPicture returns the image embedded in the tracks: cover if it is a JPEG or PNG image, the
+// formats of the FLAC pictures, else its conversion to JPEG written in tmp. It returns "" without cover.
+func embeddedPicture(cover, tmp string) (string, error) {
+	if cover == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(cover)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read %s: %w", cover, err)
+	}
+	if format, err := utils.SniffImageFormat(data); err == nil && format != utils.ImageWebP {
+		return cover, nil
+	}
+	converted, err := utils.ConvertToJPEG(data)
+	if err != nil {
+		return "", fmt.Errorf("Failed to convert %s: %w", cover, err)
+	}
+	picture := filepath.Join(tmp, coverName+utils.ImageExt(utils.ImageJPEG))
+	if err := os.WriteFile(picture, converted, 0644); err != nil {
+		return "", fmt.Errorf("Failed to write %s: %w", picture, err)
+	}
+	return picture, nil
+}
+
+// trackTags returns the Vorbis comments of the track at index i of a disc whose first track is numbered
+// first. TRACKTOTAL is the number of the last track, so that it is never below TRACKNUMBER.
+func trackTags(metadata *cache.Metadata, i, first, total int) []Tag {
+	info := metadata.DiscInfo
+	tags := []Tag{
+		{"TITLE", info.Tracks[i]},
+		{"ARTIST", info.Artist},
+		{"ALBUMARTIST", info.Artist},
+		{"ALBUM", info.Title},
+		{"TRACKNUMBER", fmt.Sprint(first + i)},
+		{"TRACKTOTAL", fmt.Sprint(first + total - 1)},
+		{"DATE", info.ReleaseDate},
+		{"GENRE", info.Genre},
+		{"BARCODE", info.Barcode},
+		{"MUSICBRAINZ_ALBUMID", metadata.MusicBrainzID},
+		{"MUSICBRAINZ_RELEASEGROUPID", info.ReleaseGroupID},
+	}
+	if i < len(info.ISRCs) {
+		tags = append(tags, Tag{"ISRC", info.ISRCs[i]})
+	}
+	kept := tags[:0]
+	for _, tag := range tags {
+		if tag.Value != "" {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+// writeCached writes a cached file to dest, unless dest already holds the same content.
+func writeCached(store cache.Store, discID, name, dest string) error {
+	data, err := store.Get(discID, name)
+	if err != nil {
+		return fmt.Errorf("Failed to read %s/%s: %w", discID, name, err)
+	}
+	if existing, err := os.ReadFile(dest); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if err := utils.WriteFileAtomic(dest, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return fmt.Errorf("Failed to write %s: %w", dest, err)
+	}
+	return nil
+}
+
+// RewriteSheet points the FILE entries of a CUE sheet to ripped track files: the FILE entry of the
+// n-th track becomes the n-th file, and tracks without INDEX get an "INDEX 01 00:00:00" at the start
+// of their file. The REM COVER path becomes cover, if not empty.
+//
+// Parameters:
+//   - sheet: The CUE sheet, with a FILE entry per track such as those written by cue.CueWriter.
+//   - files: The track file names, relative to the sheet, in order, without double quotes.
+//   - cover: The front cover file name, relative to the sheet, or "" to keep the REM COVER line.
+//
+// Returns:
+//   - []byte: The rewritten sheet.
+func RewriteSheet(sheet []byte, files []string, cover string) []byte {
+	var out bytes.Buffer
+	// file counts the FILE entries seen, indexed is set once the current track has an INDEX
+	file, inTrack, indexed := 0, false, false
+	closeTrack := func() {
+		if inTrack && !indexed {
+			out.WriteString("    INDEX 01 00:00:00\n")
+		}
+		inTrack, indexed = false, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(sheet))
+	for scanner.Scan() {
+		line := scanner.Text()
+		keyword, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch keyword {
+		case "FILE":
+			closeTrack()
+			if file < len(files) {
+				line = fmt.Sprintf("FILE \"%s\" WAVE", files[file])
+			}
+			file++
+		case "TRACK":
+			closeTrack()
+			inTrack = true
+		case "INDEX":
+			indexed = true
+		case "REM":
+			if cover != "" && strings.HasPrefix(strings.TrimSpace(line), "REM COVER ") {
+				line = fmt.Sprintf("REM COVER \"%s\"", cover)
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	closeTrack()
+	return out.Bytes()
+}
@@ -0,0 +1,340 @@
+package rip
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	imagepng "image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// webpImage is a 1x1 lossless WebP image.
+const webpImage = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+// fakeRipper writes a WAV file naming the track, recording the tracks ripped.
+type fakeRipper struct {
+	tracks []int
+}
+
+func (f *fakeRipper) Rip(ctx context.Context, device string, track int, wav string) error {
+	f.tracks = append(f.tracks, track)
+	return os.WriteFile(wav, []byte(fmt.Sprintf("%s track %d", device, track)), 0644)
+}
+
+// fakeEncoder copies the WAV file to dest followed by the tags, failing on the failOn-th call if set.
+// It records the format of the pictures embedded, "" for none.
+type fakeEncoder struct {
+	calls    int
+	failOn   int
+	wavs     []string
+	pictures []string
+}
+
+func (f *fakeEncoder) Extension() string {
+	return ".flac"
+}
+
+func (f *fakeEncoder) Encode(ctx context.Context, wav, dest string, tags []Tag, picture string) error {
+	f.calls++
+	f.wavs = append(f.wavs, wav)
+	format := ""
+	if picture != "" {
+		data, err := os.ReadFile(picture)
+		if err != nil {
+			return err
+		}
+		if format, err = utils.SniffImageFormat(data); err != nil {
+			return err
+		}
+	}
+	f.pictures = append(f.pictures, format)
+	if f.calls == f.failOn {
+		// Leave a partial file behind, as a real encoder would
+		os.WriteFile(dest, []byte("partial"), 0644)
+		return errors.New("encoder failed")
+	}
+	data, err := os.ReadFile(wav)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		data = append(data, fmt.Sprintf("\n%s=%s", tag.Name, tag.Value)...)
+	}
+	return os.WriteFile(dest, data, 0644)
+}
+
+// newTestStore returns a store holding a disc with tracks starting at track 3 and its CUE sheet.
+func newTestStore(t *testing.T) cache.Store {
+	t.Helper()
+	store := cache.NewDirStore(t.TempDir())
+	metadata := &cache.Metadata{
+		Version: 1,
+		DiscID:  "disc",
+		Toc:     "3 4 90000 150 30000",
+		Source:  "manual",
+		DiscInfo: types.DiscInfo{
+			Artist: "Artist",
+			Title:  "Album",
+			Tracks: []string{"First", "Sec/ond"},
+			ISRCs:  []string{"USABC0000001", ""},
+		},
+	}
+	if err := cache.PutMetadata(store, metadata); err != nil {
+		t.Fatal(err)
+	}
+	sheet := "PERFORMER \"Artist\"\nTITLE \"Album\"\n" +
+		"FILE \"cdda:///1\" WAVE\n  TRACK 01 AUDIO\n    TITLE \"First\"\n" +
+		"FILE \"cdda:///2\" WAVE\n  TRACK 02 AUDIO\n    TITLE \"Sec/ond\"\n"
+	if err := store.Put("disc", cache.PlaylistFile, []byte(sheet)); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// assertNoTempDir fails if a temporary folder of Rip is left in dir.
+func assertNoTempDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".rip-") {
+			t.Errorf("temporary folder %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestRip(t *testing.T) {
+	store := newTestStore(t)
+	root := t.TempDir()
+	ripper, encoder := &fakeRipper{}, &fakeEncoder{}
+	var progress []int
+	result, err := Rip(context.Background(), store, "disc", "/dev/sr1", Options{
+		Root:     root,
+		Template: "{artist}/{album}/{album}.cue",
+		Ripper:   ripper,
+		Encoder:  encoder,
+		Progress: func(track, total int, path string) { progress = append(progress, track) },
+	})
+	if err != nil {
+		t.Fatalf("Rip() error = %v", err)
+	}
+
+	// Tracks are ripped by their number on the disc, from the first track of the TOC
+	if want := []int{3, 4}; !reflect.DeepEqual(ripper.tracks, want) {
+		t.Errorf("ripped tracks = %v, want %v", ripper.tracks, want)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+	dir := filepath.Join(root, "Artist", "Album")
+	wantTracks := []string{filepath.Join(dir, "03 - First.flac"), filepath.Join(dir, "04 - Sec_ond.flac")}
+	if !reflect.DeepEqual(result.Tracks, wantTracks) {
+		t.Errorf("Tracks = %v, want %v", result.Tracks, wantTracks)
+	}
+	if result.Sheet != filepath.Join(dir, "Album.cue") {
+		t.Errorf("Sheet = %q", result.Sheet)
+	}
+
+	data, err := os.ReadFile(wantTracks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/dev/sr1 track 3", "TITLE=First", "TRACKNUMBER=3", "TRACKTOTAL=4", "ISRC=USABC0000001"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("track 3 = %q, missing %q", data, want)
+		}
+	}
+	if data, _ := os.ReadFile(wantTracks[1]); strings.Contains(string(data), "ISRC=") {
+		t.Errorf("track 4 = %q, want no empty ISRC", data)
+	}
+
+	sheet, err := os.ReadFile(result.Sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"FILE \"03 - First.flac\" WAVE", "FILE \"04 - Sec_ond.flac\" WAVE"} {
+		if !strings.Contains(string(sheet), want) {
+			t.Errorf("sheet = %q, missing %q", sheet, want)
+		}
+	}
+	for _, wav := range encoder.wavs {
+		if _, err := os.Stat(wav); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("WAV file %s left behind", wav)
+		}
+	}
+	assertNoTempDir(t, dir)
+}
+
+func TestRipCover(t *testing.T) {
+	webp, err := base64.StdEncoding.DecodeString(webpImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var png bytes.Buffer
+	if err := imagepng.Encode(&png, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file        string
+		data        []byte
+		wantPicture string
+	}{
+		{file: "front.png", data: png.Bytes(), wantPicture: utils.ImagePNG},
+		// FLAC pictures cannot be WebP images
+		{file: "front.webp", data: webp, wantPicture: utils.ImageJPEG},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			store := newTestStore(t)
+			if err := store.Put("disc", tt.file, tt.data); err != nil {
+				t.Fatal(err)
+			}
+			index := &cache.CoverIndex{Images: []cache.CoverImage{{Type: cache.CoverFront, File: tt.file}}}
+			if err := cache.PutCoverIndex(store, "disc", index); err != nil {
+				t.Fatal(err)
+			}
+			root := t.TempDir()
+			encoder := &fakeEncoder{}
+			result, err := Rip(context.Background(), store, "disc", "", Options{
+				Root:     root,
+				Template: "{album}/{album}.cue",
+				Ripper:   &fakeRipper{},
+				Encoder:  encoder,
+			})
+			if err != nil {
+				t.Fatalf("Rip() error = %v", err)
+			}
+			if want := filepath.Join(root, "Album", "cover"+filepath.Ext(tt.file)); result.Cover != want {
+				t.Errorf("Cover = %q, want %q", result.Cover, want)
+			}
+			if data, err := os.ReadFile(result.Cover); err != nil || !bytes.Equal(data, tt.data) {
+				t.Errorf("cover differs from the cached one: %v", err)
+			}
+			if want := []string{tt.wantPicture, tt.wantPicture}; !reflect.DeepEqual(encoder.pictures, want) {
+				t.Errorf("embedded pictures = %v, want %v", encoder.pictures, want)
+			}
+			assertNoTempDir(t, filepath.Dir(result.Sheet))
+		})
+	}
+}
+
+func TestRipKeepsExistingTracks(t *testing.T) {
+	store := newTestStore(t)
+	root := t.TempDir()
+	opts := Options{Root: root, Template: "{album}/{album}.cue", Ripper: &fakeRipper{}, Encoder: &fakeEncoder{}}
+	if _, err := Rip(context.Background(), store, "disc", "", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	ripper := &fakeRipper{}
+	opts.Ripper = ripper
+	result, err := Rip(context.Background(), store, "disc", "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ripper.tracks) != 0 {
+		t.Errorf("ripped tracks = %v, want none", ripper.tracks)
+	}
+	if !reflect.DeepEqual(result.Skipped, result.Tracks) {
+		t.Errorf("Skipped = %v, want %v", result.Skipped, result.Tracks)
+	}
+
+	opts.Overwrite = true
+	if _, err := Rip(context.Background(), store, "disc", "", opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{3, 4}; !reflect.DeepEqual(ripper.tracks, want) {
+		t.Errorf("ripped tracks with Overwrite = %v, want %v", ripper.tracks, want)
+	}
+}
+
+func TestRipEncoderFailure(t *testing.T) {
+	store := newTestStore(t)
+	root := t.TempDir()
+	encoder := &fakeEncoder{failOn: 2}
+	result, err := Rip(context.Background(), store, "disc", "", Options{
+		Root:     root,
+		Template: "{album}/{album}.cue",
+		Ripper:   &fakeRipper{},
+		Encoder:  encoder,
+	})
+	if err == nil || !strings.Contains(err.Error(), "encoder failed") {
+		t.Fatalf("Rip() error = %v, want the encoder error", err)
+	}
+	if result.Sheet != "" {
+		t.Errorf("Sheet = %q, want none on failure", result.Sheet)
+	}
+
+	dir := filepath.Join(root, "Album")
+	if _, err := os.Stat(filepath.Join(dir, "03 - First.flac")); err != nil {
+		t.Errorf("track 3 missing: %v", err)
+	}
+	// The partial file of the failed track stays in the removed temporary folder
+	for _, name := range []string{"04 - Sec_ond.flac", "Album.cue"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists after the failure", name)
+		}
+	}
+	assertNoTempDir(t, dir)
+}
+
+func TestRipRequiresTools(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := Rip(context.Background(), store, "disc", "", Options{Root: t.TempDir(), Template: "{album}.cue"}); err == nil {
+		t.Error("Rip() without ripper and encoder: error = nil")
+	}
+}
+
+func TestRewriteSheet(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+		files []string
+		cover string
+		want  string
+	}{
+		{
+			name: "cdda sheet",
+			sheet: "REM DATE \"2024\"\nREM COVER \"/cache/disc/front.jpg\"\nPERFORMER \"Artist\"\nTITLE \"Album\"\n" +
+				"FILE \"cdda:///1\" WAVE\n  TRACK 01 AUDIO\n    TITLE \"First\"\n" +
+				"FILE \"cdda:///2\" WAVE\n  TRACK 02 AUDIO\n    TITLE \"Second\"\n",
+			files: []string{"01 - First.flac", "02 - Second.flac"},
+			cover: "cover.jpg",
+			want: "REM DATE \"2024\"\nREM COVER \"cover.jpg\"\nPERFORMER \"Artist\"\nTITLE \"Album\"\n" +
+				"FILE \"01 - First.flac\" WAVE\n  TRACK 01 AUDIO\n    TITLE \"First\"\n    INDEX 01 00:00:00\n" +
+				"FILE \"02 - Second.flac\" WAVE\n  TRACK 02 AUDIO\n    TITLE \"Second\"\n    INDEX 01 00:00:00\n",
+		},
+		{
+			name:  "existing indexes and cover kept",
+			sheet: "REM COVER \"front.png\"\nFILE \"a.wav\" WAVE\n  TRACK 01 AUDIO\n    INDEX 01 00:00:00\n  TRACK 02 AUDIO\n    INDEX 00 01:00:00\n    INDEX 01 01:02:00\n",
+			files: []string{"a.flac"},
+			want:  "REM COVER \"front.png\"\nFILE \"a.flac\" WAVE\n  TRACK 01 AUDIO\n    INDEX 01 00:00:00\n  TRACK 02 AUDIO\n    INDEX 00 01:00:00\n    INDEX 01 01:02:00\n",
+		},
+		{
+			name:  "more entries than files",
+			sheet: "FILE \"cdda:///1\" WAVE\n  TRACK 01 AUDIO\nFILE \"cdda:///2\" WAVE\n  TRACK 02 AUDIO\n",
+			files: []string{"01.flac"},
+			want:  "FILE \"01.flac\" WAVE\n  TRACK 01 AUDIO\n    INDEX 01 00:00:00\nFILE \"cdda:///2\" WAVE\n  TRACK 02 AUDIO\n    INDEX 01 00:00:00\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(RewriteSheet([]byte(tt.sheet), tt.files, tt.cover)); got != tt.want {
+				t.Errorf("RewriteSheet() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
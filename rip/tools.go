@@ -0,0 +1,137 @@
+package rip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Ripper extracts the audio of a disc track to a WAV file.
+type Ripper interface {
+	// Rip extracts track, numbered as on the disc, from the disc in device to the WAV file wav.
+	Rip(ctx context.Context, device string, track int, wav string) error
+}
+
+// Encoder encodes a WAV file, tagging the result.
+type Encoder interface {
+	// Extension returns the extension of the encoded files, e.g. ".flac".
+	Extension() string
+	// Encode encodes wav to dest with the tags, embedding the picture image file if not empty.
+	Encode(ctx context.Context, wav, dest string, tags []Tag, picture string) error
+}
+
+// Tag is a metadata field of an encoded track, e.g. {"TITLE", "Song"}.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// ripperPrograms lists the programs searched by NewCdparanoia, in order.
+var ripperPrograms = []string{"cdparanoia", "cd-paranoia"}
+
+// ErrNoRipper is returned when neither cdparanoia nor cd-paranoia is installed.
+var ErrNoRipper = errors.New("cdparanoia or cd-paranoia not found in PATH")
+
+// Cdparanoia rips tracks with cdparanoia, or its libcdio port cd-paranoia, which take the same arguments.
+type Cdparanoia struct {
+	// Program is the path or name of the executable.
+	Program string
+}
+
+// NewCdparanoia returns a Cdparanoia ripper.
+//
+// Parameters:
+//   - program: The executable to run, or "" to search cdparanoia then cd-paranoia in PATH.
+//
+// Returns:
+//   - *Cdparanoia: The ripper.
+//   - error: ErrNoRipper if no program is given and none is installed.
+func NewCdparanoia(program string) (*Cdparanoia, error) {
+	if program != "" {
+		return &Cdparanoia{Program: program}, nil
+	}
+	for _, name := range ripperPrograms {
+		if path, err := exec.LookPath(name); err == nil {
+			return &Cdparanoia{Program: path}, nil
+		}
+	}
+	return nil, ErrNoRipper
+}
+
+// Rip runs "cdparanoia -q -w -d device track wav". A track number alone selects the whole track.
+func (c *Cdparanoia) Rip(ctx context.Context, device string, track int, wav string) error {
+	args := []string{"-q", "-w"}
+	if device != "" {
+		args = append(args, "-d", device)
+	}
+	args = append(args, strconv.Itoa(track), wav)
+	return run(exec.CommandContext(ctx, c.Program, args...))
+}
+
+// Flac encodes tracks with the flac command line encoder.
+type Flac struct {
+	// Program is the path or name of the executable.
+	Program string
+	// Compression is the compression level, from 0 (fastest) to 8 (smallest).
+	Compression int
+}
+
+// NewFlac returns a Flac encoder.
+//
+// Parameters:
+//   - program: The executable to run, "flac" if empty.
+//   - compression: The compression level, from 0 to 8.
+//
+// Returns:
+//   - *Flac: The encoder.
+//   - error: An error if the compression level is out of range or the program is not found.
+func NewFlac(program string, compression int) (*Flac, error) {
+	if program == "" {
+		program = "flac"
+	}
+	if compression < 0 || compression > 8 {
+		return nil, fmt.Errorf("invalid FLAC compression level %d: expected 0 to 8", compression)
+	}
+	if _, err := exec.LookPath(program); err != nil {
+		return nil, fmt.Errorf("FLAC encoder not found: %w", err)
+	}
+	return &Flac{Program: program, Compression: compression}, nil
+}
+
+// Extension returns ".flac".
+func (f *Flac) Extension() string {
+	return ".flac"
+}
+
+// Encode runs "flac --silent -f -N -o dest --tag=NAME=value... --picture=3||||picture wav".
+func (f *Flac) Encode(ctx context.Context, wav, dest string, tags []Tag, picture string) error {
+	args := []string{"--silent", "-f", "-" + strconv.Itoa(f.Compression), "-o", dest}
+	for _, tag := range tags {
+		args = append(args, "--tag="+tag.Name+"="+tag.Value)
+	}
+	if picture != "" {
+		// Front cover type, with the MIME type and dimensions read from the file
+		args = append(args, "--picture=3||||"+picture)
+	}
+	args = append(args, "--", wav)
+	return run(exec.CommandContext(ctx, f.Program, args...))
+}
+
+// run runs cmd, adding the last line of its output to the error on failure.
+func run(cmd *exec.Cmd) error {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			return fmt.Errorf("%s failed: %w: %s", cmd.Path, err, last)
+		}
+		return fmt.Errorf("%s failed: %w", cmd.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+	"github.com/b0bbywan/go-disc-cuer/watch"
+)
+
+// Event types published by a Broker.
+const (
+	// EventDiscInserted is published when an audio disc is inserted in the watched drive.
+	EventDiscInserted = "disc.inserted"
+	// EventDiscReady is published once the playlists of the inserted disc are generated.
+	EventDiscReady = "disc.ready"
+	// EventDiscError is published when the generation of the inserted disc failed.
+	EventDiscError = "disc.error"
+	// EventDiscEjected is published when the disc is removed from the watched drive.
+	EventDiscEjected = "disc.ejected"
+	// EventTocRead is published once the disc ID and TOC of a generation are known.
+	EventTocRead = "toc.read"
+	// EventProviderStarted is published before a metadata provider is queried.
+	EventProviderStarted = "provider.started"
+	// EventProviderFinished is published when a metadata provider found the disc.
+	EventProviderFinished = "provider.finished"
+	// EventProviderFailed is published when a metadata provider failed or did not find the disc.
+	EventProviderFailed = "provider.failed"
+	// EventPlaylistWritten is published for each CUE sheet or other playlist file written.
+	EventPlaylistWritten = "playlist.written"
+	// EventCoverFetched is published for each cover art image saved.
+	EventCoverFetched = "cover.fetched"
+)
+
+// subscriberBuffer is the number of events queued for a subscriber before new ones are dropped.
+const subscriberBuffer = 64
+
+// historySize is the number of past events kept to resume SSE streams after a reconnection.
+const historySize = 100
+
+// Event is a drive or generation event, as sent on /events and /events/ws.
+//
+// Fields:
+//   - ID (uint64): The sequence number of the event, increasing from 1.
+//   - Type (string): The event type, e.g. EventDiscInserted.
+//   - Time (time.Time): When the event was published.
+//   - Device (string): The drive, for the disc.* events.
+//   - DiscID (string): The disc ID, when known.
+//   - Toc (string): The TOC in MusicBrainz format, for EventTocRead.
+//   - Provider (string): The metadata provider, for the provider.* events.
+//   - Artist, Title (string): The metadata found, for EventProviderFinished.
+//   - Path (string): The cache.FileRef of the file, for EventPlaylistWritten and EventCoverFetched.
+//   - Error (string): The error, for EventProviderFailed and EventDiscError.
+//   - Disc (*cue.Report): The generation result, for EventDiscReady and EventDiscError.
+type Event struct {
+	ID       uint64      `json:"id"`
+	Type     string      `json:"type"`
+	Time     time.Time   `json:"time"`
+	Device   string      `json:"device,omitempty"`
+	DiscID   string      `json:"discId,omitempty"`
+	Toc      string      `json:"toc,omitempty"`
+	Provider string      `json:"provider,omitempty"`
+	Artist   string      `json:"artist,omitempty"`
+	Title    string      `json:"title,omitempty"`
+	Path     string      `json:"path,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Disc     *cue.Report `json:"disc,omitempty"`
+}
+
+// Broker fans the events out to the connected clients. It is safe for concurrent use.
+type Broker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+// NewBroker creates an empty Broker.
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[chan Event]struct{})}
+}
+
+// Publish numbers and timestamps event, then sends it to every subscriber. Subscribers too slow to
+// keep up miss the event rather than blocking the generation.
+func (b *Broker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.lastID++
+	event.ID = b.lastID
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.history = append(b.history, event)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a new subscriber.
+//
+// Parameters:
+//   - after: Replays the kept events with an ID greater than after. Zero replays none.
+//
+// Returns:
+//   - <-chan Event: The events, closed by the cancel function or when the broker is closed.
+//   - func(): Cancels the subscription.
+func (b *Broker) Subscribe(after uint64) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan Event, subscriberBuffer+historySize)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if after > 0 {
+		for _, event := range b.history {
+			if event.ID > after {
+				ch <- event
+			}
+		}
+	}
+	b.subscribers[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends every subscription, so that the event streams return, and drops later events.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Hooks returns the generator hooks publishing the generation events.
+func (b *Broker) Hooks() cue.Hooks {
+	return cue.Hooks{
+		OnTocRead: func(discID string, toc *utils.Toc) {
+			event := Event{Type: EventTocRead, DiscID: discID}
+			if toc != nil {
+				event.Toc = toc.MusicBrainzString()
+			}
+			b.Publish(event)
+		},
+		OnProviderStart: func(discID, provider string) {
+			b.Publish(Event{Type: EventProviderStarted, DiscID: discID, Provider: provider})
+		},
+		OnProviderDone: func(discID string, candidate cue.Candidate) {
+			if candidate.Err != nil {
+				b.Publish(Event{Type: EventProviderFailed, DiscID: discID, Provider: candidate.Source, Error: candidate.Err.Error()})
+				return
+			}
+			b.Publish(Event{
+				Type:     EventProviderFinished,
+				DiscID:   discID,
+				Provider: candidate.Source,
+				Artist:   candidate.DiscInfo.Artist,
+				Title:    candidate.DiscInfo.Title,
+			})
+		},
+		OnCoverFetched: func(discID, path string) {
+			b.Publish(Event{Type: EventCoverFetched, DiscID: discID, Path: path})
+		},
+		OnFileWritten: func(discID, path string) {
+			b.Publish(Event{Type: EventPlaylistWritten, DiscID: discID, Path: path})
+		},
+	}
+}
+
+// DriveChanges returns a watch.WithOnChange callback publishing the disc.* events of a drive. The
+// disc.ejected event carries the ID of the last disc generated, if any.
+func (b *Broker) DriveChanges() func(watch.State) {
+	previous, discID := watch.StateEmpty, ""
+	return func(state watch.State) {
+		if state.Disc != nil {
+			discID = state.Disc.DiscID
+		}
+		event := Event{Device: state.Device, Time: state.Since, DiscID: discID, Disc: state.Disc}
+		if state.Disc != nil {
+			event.Error = state.Disc.Error
+		}
+		switch state.State {
+		case watch.StateReading:
+			event.Type = EventDiscInserted
+		case watch.StateReady:
+			event.Type = EventDiscReady
+		case watch.StateError:
+			event.Type = EventDiscError
+		case watch.StateEmpty:
+			if previous != watch.StateEmpty {
+				event.Type = EventDiscEjected
+			}
+		}
+		previous = state.State
+		if state.State == watch.StateEmpty || state.State == watch.StateReading {
+			discID = ""
+		}
+		if event.Type != "" {
+			b.Publish(event)
+		}
+	}
+}
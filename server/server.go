@@ -0,0 +1,358 @@
+// Package server exposes the disc lookups, generations and the cache over an HTTP/JSON API.
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// maxBodySize is the maximum size of a request body.
+const maxBodySize = 1 << 20
+
+// openAPI is the OpenAPI description of the API, served at /openapi.json.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+// Server is the http.Handler of the API.
+type Server struct {
+	generator *cue.Generator
+	store     cache.Store
+	device    string
+	sheet     string
+	logger    *slog.Logger
+	events    *Broker
+	mux       *http.ServeMux
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithLogger sets the logger used to report failed requests. Failures are not logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithEvents serves the events of broker on /events and /events/ws. The generator must publish to it,
+// see Broker.Hooks. The event endpoints are not served without broker.
+func WithEvents(broker *Broker) Option {
+	return func(s *Server) {
+		s.events = broker
+	}
+}
+
+// New creates the API server.
+//
+// Parameters:
+//   - generator: The generator performing the lookups and generations, and holding the cache.
+//   - device: The drive read by GET /discs/current. Clients cannot choose another drive.
+//   - opts: Functional options overriding the defaults.
+//
+// Returns:
+//   - *Server: The API handler.
+func New(generator *cue.Generator, device string, opts ...Option) *Server {
+	s := &Server{
+		generator: generator,
+		store:     generator.Store(),
+		device:    device,
+		sheet:     sheetFile(generator.Writers()),
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		mux:       http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	s.mux.HandleFunc("/lookup", s.handleLookup)
+	s.mux.HandleFunc("/discs", s.handleGenerate)
+	s.mux.HandleFunc("/discs/", s.handleDisc)
+	if s.events != nil {
+		s.mux.HandleFunc("/events", s.handleEvents)
+		s.mux.HandleFunc("/events/ws", s.handleEventsWebSocket)
+	}
+	return s
+}
+
+// sheetFile returns the file served by GET /discs/{id}/cue: the CUE sheet of the first writer writing one,
+// cache.PlaylistFile if none does.
+func sheetFile(writers []cue.Writer) string {
+	for _, writer := range writers {
+		if strings.HasSuffix(writer.FileName(), ".cue") {
+			return writer.FileName()
+		}
+	}
+	return cache.PlaylistFile
+}
+
+// ServeHTTP dispatches a request to its endpoint.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// DiscRequest is the body of POST /lookup and POST /discs, identifying a disc without the drive.
+//
+// Fields:
+//   - Toc (string): The disc TOC in MusicBrainz format.
+//   - MusicBrainzID (string): Forces the MusicBrainz release used for metadata.
+//   - DiscID (string): Overrides the disc ID used as cache key. Requires MusicBrainzID.
+//   - Medium (int): Selects the disc of a release of several discs, by position. Requires MusicBrainzID.
+//   - Overwrite (bool): Regenerates the playlists even if cached (POST /discs only).
+type DiscRequest struct {
+	Toc           string `json:"toc,omitempty"`
+	MusicBrainzID string `json:"musicbrainzId,omitempty"`
+	DiscID        string `json:"discId,omitempty"`
+	Medium        int    `json:"medium,omitempty"`
+	Overwrite     bool   `json:"overwrite,omitempty"`
+}
+
+// errorBody is the body of the error responses.
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// handleOpenAPI serves the OpenAPI description.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
+
+// handleLookup serves POST /lookup: the metadata of the disc described in the body, without cache.
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	req, ok := s.readDiscRequest(w, r)
+	if !ok {
+		return
+	}
+	result, err := s.generator.Lookup(r.Context(), req)
+	s.writeResult(w, result, err)
+}
+
+// handleGenerate serves POST /discs: the generation of the disc described in the body.
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	req, ok := s.readDiscRequest(w, r)
+	if !ok {
+		return
+	}
+	result, err := s.generator.Generate(r.Context(), req)
+	s.writeResult(w, result, err)
+}
+
+// handleDisc serves the /discs/{id} endpoints.
+func (s *Server) handleDisc(w http.ResponseWriter, r *http.Request) {
+	id, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/discs/"), "/")
+	if id == "current" && resource == "" {
+		s.handleCurrent(w, r)
+		return
+	}
+	if id == "" || id == "." || id == ".." || strings.Contains(resource, "/") {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+		return
+	}
+	id = s.store.Resolve(id)
+	switch resource {
+	case "":
+		if allowMethods(w, r, http.MethodGet) {
+			s.handleMetadata(w, id)
+		}
+	case "cue":
+		if allowMethods(w, r, http.MethodGet) {
+			s.handleFile(w, r, id, s.sheet, "application/x-cue; charset=utf-8")
+		}
+	case "cover":
+		if allowMethods(w, r, http.MethodGet) {
+			s.handleFile(w, r, id, cache.FrontCover(s.store, id), "")
+		}
+	case "metadata":
+		switch r.Method {
+		case http.MethodGet:
+			s.handleMetadata(w, id)
+		case http.MethodPut:
+			s.handleUpdate(w, r, id)
+		default:
+			allowMethods(w, r, http.MethodGet, http.MethodPut)
+		}
+	default:
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+	}
+}
+
+// handleCurrent serves GET /discs/current: the generation of the disc in the drive.
+func (s *Server) handleCurrent(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	result, err := s.generator.Generate(r.Context(), cue.Request{Device: s.device})
+	s.writeResult(w, result, err)
+}
+
+// handleMetadata serves the metadata sidecar of a cached disc.
+func (s *Server) handleMetadata(w http.ResponseWriter, id string) {
+	metadata, err := cache.GetMetadata(s.store, id)
+	if err != nil {
+		s.writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, metadata)
+}
+
+// handleFile serves a cached file of a disc, with contentType or a type sniffed from its content if empty.
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request, id, name, contentType string) {
+	if name == "" {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("%s has no cover art: %w", id, os.ErrNotExist))
+		return
+	}
+	data, err := s.store.Get(id, name)
+	if err != nil {
+		s.writeError(w, statusOf(err), fmt.Errorf("Failed to read %s/%s: %w", id, name, err))
+		return
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
+
+// handleUpdate serves PUT /discs/{id}/metadata: the correction of the metadata of a cached disc.
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request, id string) {
+	var info types.DiscInfo
+	if !s.readJSON(w, r, &info) {
+		return
+	}
+	if info.Artist == "" || info.Title == "" || len(info.Tracks) == 0 {
+		s.writeError(w, http.StatusBadRequest, errors.New("artist, title and tracks are required"))
+		return
+	}
+	result, err := s.generator.UpdateDiscInfo(id, info)
+	if err != nil {
+		s.writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cue.NewReport(result, nil))
+}
+
+// readDiscRequest decodes and validates the body of the disc endpoints. Requests must identify
+// the disc, as clients cannot read the drive through these endpoints.
+func (s *Server) readDiscRequest(w http.ResponseWriter, r *http.Request) (cue.Request, bool) {
+	var body DiscRequest
+	if !s.readJSON(w, r, &body) {
+		return cue.Request{}, false
+	}
+	if body.Toc == "" && body.MusicBrainzID == "" && body.DiscID == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("toc, musicbrainzId or discId is required"))
+		return cue.Request{}, false
+	}
+	req := cue.Request{
+		Toc:           body.Toc,
+		MusicBrainzID: body.MusicBrainzID,
+		DiscID:        body.DiscID,
+		Medium:        body.Medium,
+		Overwrite:     body.Overwrite,
+	}
+	if _, err := req.Validate(); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return req, false
+	}
+	return req, true
+}
+
+// readJSON decodes the JSON body of r into target, replying 400 if it is invalid.
+func (s *Server) readJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+// writeResult replies with the report of a lookup or generation: 200 on success, see resultStatus otherwise.
+func (s *Server) writeResult(w http.ResponseWriter, result cue.Result, err error) {
+	status := http.StatusOK
+	if err != nil {
+		status = resultStatus(result, err)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		s.logger.Warn("request failed", "disc_id", result.DiscID, "error", err)
+	}
+	writeJSON(w, status, cue.NewReport(result, err))
+}
+
+// writeError replies with an error body.
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		s.logger.Warn("request failed", "status", status, "error", err)
+	}
+	writeJSON(w, status, errorBody{Error: err.Error()})
+}
+
+// writeJSON replies with value as JSON.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+// resultStatus returns the status of a failed lookup or generation: 400 for an invalid TOC, 422 for a
+// release of several discs without the disc to use, 503 if the disc could not be identified, e.g. no
+// disc in the drive, 502 if its metadata could not be fetched.
+func resultStatus(result cue.Result, err error) int {
+	switch {
+	case errors.Is(err, utils.ErrInvalidToc):
+		return http.StatusBadRequest
+	case errors.Is(err, cue.ErrAmbiguousRelease):
+		return http.StatusUnprocessableEntity
+	case result.DiscID == "":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// statusOf returns 404 for a missing disc or file, 500 otherwise.
+func statusOf(err error) int {
+	if errors.Is(err, os.ErrNotExist) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// allowMethods replies 405 unless r uses one of methods.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, errorBody{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+	return false
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/cache"
+	"github.com/b0bbywan/go-disc-cuer/config"
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/types"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// testToc is the disc known to testProvider.
+const testToc = "1 3 100000 150 30000 60000"
+
+// unknownToc is a disc testProvider does not know.
+const unknownToc = "1 2 100000 150 30000"
+
+// testProvider knows the discs of three tracks.
+type testProvider struct{}
+
+func (testProvider) Name() string {
+	return "test"
+}
+
+func (testProvider) Lookup(ctx context.Context, toc *utils.Toc) (*types.DiscInfo, error) {
+	if toc.TrackCount() != 3 {
+		return nil, fmt.Errorf("%w: %s", cue.ErrNotFound, toc.MusicBrainzDiscID())
+	}
+	return &types.DiscInfo{Artist: "Artist", Title: "Album", Tracks: []string{"One", "Two", "Three"}}, nil
+}
+
+// noCoverSource has no cover art.
+type noCoverSource struct{}
+
+func (noCoverSource) Name() string {
+	return "none"
+}
+
+func (noCoverSource) Fetch(ctx context.Context, query cue.CoverQuery) ([]cue.CoverArt, error) {
+	return nil, cue.ErrNoCoverArt
+}
+
+// twoDiscRelease answers every MusicBrainz request with a release of two discs of one track.
+type twoDiscRelease struct{}
+
+func (twoDiscRelease) RoundTrip(r *http.Request) (*http.Response, error) {
+	body := `{"id": "rel", "title": "Album", "artist-credit": [{"name": "Artist"}], "media": [
+  {"position": 1, "tracks": [{"title": "One"}], "discs": [{"id": "first", "sectors": 20000, "offset-count": 1, "offsets": [150]}]},
+  {"position": 2, "tracks": [{"title": "Two"}], "discs": [{"id": "second", "sectors": 30000, "offset-count": 1, "offsets": [150]}]}
+]}`
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+// newTestServer returns a server writing formats, without drive, and the ID of the cached disc of testToc.
+func newTestServer(t *testing.T, formats ...string) (*Server, string) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	generator, err := cue.NewGenerator(&config.Config{CacheLocation: t.TempDir(), Formats: formats, Logger: logger},
+		cue.WithProviders(testProvider{}),
+		cue.WithCoverSources(noCoverSource{}),
+		cue.WithHTTPClient(&http.Client{Transport: twoDiscRelease{}}),
+		cue.WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := generator.Generate(context.Background(), cue.Request{Toc: testToc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(generator, "", WithLogger(logger)), result.DiscID
+}
+
+// serve performs a request on s, returning the response status and body.
+func serve(s *Server, method, path, body string) (int, string) {
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestServerStatus(t *testing.T) {
+	s, discID := newTestServer(t, cue.FormatCUE)
+	toc, err := utils.ParseMusicBrainzToc(testToc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	freedbID := toc.FreedbID()
+	metadata := `{"artist": "Fixed", "title": "Album", "tracks": ["One", "Two", "Three"]}`
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "lookup", method: http.MethodPost, path: "/lookup", body: `{"toc": "` + testToc + `"}`, wantStatus: http.StatusOK, wantBody: `"title": "Album"`},
+		{name: "lookup unknown disc", method: http.MethodPost, path: "/lookup", body: `{"toc": "` + unknownToc + `"}`, wantStatus: http.StatusBadGateway},
+		{name: "lookup invalid toc", method: http.MethodPost, path: "/lookup", body: `{"toc": "1 3 100000"}`, wantStatus: http.StatusBadRequest, wantBody: "invalid TOC"},
+		{name: "lookup ambiguous release", method: http.MethodPost, path: "/lookup", body: `{"musicbrainzId": "rel"}`, wantStatus: http.StatusUnprocessableEntity, wantBody: "--medium"},
+		{name: "lookup medium of release", method: http.MethodPost, path: "/lookup", body: `{"musicbrainzId": "rel", "medium": 2}`, wantStatus: http.StatusOK, wantBody: `"title": "Two"`},
+		{name: "lookup empty", method: http.MethodPost, path: "/lookup", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "lookup unknown field", method: http.MethodPost, path: "/lookup", body: `{"device": "/dev/sr0"}`, wantStatus: http.StatusBadRequest},
+		{name: "lookup get", method: http.MethodGet, path: "/lookup", wantStatus: http.StatusMethodNotAllowed},
+		{name: "generate", method: http.MethodPost, path: "/discs", body: `{"toc": "` + testToc + `"}`, wantStatus: http.StatusOK, wantBody: `"cacheHit": true`},
+		{name: "generate unknown disc", method: http.MethodPost, path: "/discs", body: `{"toc": "` + unknownToc + `"}`, wantStatus: http.StatusBadGateway},
+		{name: "generate ambiguous release", method: http.MethodPost, path: "/discs", body: `{"musicbrainzId": "rel"}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "current without drive", method: http.MethodGet, path: "/discs/current", wantStatus: http.StatusServiceUnavailable},
+		{name: "disc", method: http.MethodGet, path: "/discs/" + discID, wantStatus: http.StatusOK, wantBody: `"artist": "Artist"`},
+		{name: "disc by alias", method: http.MethodGet, path: "/discs/" + freedbID + "/metadata", wantStatus: http.StatusOK, wantBody: discID},
+		{name: "missing disc", method: http.MethodGet, path: "/discs/missing", wantStatus: http.StatusNotFound},
+		{name: "cue", method: http.MethodGet, path: "/discs/" + discID + "/cue", wantStatus: http.StatusOK, wantBody: `TITLE "Album"`},
+		{name: "cue of missing disc", method: http.MethodGet, path: "/discs/missing/cue", wantStatus: http.StatusNotFound},
+		{name: "cover missing", method: http.MethodGet, path: "/discs/" + discID + "/cover", wantStatus: http.StatusNotFound},
+		{name: "unknown resource", method: http.MethodGet, path: "/discs/" + discID + "/tracks", wantStatus: http.StatusNotFound},
+		{name: "update", method: http.MethodPut, path: "/discs/" + discID + "/metadata", body: metadata, wantStatus: http.StatusOK, wantBody: `"artist": "Fixed"`},
+		{name: "update without tracks", method: http.MethodPut, path: "/discs/" + discID + "/metadata", body: `{"artist": "Fixed", "title": "Album"}`, wantStatus: http.StatusBadRequest},
+		{name: "update missing disc", method: http.MethodPut, path: "/discs/missing/metadata", body: metadata, wantStatus: http.StatusNotFound},
+		{name: "update with post", method: http.MethodPost, path: "/discs/" + discID + "/metadata", body: metadata, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := serve(s, tt.method, tt.path, tt.body)
+			if status != tt.wantStatus || !strings.Contains(body, tt.wantBody) {
+				t.Errorf("%s %s = %d %s, want %d with %q", tt.method, tt.path, status, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+
+	// The update rewrote the playlist
+	if _, body := serve(s, http.MethodGet, "/discs/"+discID+"/cue", ""); !strings.Contains(body, `PERFORMER "Fixed"`) {
+		t.Errorf("GET /cue after the update = %s, want the fixed artist", body)
+	}
+}
+
+func TestServerReport(t *testing.T) {
+	s, discID := newTestServer(t, cue.FormatCUE)
+	status, body := serve(s, http.MethodPost, "/lookup", `{"toc": "`+unknownToc+`"}`)
+	var report cue.Report
+	if err := json.Unmarshal([]byte(body), &report); err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusBadGateway || report.DiscID == "" || report.Error == "" || len(report.Candidates) != 1 {
+		t.Errorf("POST /lookup of an unknown disc = %d %+v, want 502 with the disc ID, error and candidate", status, report)
+	}
+
+	_, body = serve(s, http.MethodPost, "/discs", `{"toc": "`+testToc+`"}`)
+	if err := json.Unmarshal([]byte(body), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.DiscID != discID || !report.CacheHit || report.Release == nil || len(report.Release.Tracks) != 3 {
+		t.Errorf("POST /discs = %+v, want the cached disc %s", report, discID)
+	}
+}
+
+func TestServerCover(t *testing.T) {
+	s, discID := newTestServer(t, cue.FormatCUE)
+	png := "\x89PNG\r\n\x1a\n"
+	if err := s.store.Put(discID, "front.png", []byte(png)); err != nil {
+		t.Fatal(err)
+	}
+	index := &cache.CoverIndex{Images: []cache.CoverImage{{Type: cache.CoverFront, File: "front.png"}}}
+	if err := cache.PutCoverIndex(s.store, discID, index); err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/discs/"+discID+"/cover", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != png || recorder.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("GET /cover = %d %s %q, want the PNG cover", recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body.String())
+	}
+}
+
+func TestServerSheetFormats(t *testing.T) {
+	tests := []struct {
+		formats    []string
+		wantStatus int
+		wantBody   string
+	}{
+		{formats: []string{cue.FormatCUE}, wantStatus: http.StatusOK, wantBody: "cdda:///1"},
+		{formats: []string{cue.FormatM3U8, cue.FormatBurnCUE}, wantStatus: http.StatusOK, wantBody: `FILE "disc.wav" WAVE`},
+		{formats: []string{cue.FormatM3U8, cue.FormatBurnCUE, cue.FormatCUE}, wantStatus: http.StatusOK, wantBody: `FILE "disc.wav" WAVE`},
+		{formats: []string{cue.FormatM3U8}, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.formats, ","), func(t *testing.T) {
+			s, discID := newTestServer(t, tt.formats...)
+			status, body := serve(s, http.MethodGet, "/discs/"+discID+"/cue", "")
+			if status != tt.wantStatus || !strings.Contains(body, tt.wantBody) {
+				t.Errorf("GET /cue = %d %s, want %d with %q", status, body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
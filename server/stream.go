@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keepAliveInterval is the delay between two keep-alives of an idle event stream, so that proxies and
+// clients do not time it out.
+const keepAliveInterval = 15 * time.Second
+
+// handleEvents serves GET /events: the event stream, as server-sent events.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	// Browsers resume a stream with the ID of the last event they received
+	after, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	events, cancel := s.events.Subscribe(after)
+	defer cancel()
+	filter := newEventFilter(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Fprintf(w, "retry: %d\n\n", (5 * time.Second).Milliseconds())
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !filter(event) {
+				continue
+			}
+			data, err := encodeEvent(event)
+			if err != nil {
+				s.logger.Warn("failed to encode event", "type", event.Type, "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// handleEventsWebSocket serves GET /events/ws: the event stream, as WebSocket text messages.
+func (s *Server) handleEventsWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	after, _ := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64)
+	filter := newEventFilter(r)
+	conn, err := upgradeWebSocket(w, r)
+	if errors.Is(err, errNotWebSocket) {
+		w.Header().Set("Upgrade", "websocket")
+		s.writeError(w, http.StatusUpgradeRequired, err)
+		return
+	}
+	if err != nil {
+		s.logger.Warn("failed to upgrade connection", "error", err)
+		return
+	}
+	events, cancel := s.events.Subscribe(after)
+	defer cancel()
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- conn.readLoop()
+	}()
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-closed:
+			conn.Close(wsCloseNormal)
+			return
+		case <-keepAlive.C:
+			err = conn.Ping()
+		case event, ok := <-events:
+			if !ok {
+				conn.Close(wsCloseGoingAway)
+				return
+			}
+			if !filter(event) {
+				continue
+			}
+			var data []byte
+			if data, err = encodeEvent(event); err != nil {
+				s.logger.Warn("failed to encode event", "type", event.Type, "error", err)
+				err = nil
+				continue
+			}
+			err = conn.WriteText(data)
+		}
+		if err != nil {
+			s.logger.Debug("event stream closed", "error", err)
+			conn.Close(wsCloseGoingAway)
+			return
+		}
+	}
+}
+
+// newEventFilter returns a filter keeping the events listed in the comma separated types query
+// parameter of r, every event if absent. A category, e.g. "disc", keeps all its events, e.g. "disc.inserted".
+func newEventFilter(r *http.Request) func(Event) bool {
+	query := r.URL.Query().Get("types")
+	if query == "" {
+		return func(Event) bool { return true }
+	}
+	types := strings.Split(query, ",")
+	return func(event Event) bool {
+		for _, t := range types {
+			t = strings.TrimSpace(t)
+			if event.Type == t || strings.HasPrefix(event.Type, t+".") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// encodeEvent encodes event as single line JSON.
+func encodeEvent(event Event) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(event); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
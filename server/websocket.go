@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is appended to the client key to compute the handshake accept key, see RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes.
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// WebSocket close status codes.
+const (
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001
+)
+
+// maxControlPayload is the maximum payload of a control frame, see RFC 6455.
+const maxControlPayload = 125
+
+// websocketWriteTimeout bounds the writes to a client, so that a stalled client cannot hold a stream.
+const websocketWriteTimeout = 10 * time.Second
+
+// errNotWebSocket is returned by upgradeWebSocket when the request is not a WebSocket handshake.
+var errNotWebSocket = errors.New("expected a WebSocket handshake (Upgrade: websocket, Sec-WebSocket-Version: 13)")
+
+// wsConn is a minimal server side WebSocket connection, sending text messages and answering the
+// control frames of the client. The messages of the client are discarded.
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// upgradeWebSocket completes the WebSocket handshake of r and takes over its connection.
+//
+// Returns:
+//   - *wsConn: The WebSocket connection, to close once done.
+//   - error: errNotWebSocket if r is not a valid handshake, or an error if the connection cannot be taken over.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		return nil, errNotWebSocket
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection cannot be upgraded")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+// headerContains reports whether the comma separated values of header name include token, ignoring case.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFrame sends a single unmasked frame, as servers do.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n <= maxControlPayload:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteText sends a text message.
+func (c *wsConn) WriteText(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// Ping sends a ping, keeping proxies from closing an idle connection.
+func (c *wsConn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Close sends a close frame with status code, then closes the connection.
+func (c *wsConn) Close(code uint16) error {
+	c.writeFrame(opClose, binary.BigEndian.AppendUint16(nil, code))
+	return c.conn.Close()
+}
+
+// readLoop reads the frames of the client until it closes the connection or an error occurs, answering
+// pings. Other messages are discarded. It returns io.EOF when the client sends a close frame.
+func (c *wsConn) readLoop() error {
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return err
+		}
+		opcode := header[0] & 0x0F
+		masked := header[1]&0x80 != 0
+		length := uint64(header[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+				return err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+				return err
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+		if !masked {
+			return errors.New("unmasked client frame")
+		}
+		var mask [4]byte
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return err
+		}
+		if opcode < opClose {
+			if _, err := io.CopyN(io.Discard, c.reader, int64(length)); err != nil {
+				return err
+			}
+			continue
+		}
+		if length > maxControlPayload {
+			return errors.New("control frame too long")
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return err
+			}
+		case opClose:
+			return io.EOF
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readFrame reads a server frame, which must be unmasked.
+func readFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[0]&0x80 == 0 {
+		t.Fatal("fragmented server frame")
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("masked server frame")
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(r, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// clientFrame returns a frame as sent by a client, masked with mask unless it is nil.
+func clientFrame(opcode byte, payload []byte, mask []byte) []byte {
+	frame := []byte{0x80 | opcode, byte(len(payload))}
+	if mask == nil {
+		return append(frame, payload...)
+	}
+	frame[1] |= 0x80
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// pipeConn returns a WebSocket connection over an in-memory pipe, and the client end of the pipe.
+func pipeConn(t *testing.T) (*wsConn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return &wsConn{conn: server, reader: bufio.NewReader(server)}, client
+}
+
+func TestUpgradeWebSocket(t *testing.T) {
+	upgraded := make(chan *wsConn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgradeWebSocket(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		upgraded <- conn
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// The sample handshake of RFC 6455, section 1.3
+	io.WriteString(conn, "GET /events HTTP/1.1\r\nHost: server.example.com\r\nUpgrade: websocket\r\n"+
+		"Connection: keep-alive, Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q, want the RFC 6455 sample value", got)
+	}
+
+	ws := <-upgraded
+	defer ws.Close(wsCloseNormal)
+	go ws.WriteText([]byte("hello"))
+	if opcode, payload := readFrame(t, reader); opcode != opText || string(payload) != "hello" {
+		t.Errorf("frame = %#x %q, want a text frame \"hello\"", opcode, payload)
+	}
+}
+
+func TestUpgradeWebSocketRejectsHTTP(t *testing.T) {
+	tests := map[string]http.Header{
+		"plain request":   {},
+		"missing key":     {"Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"13"}},
+		"wrong version":   {"Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"8"}, "Sec-Websocket-Key": {"x"}},
+		"wrong upgrade":   {"Connection": {"Upgrade"}, "Upgrade": {"h2c"}, "Sec-Websocket-Version": {"13"}, "Sec-Websocket-Key": {"x"}},
+		"missing upgrade": {"Upgrade": {"websocket"}, "Sec-Websocket-Version": {"13"}, "Sec-Websocket-Key": {"x"}},
+	}
+	for name, header := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/events", nil)
+			r.Header = header
+			if _, err := upgradeWebSocket(httptest.NewRecorder(), r); !errors.Is(err, errNotWebSocket) {
+				t.Errorf("upgradeWebSocket() error = %v, want errNotWebSocket", err)
+			}
+		})
+	}
+}
+
+func TestWebSocketWriteText(t *testing.T) {
+	for _, size := range []int{0, maxControlPayload, maxControlPayload + 1, 0xFFFF, 0xFFFF + 1} {
+		ws, client := pipeConn(t)
+		message := bytes.Repeat([]byte("a"), size)
+		go ws.WriteText(message)
+		if opcode, payload := readFrame(t, client); opcode != opText || !bytes.Equal(payload, message) {
+			t.Errorf("frame of %d bytes = %#x with %d bytes, want the text message", size, opcode, len(payload))
+		}
+	}
+}
+
+func TestWebSocketReadLoop(t *testing.T) {
+	ws, client := pipeConn(t)
+	done := make(chan error, 1)
+	go func() { done <- ws.readLoop() }()
+
+	mask := []byte{0x37, 0xfa, 0x21, 0x3d}
+	// Text messages are discarded
+	client.Write(clientFrame(opText, []byte("ignored"), mask))
+	// Pings are answered with their unmasked payload
+	client.Write(clientFrame(opPing, []byte("Hello"), mask))
+	if opcode, payload := readFrame(t, client); opcode != opPong || string(payload) != "Hello" {
+		t.Errorf("answer to ping = %#x %q, want a pong \"Hello\"", opcode, payload)
+	}
+	client.Write(clientFrame(opClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal), mask))
+	if err := <-done; !errors.Is(err, io.EOF) {
+		t.Errorf("readLoop() after a close frame = %v, want io.EOF", err)
+	}
+}
+
+func TestWebSocketReadLoopErrors(t *testing.T) {
+	mask := []byte{1, 2, 3, 4}
+	tests := []struct {
+		name  string
+		frame []byte
+		want  string
+	}{
+		{"unmasked frame", clientFrame(opText, []byte("hi"), nil), "unmasked"},
+		{"long control frame", append([]byte{0x80 | opPing, 0x80 | 126, 0, 200}, mask...), "too long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, client := pipeConn(t)
+			done := make(chan error, 1)
+			go func() { done <- ws.readLoop() }()
+			client.Write(tt.frame)
+			if err := <-done; err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("readLoop() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	ws, client := pipeConn(t)
+	go ws.Close(wsCloseGoingAway)
+	opcode, payload := readFrame(t, client)
+	if opcode != opClose || len(payload) != 2 || binary.BigEndian.Uint16(payload) != wsCloseGoingAway {
+		t.Errorf("close frame = %#x %v, want a close frame with status 1001", opcode, payload)
+	}
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("connection still open after Close()")
+	}
+}
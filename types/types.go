@@ -4,20 +4,30 @@ package types
 //
 // Fields:
 //   - ID (string): The unique identifier for the disc (e.g., MusicBrainz ID or custom disc ID).
+//   - ReleaseGroupID (string): The MusicBrainz release group ID, if known.
 //   - Artist (string): The name of the artist or band.
 //   - Title (string): The title of the release (album name).
 //   - ReleaseDate (string): The release date of the disc (e.g., "2024-01-01").
 //   - Genre (string): The genre of the music (e.g., "Rock", "Pop").
 //   - Tracks ([]string): A list of track titles in the release.
+//   - Medium (int): The position of the disc in a release of several discs, 0 for single disc releases.
+//   - Barcode (string): The UPC/EAN barcode of the release, if known.
+//   - ISRCs ([]string): The ISRC of each track, in track order, empty strings when unknown (optional).
+//   - Songwriters ([]string): The composers and lyricists of each track, in track order (optional).
 //   - CoverArtPath (string): The file path where the cover art image is stored (optional).
 type DiscInfo struct {
-	ID           string   // Unique ID for the disc
-	Artist       string   // Artist or band name
-	Title        string   // Title of the album or release
-	ReleaseDate  string   // Release date of the disc
-	Genre        string   // Genre of the album
-	Tracks       []string // List of track titles
-	CoverArtPath string   // Path to the cover art image
+	ID             string   `json:"id"`                       // Unique ID for the disc
+	ReleaseGroupID string   `json:"releaseGroupId,omitempty"` // MusicBrainz release group ID
+	Artist         string   `json:"artist"`                   // Artist or band name
+	Title          string   `json:"title"`                    // Title of the album or release
+	ReleaseDate    string   `json:"releaseDate,omitempty"`    // Release date of the disc
+	Genre          string   `json:"genre,omitempty"`          // Genre of the album
+	Tracks         []string `json:"tracks"`                   // List of track titles
+	Medium         int      `json:"medium,omitempty"`         // Position of the disc in the release
+	Barcode        string   `json:"barcode,omitempty"`        // UPC/EAN barcode of the release
+	ISRCs          []string `json:"isrcs,omitempty"`          // ISRC of each track
+	Songwriters    []string `json:"songwriters,omitempty"`    // Songwriters of each track
+	CoverArtPath   string   `json:"coverArtPath,omitempty"`   // Path to the cover art image
 }
 
 // MBRelease represents a MusicBrainz release. This struct is used for parsing MusicBrainz API responses.
@@ -26,20 +36,75 @@ type DiscInfo struct {
 //   - ID (string): The unique identifier of the release in MusicBrainz.
 //   - Title (string): The title of the release (album name).
 //   - Date (string): The release date in MusicBrainz format (e.g., "2024-01-01").
+//   - Barcode (string): The UPC/EAN barcode of the release, possibly empty.
+//   - ReleaseGroup (struct{ID string}): The release group of the release (only when requested with inc=release-groups).
 //   - ArtistCredit ([]struct{Name string}): A list of artist credits, with each artist's name as a string.
-//   - Media ([]struct{ Tracks []struct{ Title string } }): Media tracks, with each track containing its title.
+//   - Media ([]struct{ Position int; Tracks []MBTrack; Discs []MBDisc }): Media position and tracks, and
+//     the disc IDs attached to each medium (only when requested with inc=discids).
 type MBRelease struct {
-	ID           string `json:"id"`    // MusicBrainz release ID
-	Title        string `json:"title"` // Release title
-	Date         string `json:"date"`  // Release date in MusicBrainz format
+	ID           string `json:"id"`      // MusicBrainz release ID
+	Title        string `json:"title"`   // Release title
+	Date         string `json:"date"`    // Release date in MusicBrainz format
+	Barcode      string `json:"barcode"` // UPC/EAN barcode
+	ReleaseGroup struct {
+		ID string `json:"id"` // MusicBrainz release group ID
+	} `json:"release-group"`
 	ArtistCredit []struct {
 		Name string // Artist credit information
 	} `json:"artist-credit"`
 	Media []struct { // List of tracks in the release
-		Tracks []struct{ Title string }
+		Position int `json:"position"` // Position of the medium in the release, from 1
+		Tracks   []MBTrack
+		Discs    []MBDisc `json:"discs"` // Disc IDs attached to the medium
 	} `json:"media"`
 }
 
+// MBTrack represents a track of a MusicBrainz medium.
+//
+// Fields:
+//   - Title (string): The track title.
+//   - Recording (struct{ ISRCs []string; Relations []MBRelation }): The recording of the track, with its
+//     ISRCs (only when requested with inc=isrcs) and its relations (only when requested with
+//     inc=recording-level-rels+work-rels, e.g. the performed work).
+type MBTrack struct {
+	Title     string `json:"title"` // Track title
+	Recording struct {
+		ISRCs     []string     `json:"isrcs"`     // ISRCs of the recording
+		Relations []MBRelation `json:"relations"` // Relations of the recording
+	} `json:"recording"`
+}
+
+// MBRelation represents a MusicBrainz relationship, to an artist or a work.
+//
+// Fields:
+//   - Type (string): The relationship type (e.g., "performance", "composer", "lyricist", "writer").
+//   - Artist (*struct{ Name string }): The related artist, if any.
+//   - Work (*struct{ Relations []MBRelation }): The related work, with its own relations when requested
+//     with inc=work-level-rels+artist-rels.
+type MBRelation struct {
+	Type   string `json:"type"` // Relationship type
+	Artist *struct {
+		Name string `json:"name"` // Artist name
+	} `json:"artist"`
+	Work *struct {
+		Relations []MBRelation `json:"relations"` // Relations of the work
+	} `json:"work"`
+}
+
+// MBDisc represents a disc ID attached to a MusicBrainz medium, along with the TOC it was computed from.
+//
+// Fields:
+//   - ID (string): The MusicBrainz disc ID.
+//   - Sectors (int): The lead-out offset of the disc, in frames.
+//   - OffsetCount (int): The number of tracks on the disc.
+//   - Offsets ([]int): The start offset of each track, in frames.
+type MBDisc struct {
+	ID          string `json:"id"`           // MusicBrainz disc ID
+	Sectors     int    `json:"sectors"`      // Lead-out offset in frames
+	OffsetCount int    `json:"offset-count"` // Number of tracks
+	Offsets     []int  `json:"offsets"`      // Track offsets in frames
+}
+
 // ReleaseResult contains a list of releases returned by MusicBrainz in response to a query.
 // Fields:
 //   - Releases ([]MBRelease): A list of `MBRelease` structs that represent the releases matched by the query.
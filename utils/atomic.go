@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempFilePrefix starts the name of the temporary files created by WriteFileAtomic.
+const tempFilePrefix = ".tmp-"
+
+// WriteFileAtomic writes a file through a temporary file renamed over path once complete, so readers
+// never see a partially written file, even if the process crashes.
+//
+// Parameters:
+//   - path (string): The path of the file to write. Its folder must exist.
+//   - write (func(io.Writer) error): Writes the file content.
+//
+// Returns:
+//   - error: Any error encountered while writing, syncing or renaming. The temporary file is removed on error.
+func WriteFileAtomic(path string, write func(io.Writer) error) (err error) {
+	dir, name := filepath.Split(path)
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+name+"-*")
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file for %s: %w", path, err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(0o644); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// IsTempFile reports whether name is a temporary file left by an interrupted WriteFileAtomic.
+func IsTempFile(name string) bool {
+	return strings.HasPrefix(name, tempFilePrefix)
+}
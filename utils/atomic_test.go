@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	errWrite := errors.New("write failed")
+	tests := []struct {
+		name    string
+		write   func(io.Writer) error
+		wantErr error
+		want    string
+	}{
+		{name: "written", write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "new")
+			return err
+		}, want: "new"},
+		{name: "write error keeps the file", write: func(w io.Writer) error {
+			io.WriteString(w, "partial")
+			return errWrite
+		}, wantErr: errWrite, want: "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "disc.cue")
+			if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteFileAtomic(path, tt.write); !errors.Is(err, tt.wantErr) {
+				t.Errorf("WriteFileAtomic() error = %v, want %v", err, tt.wantErr)
+			}
+			if data, err := os.ReadFile(path); err != nil || string(data) != tt.want {
+				t.Errorf("file = %q, %v, want %q", data, err, tt.want)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if IsTempFile(entry.Name()) {
+					t.Errorf("temporary file %s left", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomicMissingFolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "disc.cue")
+	if err := WriteFileAtomic(path, func(io.Writer) error { return nil }); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("WriteFileAtomic() in a missing folder: error = %v, want os.ErrNotExist", err)
+	}
+}
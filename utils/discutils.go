@@ -2,7 +2,7 @@ package utils
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 
 	"go.uploadedlobster.com/discid"
@@ -24,7 +24,7 @@ func GetTocAndDiscID(disc discid.Disc) (string, string, error) {
 	}
 
 	discID := disc.FreedbID()
-	log.Printf("GNU TOC: %s", gnuToc)
+	slog.Debug("GNU TOC", "gnu_toc", gnuToc)
 	return gnuToc, discID, nil
 }
 
@@ -38,7 +38,7 @@ func GetTocAndDiscID(disc discid.Disc) (string, string, error) {
 //   - error: Any error encountered during the process.
 func GetMusicBrainzTOC(disc discid.Disc) (string, error) {
 	mbToc := disc.TOCString()
-	log.Printf("MusicBrainz TOC: %s", mbToc)
+	slog.Debug("MusicBrainz TOC", "mb_toc", mbToc)
 	return mbToc, nil
 }
 
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// lockRetryInterval is the delay between two attempts to take a busy lock.
+const lockRetryInterval = 100 * time.Millisecond
+
+// FileLock is an advisory lock on a file, shared between processes.
+type FileLock struct {
+	file *os.File
+	// release is set for record locks, to release their process slot once unlocked.
+	release func()
+}
+
+// recordSlots holds a slot per record locked path, a channel of capacity 1. POSIX record locks are
+// held by the process, so they neither exclude its own goroutines nor survive the closing of another
+// descriptor of the file: a process holds at most one record lock per path.
+var recordSlots sync.Map
+
+// LockFile takes an exclusive lock on the file at path, creating it if needed, and waits
+// until the lock is available or ctx is done.
+//
+// Parameters:
+//   - ctx (context.Context): Cancels the wait for a busy lock.
+//   - path (string): The path of the lock file.
+//
+// Returns:
+//   - *FileLock: The held lock, to be released with Unlock.
+//   - error: An error if the lock file cannot be opened or ctx is done before the lock is taken.
+func LockFile(ctx context.Context, path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open lock %s: %w", path, err)
+	}
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("Failed to lock %s: %w", path, err)
+		}
+		if locked {
+			return &FileLock{file: file}, nil
+		}
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, fmt.Errorf("Failed to lock %s: %w", path, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// LockRecord takes a POSIX record lock on the whole file at path, creating it if needed, and waits
+// until the lock is available or ctx is done. Unlike the flock of LockFile, which some NFS clients
+// keep local to the host, NFS forwards record locks to the server, so that processes of different
+// hosts sharing the file exclude each other.
+//
+// Parameters:
+//   - ctx (context.Context): Cancels the wait for a busy lock.
+//   - path (string): The path of the lock file.
+//   - exclusive (bool): Whether to take a write lock, excluding any other lock, rather than a read
+//     lock, only excluding write locks of other processes.
+//
+// Returns:
+//   - *FileLock: The held lock, to be released with Unlock.
+//   - error: An error if the lock file cannot be opened or ctx is done before the lock is taken.
+func LockRecord(ctx context.Context, path string, exclusive bool) (*FileLock, error) {
+	slot, _ := recordSlots.LoadOrStore(path, make(chan struct{}, 1))
+	select {
+	case slot.(chan struct{}) <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("Failed to lock %s: %w", path, ctx.Err())
+	}
+	release := func() { <-slot.(chan struct{}) }
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		release()
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("Failed to open lock %s: %w", path, err)
+	}
+	for {
+		locked, err := tryLockRecord(file, exclusive)
+		if err != nil {
+			file.Close()
+			release()
+			return nil, fmt.Errorf("Failed to lock %s: %w", path, err)
+		}
+		if locked {
+			return &FileLock{file: file, release: release}, nil
+		}
+		select {
+		case <-ctx.Done():
+			file.Close()
+			release()
+			return nil, fmt.Errorf("Failed to lock %s: %w", path, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// LockDisc takes the exclusive lock of a cached disc, so concurrent generations of the same disc,
+// possibly from different processes, do not interleave their writes.
+//
+// Parameters:
+//   - ctx (context.Context): Cancels the wait for a busy lock.
+//   - cacheLocation: The base directory for caching files.
+//   - discID (string): The disc ID.
+//
+// Returns:
+//   - *FileLock: The held lock, to be released with Unlock.
+//   - error: An error if the lock cannot be taken.
+func LockDisc(ctx context.Context, cacheLocation, discID string) (*FileLock, error) {
+	return LockFile(ctx, CacheLockPath(cacheLocation, discID))
+}
+
+// CacheLockPath generates the path of the lock file of name, in the hidden .locks folder of the cache.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - name (string): The name of the locked resource, e.g. a disc ID.
+//
+// Returns:
+//   - string: The generated lock file path.
+func CacheLockPath(cacheLocation, name string) string {
+	return filepath.Join(cacheLocation, ".locks", name+".lock")
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	if l.release != nil {
+		// Closing the file releases the record lock
+		defer l.release()
+		return l.file.Close()
+	}
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
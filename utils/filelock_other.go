@@ -0,0 +1,22 @@
+//go:build !unix
+
+package utils
+
+import (
+	"os"
+)
+
+// tryLock always succeeds: file locks are only supported on unix systems.
+func tryLock(file *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlock is a no-op on systems without file locks.
+func unlock(file *os.File) error {
+	return nil
+}
+
+// tryLockRecord always succeeds: file locks are only supported on unix systems.
+func tryLockRecord(file *os.File, exclusive bool) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// lockHelperEnv runs the test binary as a process holding a record lock, see TestLockRecordHelper.
+const lockHelperEnv = "DISC_CUER_LOCK_HELPER"
+
+// TestLockRecordHelper is not a test: run by lockInOtherProcess, it takes the record lock of the
+// path in lockHelperEnv, exclusive if DISC_CUER_LOCK_EXCLUSIVE is set, prints a line and holds the
+// lock until its standard input is closed.
+func TestLockRecordHelper(t *testing.T) {
+	path := os.Getenv(lockHelperEnv)
+	if path == "" {
+		t.Skip("helper process")
+	}
+	lock, err := LockRecord(context.Background(), path, os.Getenv("DISC_CUER_LOCK_EXCLUSIVE") != "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout.WriteString("locked\n")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	lock.Unlock()
+}
+
+// lockInOtherProcess holds the record lock of path in another process, until the returned function is called.
+func lockInOtherProcess(t *testing.T, path string, exclusive bool) func() {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockRecordHelper$")
+	cmd.Env = append(os.Environ(), lockHelperEnv+"="+path)
+	if exclusive {
+		cmd.Env = append(cmd.Env, "DISC_CUER_LOCK_EXCLUSIVE=1")
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); err != nil || line != "locked\n" {
+		t.Fatalf("helper process: %q, %v", line, err)
+	}
+	return func() {
+		stdin.Close()
+		cmd.Wait()
+	}
+}
+
+// tryLockRecordFor attempts to take the record lock of path for a short time.
+func tryLockRecordFor(path string, exclusive bool) (*FileLock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	return LockRecord(ctx, path, exclusive)
+}
+
+func TestLockRecordExcludesGoroutines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.lock")
+	lock, err := LockRecord(context.Background(), path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Even read locks exclude each other within a process
+	if _, err := tryLockRecordFor(path, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second LockRecord() in the process: error = %v, want context.DeadlineExceeded", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = tryLockRecordFor(path, true)
+	if err != nil {
+		t.Fatalf("LockRecord() after Unlock(): error = %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestLockRecordExcludesProcesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("record locks are only supported on unix systems")
+	}
+	path := filepath.Join(t.TempDir(), "store.lock")
+	tests := []struct {
+		name           string
+		otherExclusive bool
+		exclusive      bool
+		wantBusy       bool
+	}{
+		{name: "read locks", otherExclusive: false, exclusive: false, wantBusy: false},
+		{name: "write lock of the other process", otherExclusive: true, exclusive: false, wantBusy: true},
+		{name: "write lock while the other process reads", otherExclusive: false, exclusive: true, wantBusy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := lockInOtherProcess(t, path, tt.otherExclusive)
+			lock, err := tryLockRecordFor(path, tt.exclusive)
+			if tt.wantBusy != errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("LockRecord() = %v, want busy %v", err, tt.wantBusy)
+			}
+			if err == nil {
+				lock.Unlock()
+			}
+			release()
+			if lock, err = tryLockRecordFor(path, true); err != nil {
+				t.Fatalf("LockRecord() after the other process exited: error = %v", err)
+			}
+			lock.Unlock()
+		})
+	}
+}
+
+func TestLockFileExcludes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file locks are only supported on unix systems")
+	}
+	path := filepath.Join(t.TempDir(), "locks", "disc.lock")
+	lock, err := LockFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan *FileLock)
+	go func() {
+		other, err := LockFile(context.Background(), path)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- other
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second LockFile() took the held lock")
+	case <-time.After(3 * lockRetryInterval):
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case other := <-acquired:
+		if other != nil {
+			other.Unlock()
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second LockFile() still waiting after Unlock()")
+	}
+}
+
+func TestLockFileCanceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file locks are only supported on unix systems")
+	}
+	path := filepath.Join(t.TempDir(), "disc.lock")
+	lock, err := LockFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := LockFile(ctx, path)
+		done <- err
+	}()
+	time.Sleep(lockRetryInterval)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("LockFile() after cancel: error = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("LockFile() still waiting after cancel")
+	}
+}
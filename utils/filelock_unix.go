@@ -0,0 +1,38 @@
+//go:build unix
+
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// tryLock takes an exclusive flock on file without blocking. It returns false if the lock is busy.
+func tryLock(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlock releases the flock on file.
+func unlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
+
+// tryLockRecord takes a POSIX record lock on the whole file without blocking: a write lock if
+// exclusive, else a read lock. It returns false if the lock is busy.
+func tryLockRecord(file *os.File, exclusive bool) (bool, error) {
+	lock := syscall.Flock_t{Type: syscall.F_RDLCK, Whence: io.SeekStart}
+	if exclusive {
+		lock.Type = syscall.F_WRLCK
+	}
+	err := syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, &lock)
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EACCES) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"log"
 	"os"
 	"path/filepath"
 )
@@ -13,13 +12,9 @@ import (
 //
 // Returns:
 //   - bool: True if the playlist file exists, false otherwise.
-//   - The function also logs an informational message if the file exists.
 func CheckIfPlaylistExists(cueFilePath string) bool {
-	if _, err := os.Stat(cueFilePath); err == nil {
-		log.Printf("info: Playlist already exists at %s", cueFilePath)
-		return true
-	}
-	return false
+	_, err := os.Stat(cueFilePath)
+	return err == nil
 }
 
 // CreateFolderIfNeeded ensures that the folder for the playlist file exists. If the folder does not exist, it creates it.
@@ -57,6 +52,18 @@ func CacheCoverArtPath(cacheLocation, discID string) string {
 	return getCachePath(cacheLocation, discID, "cover.jpg")
 }
 
+// CacheMetadataPath generates the file path where the disc metadata sidecar is cached based on the disc ID.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - discID (string): The disc ID used to generate the path for the cached metadata file.
+//
+// Returns:
+//   - string: The generated file path for the cached metadata file.
+func CacheMetadataPath(cacheLocation, discID string) string {
+	return getCachePath(cacheLocation, discID, "metadata.json")
+}
+
 // getCachePath constructs a file path within the cache directory for a given disc ID and filename.
 //
 // Parameters:
@@ -69,3 +76,28 @@ func CacheCoverArtPath(cacheLocation, discID string) string {
 func getCachePath(cacheLocation, discID, filename string) string {
 	return filepath.Join(cacheLocation, discID, filename)
 }
+
+// CacheFilePath generates the path of a file cached for the given disc ID.
+//
+// Parameters:
+//   - cacheLocation: The base directory for caching files.
+//   - discID (string): The disc ID used to create a subdirectory.
+//   - filename (string): The name of the cached file.
+//
+// Returns:
+//   - string: The generated file path.
+func CacheFilePath(cacheLocation, discID, filename string) string {
+	return getCachePath(cacheLocation, discID, filename)
+}
+
+// CheckIfFileExists checks if a regular file exists at the specified path.
+//
+// Parameters:
+//   - path (string): The path to check.
+//
+// Returns:
+//   - bool: True if a regular file exists, false otherwise.
+func CheckIfFileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
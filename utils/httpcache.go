@@ -0,0 +1,340 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CachingTransport is an http.RoundTripper keeping the GET responses of a web service on disk,
+// so that repeated runs do not download the same resources again:
+//   - Responses with an ETag or Last-Modified validator are revalidated with If-None-Match and
+//     If-Modified-Since, and served from disk on 304 Not Modified.
+//   - 404 Not Found responses are remembered for NegativeTTL and answered without network access.
+//   - Redirects are remembered for RedirectTTL and answered without network access.
+//
+// Bodies are written to disk while the caller reads them, and only cached once read entirely.
+// Responses are kept until removed by PruneHTTPCache. It is safe for concurrent use, including from
+// several processes sharing Dir.
+type CachingTransport struct {
+	// Base performs the requests. Uses http.DefaultTransport if nil.
+	Base http.RoundTripper
+	// Dir holds the cached responses.
+	Dir string
+	// NegativeTTL is how long a not found resource is remembered. Not found responses are not cached if zero.
+	NegativeTTL time.Duration
+	// RedirectTTL is how long a redirect is remembered. Redirects are not cached if zero.
+	RedirectTTL time.Duration
+	// MaxBodySize is the size in bytes above which a response is not cached, unlimited if zero.
+	MaxBodySize int64
+}
+
+// cachedResponse is the metadata of a cached response, saved next to its body. StoredAt is updated
+// when the response is revalidated, so that PruneHTTPCache keeps the responses still in use.
+type cachedResponse struct {
+	URL          string    `json:"url"`
+	Status       int       `json:"status"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	ContentType  string    `json:"contentType,omitempty"`
+	Location     string    `json:"location,omitempty"`
+	StoredAt     time.Time `json:"storedAt"`
+}
+
+// RoundTrip serves req from the cache when possible, revalidates it, or performs it and caches the response.
+func (t *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet {
+		return base.RoundTrip(req)
+	}
+
+	key := t.key(req.URL.String())
+	cached := t.load(key)
+	if cached != nil {
+		age := time.Since(cached.StoredAt)
+		switch {
+		case cached.Status == http.StatusNotFound && age < t.NegativeTTL:
+			return cachedHTTPResponse(req, cached, nil), nil
+		case isRedirect(cached.Status) && age < t.RedirectTTL:
+			return cachedHTTPResponse(req, cached, nil), nil
+		case cached.Status == http.StatusOK && CheckIfFileExists(t.bodyPath(key)):
+			req = req.Clone(req.Context())
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil && cached.Status == http.StatusOK:
+		resp.Body.Close()
+		body, err := os.ReadFile(t.bodyPath(key))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read cached response of %s: %w", req.URL, err)
+		}
+		cached.StoredAt = time.Now()
+		t.saveMeta(key, cached)
+		return cachedHTTPResponse(req, cached, body), nil
+	case resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "") &&
+		!strings.Contains(resp.Header.Get("Cache-Control"), "no-store") &&
+		(t.MaxBodySize <= 0 || resp.ContentLength <= t.MaxBodySize):
+		resp.Body = t.newCachingBody(key, newCachedResponse(req, resp), resp.Body)
+	case resp.StatusCode == http.StatusNotFound && t.NegativeTTL > 0:
+		t.save(key, newCachedResponse(req, resp))
+	case isRedirect(resp.StatusCode) && resp.Header.Get("Location") != "" && t.RedirectTTL > 0:
+		t.save(key, newCachedResponse(req, resp))
+	}
+	return resp, nil
+}
+
+// key returns the file name prefix of the cached response of url.
+func (t *CachingTransport) key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// metaPath returns the path of the metadata of a cached response.
+func (t *CachingTransport) metaPath(key string) string {
+	return filepath.Join(t.Dir, key+".json")
+}
+
+// bodyPath returns the path of the body of a cached response.
+func (t *CachingTransport) bodyPath(key string) string {
+	return filepath.Join(t.Dir, key+".body")
+}
+
+// load reads the metadata of a cached response, nil if there is none.
+func (t *CachingTransport) load(key string) *cachedResponse {
+	data, err := os.ReadFile(t.metaPath(key))
+	if err != nil {
+		return nil
+	}
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil
+	}
+	return &cached
+}
+
+// save writes the metadata of a cached response without body.
+// Failures only mean the next request will not be served from the cache, so they are ignored.
+func (t *CachingTransport) save(key string, cached *cachedResponse) {
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return
+	}
+	t.saveMeta(key, cached)
+}
+
+// saveMeta writes the metadata of a cached response, ignoring failures like save.
+func (t *CachingTransport) saveMeta(key string, cached *cachedResponse) {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	WriteFileAtomic(t.metaPath(key), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// cachingBody is the body of a cacheable response, copied to a temporary file while it is read. The
+// copy becomes the cached body once the response is read entirely, and the metadata is written after
+// it, so that metadata never references a missing body. Caching is abandoned, and the temporary file
+// removed, when the body exceeds MaxBodySize, a write fails, or the body is closed before its end.
+type cachingBody struct {
+	io.ReadCloser
+	transport *CachingTransport
+	key       string
+	cached    *cachedResponse
+	// file is the temporary copy, nil once caching is done or abandoned
+	file *os.File
+	size int64
+}
+
+// newCachingBody returns body copied to the cache while it is read, or body itself if the copy cannot be created.
+func (t *CachingTransport) newCachingBody(key string, cached *cachedResponse, body io.ReadCloser) io.ReadCloser {
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return body
+	}
+	file, err := os.CreateTemp(t.Dir, tempFilePrefix+key+".body-*")
+	if err != nil {
+		return body
+	}
+	return &cachingBody{ReadCloser: body, transport: t, key: key, cached: cached, file: file}
+}
+
+// Read reads the response body, copying it to the temporary file.
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.file != nil && n > 0 {
+		b.size += int64(n)
+		if max := b.transport.MaxBodySize; max > 0 && b.size > max {
+			b.abandon()
+		} else if _, werr := b.file.Write(p[:n]); werr != nil {
+			b.abandon()
+		}
+	}
+	if b.file != nil && err == io.EOF {
+		b.commit()
+	}
+	return n, err
+}
+
+// Close closes the response body, abandoning the copy of a body not read entirely.
+func (b *cachingBody) Close() error {
+	if b.file != nil {
+		b.abandon()
+	}
+	return b.ReadCloser.Close()
+}
+
+// commit moves the complete copy to the cached body, then writes the metadata.
+func (b *cachingBody) commit() {
+	file := b.file
+	b.file = nil
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return
+	}
+	if err := os.Rename(file.Name(), b.transport.bodyPath(b.key)); err != nil {
+		os.Remove(file.Name())
+		return
+	}
+	b.transport.saveMeta(b.key, b.cached)
+}
+
+// abandon removes the temporary copy.
+func (b *cachingBody) abandon() {
+	b.file.Close()
+	os.Remove(b.file.Name())
+	b.file = nil
+}
+
+// PruneHTTPCache removes the responses cached by a CachingTransport in dir that were stored or last
+// revalidated before cutoff, the bodies without metadata, and the temporary files of interrupted downloads.
+//
+// Parameters:
+//   - dir: The Dir of the CachingTransport.
+//   - cutoff: The responses stored before this time are removed.
+//
+// Returns:
+//   - int: The number of responses removed.
+//   - error: An error if dir cannot be read or a file cannot be removed. A missing dir is empty.
+func PruneHTTPCache(dir string, cutoff time.Time) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("Failed to read %s: %w", dir, err)
+	}
+	transport := &CachingTransport{Dir: dir}
+	var removed int
+	var errs []error
+	remove := func(path string) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		switch {
+		case IsTempFile(name):
+			// Leave the downloads in progress alone
+			if info.ModTime().Before(cutoff) {
+				remove(filepath.Join(dir, name))
+			}
+		case strings.HasSuffix(name, ".json"):
+			key := strings.TrimSuffix(name, ".json")
+			cached := transport.load(key)
+			if cached != nil && !cached.StoredAt.Before(cutoff) {
+				continue
+			}
+			// The metadata first, so that no metadata references a removed body
+			remove(transport.metaPath(key))
+			remove(transport.bodyPath(key))
+			removed++
+		case strings.HasSuffix(name, ".body"):
+			key := strings.TrimSuffix(name, ".body")
+			if !CheckIfFileExists(transport.metaPath(key)) && info.ModTime().Before(cutoff) {
+				remove(transport.bodyPath(key))
+			}
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+// newCachedResponse extracts the cached metadata of resp.
+func newCachedResponse(req *http.Request, resp *http.Response) *cachedResponse {
+	return &cachedResponse{
+		URL:          req.URL.String(),
+		Status:       resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Location:     resp.Header.Get("Location"),
+		StoredAt:     time.Now(),
+	}
+}
+
+// cachedHTTPResponse builds the response to req from a cached response.
+func cachedHTTPResponse(req *http.Request, cached *cachedResponse, body []byte) *http.Response {
+	header := http.Header{}
+	for name, value := range map[string]string{
+		"ETag":          cached.ETag,
+		"Last-Modified": cached.LastModified,
+		"Content-Type":  cached.ContentType,
+		"Location":      cached.Location,
+	} {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cached.Status, http.StatusText(cached.Status)),
+		StatusCode:    cached.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// isRedirect reports whether status is a redirect followed by http.Client.
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResource serves a body with an ETag, answering 304 to a matching If-None-Match, or 404 when
+// missing. It records the If-None-Match header of each request.
+type fakeResource struct {
+	mu          sync.Mutex
+	etag        string
+	body        string
+	missing     bool
+	ifNoneMatch []string
+}
+
+func (f *fakeResource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ifNoneMatch = append(f.ifNoneMatch, r.Header.Get("If-None-Match"))
+	switch {
+	case f.missing:
+		http.NotFound(w, r)
+	case r.Header.Get("If-None-Match") == f.etag:
+		w.WriteHeader(http.StatusNotModified)
+	default:
+		w.Header().Set("ETag", f.etag)
+		w.Header().Set("Content-Type", "image/jpeg")
+		io.WriteString(w, f.body)
+	}
+}
+
+// requests returns the number of requests received.
+func (f *fakeResource) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.ifNoneMatch)
+}
+
+// get performs a GET request of url through transport, returning the status and body.
+func get(t *testing.T, transport http.RoundTripper, url string) (int, string) {
+	t.Helper()
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// ageCachedResponse moves the storage time of the cached response of url back by d.
+func ageCachedResponse(t *testing.T, transport *CachingTransport, url string, d time.Duration) {
+	t.Helper()
+	key := transport.key(url)
+	cached := transport.load(key)
+	if cached == nil {
+		t.Fatalf("no cached response for %s", url)
+	}
+	cached.StoredAt = cached.StoredAt.Add(-d)
+	data, err := json.Marshal(cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(transport.metaPath(key), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachingTransportRevalidates(t *testing.T) {
+	resource := &fakeResource{etag: `"v1"`, body: "one"}
+	server := httptest.NewServer(resource)
+	defer server.Close()
+	transport := &CachingTransport{Dir: t.TempDir()}
+
+	for i := 0; i < 2; i++ {
+		if status, body := get(t, transport, server.URL); status != http.StatusOK || body != "one" {
+			t.Errorf("request %d = %d %q, want 200 \"one\"", i+1, status, body)
+		}
+	}
+	if want := []string{"", `"v1"`}; resource.ifNoneMatch[0] != want[0] || resource.ifNoneMatch[1] != want[1] {
+		t.Errorf("If-None-Match = %q, want %q", resource.ifNoneMatch, want)
+	}
+
+	// A changed resource is downloaded again and replaces the cached one
+	resource.mu.Lock()
+	resource.etag, resource.body = `"v2"`, "two"
+	resource.mu.Unlock()
+	if status, body := get(t, transport, server.URL); status != http.StatusOK || body != "two" {
+		t.Errorf("request of the changed resource = %d %q, want 200 \"two\"", status, body)
+	}
+	if status, body := get(t, transport, server.URL); status != http.StatusOK || body != "two" {
+		t.Errorf("request after the change = %d %q, want 200 \"two\"", status, body)
+	}
+	if got := resource.ifNoneMatch[3]; got != `"v2"` {
+		t.Errorf("If-None-Match after the change = %q, want the new ETag", got)
+	}
+}
+
+func TestCachingTransportNegativeTTL(t *testing.T) {
+	resource := &fakeResource{missing: true}
+	server := httptest.NewServer(resource)
+	defer server.Close()
+	transport := &CachingTransport{Dir: t.TempDir(), NegativeTTL: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		if status, _ := get(t, transport, server.URL); status != http.StatusNotFound {
+			t.Errorf("request %d = %d, want 404", i+1, status)
+		}
+	}
+	if got := resource.requests(); got != 1 {
+		t.Errorf("server requests = %d, want 1 while the 404 is remembered", got)
+	}
+
+	// Once NegativeTTL expired, the resource is requested again
+	ageCachedResponse(t, transport, server.URL, time.Hour)
+	resource.mu.Lock()
+	resource.missing, resource.etag, resource.body = false, `"v1"`, "found"
+	resource.mu.Unlock()
+	if status, body := get(t, transport, server.URL); status != http.StatusOK || body != "found" {
+		t.Errorf("request after NegativeTTL = %d %q, want 200 \"found\"", status, body)
+	}
+	if got := resource.requests(); got != 2 {
+		t.Errorf("server requests = %d, want 2", got)
+	}
+}
+
+func TestCachingTransportWithoutNegativeTTL(t *testing.T) {
+	resource := &fakeResource{missing: true}
+	server := httptest.NewServer(resource)
+	defer server.Close()
+	transport := &CachingTransport{Dir: t.TempDir()}
+
+	get(t, transport, server.URL)
+	get(t, transport, server.URL)
+	if got := resource.requests(); got != 2 {
+		t.Errorf("server requests = %d, want 2 without NegativeTTL", got)
+	}
+}
+
+func TestCachingTransportRedirectTTL(t *testing.T) {
+	var redirects int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/release", func(w http.ResponseWriter, r *http.Request) {
+		redirects++
+		http.Redirect(w, r, "/image.jpg", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/image.jpg", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "image")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	transport := &CachingTransport{Dir: t.TempDir(), RedirectTTL: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		if status, body := get(t, transport, server.URL+"/release"); status != http.StatusOK || body != "image" {
+			t.Errorf("request %d = %d %q, want the redirect followed", i+1, status, body)
+		}
+	}
+	if redirects != 1 {
+		t.Errorf("redirects served = %d, want 1 while the redirect is remembered", redirects)
+	}
+}
+
+// assertCacheFiles fails unless dir holds want files.
+func assertCacheFiles(t *testing.T, dir string, want int) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != want {
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		t.Errorf("cache files = %v, want %d", names, want)
+	}
+}
+
+func TestCachingTransportMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		chunked   bool
+		wantCache bool
+	}{
+		{name: "within the limit", body: "12345", wantCache: true},
+		{name: "over the limit", body: "123456"},
+		{name: "chunked within the limit", body: "12345", chunked: true, wantCache: true},
+		// The length is only known while reading
+		{name: "chunked over the limit", body: "123456", chunked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ifNoneMatch []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				if tt.chunked {
+					io.WriteString(w, tt.body[:1])
+					w.(http.Flusher).Flush()
+					io.WriteString(w, tt.body[1:])
+					return
+				}
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+			transport := &CachingTransport{Dir: t.TempDir(), MaxBodySize: 5}
+
+			for i := 0; i < 2; i++ {
+				if status, body := get(t, transport, server.URL); status != http.StatusOK || body != tt.body {
+					t.Errorf("request %d = %d %q, want 200 %q", i+1, status, body, tt.body)
+				}
+			}
+			if cached := ifNoneMatch[1] != ""; cached != tt.wantCache {
+				t.Errorf("response cached = %v, want %v", cached, tt.wantCache)
+			}
+			if tt.wantCache {
+				assertCacheFiles(t, transport.Dir, 2)
+			} else {
+				assertCacheFiles(t, transport.Dir, 0)
+			}
+		})
+	}
+}
+
+func TestCachingTransportPartialRead(t *testing.T) {
+	resource := &fakeResource{etag: `"v1"`, body: "a body read partially"}
+	server := httptest.NewServer(resource)
+	defer server.Close()
+	transport := &CachingTransport{Dir: t.TempDir()}
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadFull(resp.Body, make([]byte, 2))
+	resp.Body.Close()
+	assertCacheFiles(t, transport.Dir, 0)
+
+	if status, body := get(t, transport, server.URL); status != http.StatusOK || body != resource.body {
+		t.Errorf("request after a partial read = %d %q, want the whole body", status, body)
+	}
+	if got := resource.ifNoneMatch[1]; got != "" {
+		t.Errorf("If-None-Match after a partial read = %q, want none", got)
+	}
+}
+
+func TestPruneHTTPCache(t *testing.T) {
+	used := &fakeResource{etag: `"v1"`, body: "used"}
+	usedServer := httptest.NewServer(used)
+	defer usedServer.Close()
+	unused := &fakeResource{etag: `"v1"`, body: "unused"}
+	unusedServer := httptest.NewServer(unused)
+	defer unusedServer.Close()
+	transport := &CachingTransport{Dir: t.TempDir()}
+
+	get(t, transport, usedServer.URL)
+	get(t, transport, unusedServer.URL)
+	ageCachedResponse(t, transport, usedServer.URL, 2*time.Hour)
+	ageCachedResponse(t, transport, unusedServer.URL, 2*time.Hour)
+	// Revalidating a response marks it as used
+	get(t, transport, usedServer.URL)
+
+	// Leftovers of interrupted writes
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"orphan.body", tempFilePrefix + "key.body-1"} {
+		path := filepath.Join(transport.Dir, name)
+		os.WriteFile(path, []byte("leftover"), 0644)
+		os.Chtimes(path, old, old)
+	}
+
+	removed, err := PruneHTTPCache(transport.Dir, time.Now().Add(-time.Hour))
+	if err != nil || removed != 1 {
+		t.Fatalf("PruneHTTPCache() = %d, %v, want 1 response removed", removed, err)
+	}
+	assertCacheFiles(t, transport.Dir, 2)
+	if transport.load(transport.key(usedServer.URL)) == nil {
+		t.Error("response used since the cutoff removed")
+	}
+	if removed, err := PruneHTTPCache(filepath.Join(transport.Dir, "missing"), time.Now()); err != nil || removed != 0 {
+		t.Errorf("PruneHTTPCache() of a missing folder = %d, %v, want 0, nil", removed, err)
+	}
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Image formats detected by SniffImageFormat.
+const (
+	ImageJPEG = "jpeg"
+	ImagePNG  = "png"
+	ImageWebP = "webp"
+)
+
+// maxImageDimension rejects images whose decoding would use an unreasonable amount of memory.
+const maxImageDimension = 16384
+
+// resizedJPEGQuality is the quality of the JPEG images re-encoded after resizing or conversion.
+const resizedJPEGQuality = 90
+
+// ImageLimits constrains the images accepted by NormalizeImage.
+//
+// Fields:
+//   - MaxBytes (int64): The maximum size of the image data, unlimited if zero.
+//   - MinDimension (int): The minimum width and height in pixels, to reject placeholders.
+//   - MaxDimension (int): Larger images are resized to fit this width and height, kept as is if zero.
+type ImageLimits struct {
+	MaxBytes     int64
+	MinDimension int
+	MaxDimension int
+}
+
+// SniffImageFormat detects the format of an image from its content.
+//
+// Parameters:
+//   - data: The image data.
+//
+// Returns:
+//   - string: ImageJPEG, ImagePNG or ImageWebP.
+//   - error: An error naming the detected content type if data is not a JPEG, PNG or WebP image.
+func SniffImageFormat(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return ImageJPEG, nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return ImagePNG, nil
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return ImageWebP, nil
+	default:
+		return "", fmt.Errorf("not a JPEG, PNG or WebP image: %s", http.DetectContentType(data))
+	}
+}
+
+// ImageExt returns the file extension of an image format, e.g. ".jpg" for ImageJPEG.
+func ImageExt(format string) string {
+	if format == ImageJPEG {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+// NormalizeImage validates an image against limits and resizes it if it exceeds MaxDimension.
+// Resized PNG images stay PNG, other formats are re-encoded as JPEG.
+//
+// Parameters:
+//   - data: The image data.
+//   - limits: The size and dimension limits.
+//
+// Returns:
+//   - []byte: The image data, resized if needed.
+//   - string: The format of the returned image.
+//   - image.Point: The dimensions of the returned image.
+//   - error: An error if the image is too large, not a JPEG, PNG or WebP image, corrupt or too small.
+func NormalizeImage(data []byte, limits ImageLimits) ([]byte, string, image.Point, error) {
+	if limits.MaxBytes > 0 && int64(len(data)) > limits.MaxBytes {
+		return nil, "", image.Point{}, fmt.Errorf("image of %d bytes exceeds the %d bytes limit", len(data), limits.MaxBytes)
+	}
+	format, err := SniffImageFormat(data)
+	if err != nil {
+		return nil, "", image.Point{}, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", image.Point{}, fmt.Errorf("invalid %s image: %w", format, err)
+	}
+	size := image.Pt(config.Width, config.Height)
+	if size.X < limits.MinDimension || size.Y < limits.MinDimension {
+		return nil, "", size, fmt.Errorf("image of %dx%d pixels is smaller than %d pixels", size.X, size.Y, limits.MinDimension)
+	}
+	if size.X > maxImageDimension || size.Y > maxImageDimension {
+		return nil, "", size, fmt.Errorf("image of %dx%d pixels exceeds %d pixels", size.X, size.Y, maxImageDimension)
+	}
+	if limits.MaxDimension <= 0 || (size.X <= limits.MaxDimension && size.Y <= limits.MaxDimension) {
+		return data, format, size, nil
+	}
+	return resizeImage(data, format, size, limits.MaxDimension)
+}
+
+// ConvertToJPEG re-encodes an image as JPEG, for the consumers not supporting the other formats.
+//
+// Parameters:
+//   - data: The image data, a JPEG, PNG or WebP image.
+//
+// Returns:
+//   - []byte: The JPEG image.
+//   - error: An error if the image cannot be decoded or encoded.
+func ConvertToJPEG(data []byte) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: resizedJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("Failed to encode %s image as JPEG: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// resizeImage scales an image down to fit maxDimension, keeping its aspect ratio.
+func resizeImage(data []byte, format string, size image.Point, maxDimension int) ([]byte, string, image.Point, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", size, fmt.Errorf("invalid %s image: %w", format, err)
+	}
+	scaled := image.Pt(maxDimension, maxDimension)
+	if size.X > size.Y {
+		scaled.Y = max(1, size.Y*maxDimension/size.X)
+	} else {
+		scaled.X = max(1, size.X*maxDimension/size.Y)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, scaled.X, scaled.Y))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if format == ImagePNG {
+		err = png.Encode(&buf, dst)
+	} else {
+		format = ImageJPEG
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: resizedJPEGQuality})
+	}
+	if err != nil {
+		return nil, "", size, fmt.Errorf("Failed to encode resized image: %w", err)
+	}
+	return buf.Bytes(), format, scaled, nil
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// webpImage is a 1x1 lossless WebP image.
+const webpImage = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+// testImage returns an image of width x height pixels encoded as JPEG or PNG.
+func testImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	var buf bytes.Buffer
+	var err error
+	if format == ImagePNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniffImageFormat(t *testing.T) {
+	webp, err := base64.StdEncoding.DecodeString(webpImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr string
+	}{
+		{name: "jpeg", data: testImage(t, ImageJPEG, 2, 2), want: ImageJPEG},
+		{name: "png", data: testImage(t, ImagePNG, 2, 2), want: ImagePNG},
+		{name: "webp", data: webp, want: ImageWebP},
+		{name: "html", data: []byte("<html><body>Not Found</body></html>"), wantErr: "text/html"},
+		{name: "gif", data: []byte("GIF89a\x01\x00\x01\x00"), wantErr: "image/gif"},
+		{name: "riff without webp", data: []byte("RIFF\x00\x00\x00\x00WAVEfmt "), wantErr: "not a JPEG"},
+		{name: "empty", data: nil, wantErr: "not a JPEG"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SniffImageFormat(tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("SniffImageFormat() = %q, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("SniffImageFormat() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	webp, err := base64.StdEncoding.DecodeString(webpImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		data       []byte
+		limits     ImageLimits
+		wantFormat string
+		wantSize   image.Point
+		wantErr    bool
+	}{
+		{name: "kept", data: testImage(t, ImageJPEG, 300, 200), limits: ImageLimits{MinDimension: 100, MaxDimension: 500}, wantFormat: ImageJPEG, wantSize: image.Pt(300, 200)},
+		{name: "no limits", data: webp, wantFormat: ImageWebP, wantSize: image.Pt(1, 1)},
+		{name: "landscape resized", data: testImage(t, ImageJPEG, 400, 200), limits: ImageLimits{MaxDimension: 100}, wantFormat: ImageJPEG, wantSize: image.Pt(100, 50)},
+		{name: "portrait resized", data: testImage(t, ImagePNG, 200, 400), limits: ImageLimits{MaxDimension: 100}, wantFormat: ImagePNG, wantSize: image.Pt(50, 100)},
+		{name: "thin image keeps a pixel", data: testImage(t, ImagePNG, 1000, 2), limits: ImageLimits{MaxDimension: 100}, wantFormat: ImagePNG, wantSize: image.Pt(100, 1)},
+		{name: "exactly the maximum", data: testImage(t, ImagePNG, 100, 100), limits: ImageLimits{MaxDimension: 100}, wantFormat: ImagePNG, wantSize: image.Pt(100, 100)},
+		{name: "too small", data: testImage(t, ImagePNG, 50, 200), limits: ImageLimits{MinDimension: 100}, wantErr: true},
+		{name: "too large", data: testImage(t, ImagePNG, 10, 10), limits: ImageLimits{MaxBytes: 10}, wantErr: true},
+		{name: "beyond the decoding limit", data: testImage(t, ImagePNG, maxImageDimension+1, 1), limits: ImageLimits{MaxDimension: 100}, wantErr: true},
+		{name: "truncated", data: testImage(t, ImagePNG, 10, 10)[:20], wantErr: true},
+		{name: "not an image", data: []byte("<html></html>"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, format, size, err := NormalizeImage(tt.data, tt.limits)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NormalizeImage() error = nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeImage() error = %v", err)
+			}
+			if format != tt.wantFormat || size != tt.wantSize {
+				t.Errorf("NormalizeImage() = %s %v, want %s %v", format, size, tt.wantFormat, tt.wantSize)
+			}
+			// The returned data is an image of the returned format and size
+			config, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("returned data: %v", err)
+			}
+			if decoded != format || image.Pt(config.Width, config.Height) != size {
+				t.Errorf("returned data = %s %dx%d, want %s %v", decoded, config.Width, config.Height, format, size)
+			}
+		})
+	}
+}
+
+func TestImageExt(t *testing.T) {
+	for format, want := range map[string]string{ImageJPEG: ".jpg", ImagePNG: ".png", ImageWebP: ".webp"} {
+		if got := ImageExt(format); got != want {
+			t.Errorf("ImageExt(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestConvertToJPEG(t *testing.T) {
+	webp, err := base64.StdEncoding.DecodeString(webpImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"webp": webp, "png": testImage(t, ImagePNG, 2, 2)} {
+		converted, err := ConvertToJPEG(data)
+		if err != nil {
+			t.Fatalf("ConvertToJPEG() of %s error = %v", name, err)
+		}
+		if format, _ := SniffImageFormat(converted); format != ImageJPEG {
+			t.Errorf("ConvertToJPEG() of %s = %q image, want %q", name, format, ImageJPEG)
+		}
+	}
+	if _, err := ConvertToJPEG([]byte("<html></html>")); err == nil {
+		t.Error("ConvertToJPEG() of HTML: error = nil")
+	}
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimitedTransport is an http.RoundTripper enforcing a minimum delay between two requests
+// to the same host, as required by web services like MusicBrainz. It is safe for concurrent use,
+// so a single client can be shared by several generators.
+type RateLimitedTransport struct {
+	// Base performs the requests. Uses http.DefaultTransport if nil.
+	Base http.RoundTripper
+	// Interval is the minimum delay between two requests to the same host.
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// NewRateLimitedClient creates an HTTP client waiting at least interval between two requests to the same host.
+//
+// Parameters:
+//   - interval (time.Duration): The minimum delay between two requests to the same host. No limit if zero.
+//
+// Returns:
+//   - *http.Client: The rate limited client.
+func NewRateLimitedClient(interval time.Duration) *http.Client {
+	return &http.Client{Transport: &RateLimitedTransport{Interval: interval}}
+}
+
+// RoundTrip waits for the request host slot, then performs the request.
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if wait := t.reserve(req.URL.Host); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// reserve books the next request slot for host and returns how long to wait for it.
+func (t *RateLimitedTransport) reserve(host string) time.Duration {
+	if t.Interval <= 0 {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.next == nil {
+		t.next = map[string]time.Time{}
+	}
+	now := time.Now()
+	slot := t.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next[host] = slot.Add(t.Interval)
+	return slot.Sub(now)
+}
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uploadedlobster.com/discid"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+// framesPerSecond is the number of CD frames (sectors) per second of audio.
+const framesPerSecond = 75
+
+// ErrInvalidToc is returned by ParseMusicBrainzToc when a TOC cannot be parsed or describes no readable disc.
+var ErrInvalidToc = errors.New("invalid TOC")
+
+// Toc holds the table of contents of an audio disc, independently of the drive it was read from.
+//
+// Fields:
+//   - FirstTrack (int): The number of the first track (usually 1).
+//   - LastTrack (int): The number of the last track.
+//   - LeadOut (int): The lead-out offset in frames, i.e. the total length of the disc.
+//   - Offsets ([]int): The start offset of each track in frames, including the 150 frames pregap.
+type Toc struct {
+	FirstTrack int
+	LastTrack  int
+	LeadOut    int
+	Offsets    []int
+}
+
+// NewTocFromDisc builds a Toc from a disc read by libdiscid.
+//
+// Parameters:
+//   - disc (discid.Disc): The disc object containing the track offsets.
+//
+// Returns:
+//   - *Toc: The table of contents of the disc.
+//   - error: Any error encountered while reading a track.
+func NewTocFromDisc(disc discid.Disc) (*Toc, error) {
+	toc := &Toc{
+		FirstTrack: disc.FirstTrackNumber(),
+		LastTrack:  disc.LastTrackNumber(),
+		LeadOut:    disc.Sectors(),
+	}
+	for i := toc.FirstTrack; i <= toc.LastTrack; i++ {
+		track, err := disc.Track(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read track %d: %w", i, err)
+		}
+		toc.Offsets = append(toc.Offsets, track.Offset)
+	}
+	return toc, toc.Validate()
+}
+
+// ParseMusicBrainzToc parses a TOC in MusicBrainz format ("first last leadout offset1 ... offsetN").
+// Fields may be separated by spaces or '+' signs, as found in MusicBrainz URLs.
+//
+// Parameters:
+//   - mbToc (string): The TOC string to parse.
+//
+// Returns:
+//   - *Toc: The parsed table of contents.
+//   - error: An error wrapping ErrInvalidToc if the string is malformed or describes an impossible disc.
+func ParseMusicBrainzToc(mbToc string) (*Toc, error) {
+	fields := strings.Fields(strings.ReplaceAll(mbToc, "+", " "))
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("%w %q: expected at least 4 fields", ErrInvalidToc, mbToc)
+	}
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidToc, mbToc, err)
+		}
+		values[i] = value
+	}
+	toc := &Toc{
+		FirstTrack: values[0],
+		LastTrack:  values[1],
+		LeadOut:    values[2],
+		Offsets:    values[3:],
+	}
+	if err := toc.Validate(); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidToc, mbToc, err)
+	}
+	return toc, nil
+}
+
+// Validate checks that the track numbers and offsets describe a readable disc.
+//
+// Returns:
+//   - error: An error describing the first inconsistency found; nil otherwise.
+func (t *Toc) Validate() error {
+	if t.FirstTrack < 1 || t.LastTrack > 99 || t.FirstTrack > t.LastTrack {
+		return fmt.Errorf("invalid track range %d-%d", t.FirstTrack, t.LastTrack)
+	}
+	if len(t.Offsets) != t.TrackCount() {
+		return fmt.Errorf("expected %d track offsets, got %d", t.TrackCount(), len(t.Offsets))
+	}
+	previous := 0
+	for i, offset := range t.Offsets {
+		if offset < previous {
+			return fmt.Errorf("track %d offset %d is before previous track", t.FirstTrack+i, offset)
+		}
+		previous = offset
+	}
+	if t.LeadOut <= previous {
+		return fmt.Errorf("lead-out %d is before last track offset %d", t.LeadOut, previous)
+	}
+	return nil
+}
+
+// TrackCount returns the number of tracks described by the TOC.
+func (t *Toc) TrackCount() int {
+	return t.LastTrack - t.FirstTrack + 1
+}
+
+// TrackFrames returns the length in frames of a track, numbered from FirstTrack.
+//
+// Parameters:
+//   - number (int): The track number.
+//
+// Returns:
+//   - int: The track length in frames, 0 if the track is not on the disc.
+func (t *Toc) TrackFrames(number int) int {
+	i := number - t.FirstTrack
+	if i < 0 || i >= len(t.Offsets) {
+		return 0
+	}
+	if i == len(t.Offsets)-1 {
+		return t.LeadOut - t.Offsets[i]
+	}
+	return t.Offsets[i+1] - t.Offsets[i]
+}
+
+// TrackDuration returns the duration of a track, numbered from FirstTrack.
+//
+// Parameters:
+//   - number (int): The track number.
+//
+// Returns:
+//   - time.Duration: The track duration, 0 if the track is not on the disc.
+func (t *Toc) TrackDuration(number int) time.Duration {
+	return time.Duration(t.TrackFrames(number)) * time.Second / framesPerSecond
+}
+
+// FreedbID computes the FreeDB (CDDB) disc ID, using the same algorithm as libdiscid.
+//
+// Returns:
+//   - string: The 8 hexadecimal digits FreeDB ID.
+func (t *Toc) FreedbID() string {
+	sum := 0
+	for _, offset := range t.Offsets {
+		sum += digitSum(offset / framesPerSecond)
+	}
+	length := t.LeadOut/framesPerSecond - t.Offsets[0]/framesPerSecond
+	return fmt.Sprintf("%08x", (sum%0xff)<<24|length<<8|t.TrackCount())
+}
+
+// MusicBrainzDiscID computes the MusicBrainz disc ID, using the same algorithm as libdiscid.
+// Unlike the FreeDB ID, it is a hash of the full TOC and practically collision free.
+//
+// Returns:
+//   - string: The 28 characters MusicBrainz disc ID.
+func (t *Toc) MusicBrainzDiscID() string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%02X%02X%08X", t.FirstTrack, t.LastTrack, t.LeadOut)
+	for track := 1; track <= 99; track++ {
+		offset := 0
+		if i := track - t.FirstTrack; i >= 0 && i < len(t.Offsets) {
+			offset = t.Offsets[i]
+		}
+		fmt.Fprintf(hash, "%08X", offset)
+	}
+	encoded := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	return strings.NewReplacer("+", ".", "/", "_", "=", "-").Replace(encoded)
+}
+
+// GnuString returns the TOC formatted for GNUDB queries ("freedbID count offset1 ... offsetN seconds").
+func (t *Toc) GnuString() string {
+	parts := []string{t.FreedbID(), strconv.Itoa(t.TrackCount())}
+	for _, offset := range t.Offsets {
+		parts = append(parts, strconv.Itoa(offset))
+	}
+	parts = append(parts, strconv.Itoa(t.LeadOut/framesPerSecond))
+	return strings.Join(parts, " ")
+}
+
+// MusicBrainzString returns the TOC formatted for MusicBrainz queries ("first last leadout offset1 ... offsetN").
+func (t *Toc) MusicBrainzString() string {
+	parts := []string{strconv.Itoa(t.FirstTrack), strconv.Itoa(t.LastTrack), strconv.Itoa(t.LeadOut)}
+	for _, offset := range t.Offsets {
+		parts = append(parts, strconv.Itoa(offset))
+	}
+	return strings.Join(parts, " ")
+}
+
+// digitSum returns the sum of the decimal digits of n, as used by the FreeDB ID algorithm.
+func digitSum(n int) int {
+	sum := 0
+	for n > 0 {
+		sum += n % 10
+		n /= 10
+	}
+	return sum
+}
+
+// NewTocFromMusicBrainzDisc builds a Toc from a disc ID attached to a MusicBrainz medium.
+//
+// Parameters:
+//   - disc (types.MBDisc): The MusicBrainz disc, as returned with inc=discids.
+//
+// Returns:
+//   - *Toc: The table of contents the disc ID was computed from.
+//   - error: An error if the disc data is inconsistent.
+func NewTocFromMusicBrainzDisc(disc types.MBDisc) (*Toc, error) {
+	toc := &Toc{
+		FirstTrack: 1,
+		LastTrack:  disc.OffsetCount,
+		LeadOut:    disc.Sectors,
+		Offsets:    disc.Offsets,
+	}
+	if err := toc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid MusicBrainz disc %s: %w", disc.ID, err)
+	}
+	return toc, nil
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/b0bbywan/go-disc-cuer/types"
+)
+
+// tocVectors are real discs with the IDs computed by libdiscid, from the libdiscid and python-discid test suites.
+var tocVectors = []struct {
+	name     string
+	toc      string
+	discID   string
+	freedbID string
+}{
+	{
+		name:     "libdiscid",
+		toc:      "1 10 206535 150 18901 39738 59557 79152 100126 124833 147278 166336 182560",
+		discID:   "Wn8eRBtfLDfM0qjYPdxrz.Zjs_U-",
+		freedbID: "830abf0a",
+	},
+	{
+		name:     "python-discid",
+		toc:      "1 15 258725 150 17510 33275 45910 57805 78310 94650 109580 132010 149160 165115 177710 203325 215555 235590",
+		discID:   "TqvKjMu7dMliSfmVEBtrL7sBSno-",
+		freedbID: "b60d770f",
+	},
+}
+
+func TestTocIDs(t *testing.T) {
+	for _, tt := range tocVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			toc, err := ParseMusicBrainzToc(tt.toc)
+			if err != nil {
+				t.Fatalf("ParseMusicBrainzToc() error = %v", err)
+			}
+			if got := toc.MusicBrainzDiscID(); got != tt.discID {
+				t.Errorf("MusicBrainzDiscID() = %q, want %q", got, tt.discID)
+			}
+			if got := toc.FreedbID(); got != tt.freedbID {
+				t.Errorf("FreedbID() = %q, want %q", got, tt.freedbID)
+			}
+			if got := toc.MusicBrainzString(); got != tt.toc {
+				t.Errorf("MusicBrainzString() = %q, want %q", got, tt.toc)
+			}
+		})
+	}
+}
+
+func TestTocFromMusicBrainzDisc(t *testing.T) {
+	for _, tt := range tocVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseMusicBrainzToc(tt.toc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			disc := types.MBDisc{ID: tt.discID, Sectors: parsed.LeadOut, OffsetCount: len(parsed.Offsets), Offsets: parsed.Offsets}
+			toc, err := NewTocFromMusicBrainzDisc(disc)
+			if err != nil {
+				t.Fatalf("NewTocFromMusicBrainzDisc() error = %v", err)
+			}
+			if !reflect.DeepEqual(toc, parsed) {
+				t.Errorf("NewTocFromMusicBrainzDisc() = %+v, want %+v", toc, parsed)
+			}
+			if got := toc.MusicBrainzDiscID(); got != disc.ID {
+				t.Errorf("MusicBrainzDiscID() = %q, want the disc ID %q", got, disc.ID)
+			}
+		})
+	}
+
+	if _, err := NewTocFromMusicBrainzDisc(types.MBDisc{ID: "bad", Sectors: 1000, OffsetCount: 2, Offsets: []int{150}}); err == nil {
+		t.Error("NewTocFromMusicBrainzDisc() with missing offsets: error = nil")
+	}
+}
+
+func TestTocGnuString(t *testing.T) {
+	toc, err := ParseMusicBrainzToc(tocVectors[1].toc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "b60d770f 15 150 17510 33275 45910 57805 78310 94650 109580 132010 149160 165115 177710 203325 215555 235590 3449"
+	if got := toc.GnuString(); got != want {
+		t.Errorf("GnuString() = %q, want %q", got, want)
+	}
+}
+
+func TestParseMusicBrainzToc(t *testing.T) {
+	toc, err := ParseMusicBrainzToc("1+3+100000+150+30000+60000")
+	if err != nil {
+		t.Fatalf("ParseMusicBrainzToc() with + separators: error = %v", err)
+	}
+	want := &Toc{FirstTrack: 1, LastTrack: 3, LeadOut: 100000, Offsets: []int{150, 30000, 60000}}
+	if !reflect.DeepEqual(toc, want) {
+		t.Errorf("ParseMusicBrainzToc() = %+v, want %+v", toc, want)
+	}
+	if got := toc.TrackFrames(2); got != 30000 {
+		t.Errorf("TrackFrames(2) = %d, want 30000", got)
+	}
+	if got := toc.TrackFrames(3); got != 40000 {
+		t.Errorf("TrackFrames(3) = %d, want the frames up to the lead-out, 40000", got)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"1 3 100000",
+		"1 3 100000 150 30000",
+		"1 2 100000 150 x",
+		"0 1 100000 150",
+		"2 1 100000 150",
+		"1 2 100000 30000 150",
+		"1 2 30000 150 60000",
+	} {
+		if _, err := ParseMusicBrainzToc(invalid); !errors.Is(err, ErrInvalidToc) {
+			t.Errorf("ParseMusicBrainzToc(%q) error = %v, want ErrInvalidToc", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+//go:build linux
+
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Linux CD-ROM ioctls and their results, from linux/cdrom.h.
+const (
+	cdromDriveStatus = 0x5326
+	cdromDiscStatus  = 0x5327
+	cdslCurrent      = 0x7fffffff
+
+	cdsNoDisc        = 1
+	cdsTrayOpen      = 2
+	cdsDriveNotReady = 3
+	cdsDiscOK        = 4
+	cdsAudio         = 100
+	cdsMixed         = 105
+)
+
+// netlinkKobjectUevent is the netlink protocol of the kernel uevents.
+const netlinkKobjectUevent = 15
+
+// cdromInfo lists the CD-ROM drives registered by the kernel.
+const cdromInfo = "/proc/sys/dev/cdrom/info"
+
+// listDrives returns the CD-ROM drives of the system, from the "drive name:" line of
+// /proc/sys/dev/cdrom/info, else the /dev/sr* devices.
+//
+// Returns:
+//   - []string: The device paths, sorted, e.g. [/dev/sr0 /dev/sr1].
+//   - error: An error if the drives cannot be listed.
+func listDrives() ([]string, error) {
+	var drives []string
+	if data, err := os.ReadFile(cdromInfo); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if name, ok := strings.CutPrefix(line, "drive name:"); ok {
+				for _, field := range strings.Fields(name) {
+					drives = append(drives, filepath.Join("/dev", field))
+				}
+				break
+			}
+		}
+	}
+	if len(drives) == 0 {
+		var err error
+		if drives, err = filepath.Glob("/dev/sr[0-9]*"); err != nil {
+			return nil, fmt.Errorf("Failed to list drives: %w", err)
+		}
+	}
+	sort.Strings(drives)
+	return drives, nil
+}
+
+// driveStatus queries the drive and disc status of device with the CDROM_DRIVE_STATUS and
+// CDROM_DISC_STATUS ioctls.
+//
+// Parameters:
+//   - device: The drive device, e.g. /dev/sr0.
+//
+// Returns:
+//   - Status: The status of the drive.
+//   - error: An error if the device cannot be opened or is not a CD drive.
+func driveStatus(device string) (Status, error) {
+	fd, err := syscall.Open(device, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return StatusUnknown, fmt.Errorf("Failed to open %s: %w", device, err)
+	}
+	defer syscall.Close(fd)
+
+	drive, err := ioctl(fd, cdromDriveStatus, cdslCurrent)
+	if err != nil {
+		return StatusUnknown, fmt.Errorf("Failed to read %s drive status: %w", device, err)
+	}
+	switch drive {
+	case cdsNoDisc:
+		return StatusNoDisc, nil
+	case cdsTrayOpen:
+		return StatusTrayOpen, nil
+	case cdsDriveNotReady:
+		return StatusNotReady, nil
+	case cdsDiscOK:
+	default:
+		return StatusUnknown, nil
+	}
+
+	disc, err := ioctl(fd, cdromDiscStatus, 0)
+	if err != nil {
+		return StatusUnknown, fmt.Errorf("Failed to read %s disc status: %w", device, err)
+	}
+	if disc == cdsAudio || disc == cdsMixed {
+		return StatusAudio, nil
+	}
+	return StatusData, nil
+}
+
+// ioctl performs an ioctl taking an integer argument and returns its result.
+func ioctl(fd int, request, arg uintptr) (uintptr, error) {
+	r, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, arg)
+	if errno != 0 {
+		return 0, errno
+	}
+	return r, nil
+}
+
+// listenUevents receives the kernel uevents over netlink and signals those about device on events,
+// until done is closed.
+//
+// Parameters:
+//   - device: The drive device, matched against the uevent DEVNAME.
+//   - events: Receives a value for each uevent of the device. Sends do not block.
+//   - done: Stops listening when closed.
+//
+// Returns:
+//   - error: An error if the netlink socket cannot be opened.
+func listenUevents(device string, events chan<- struct{}, done <-chan struct{}) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, netlinkKobjectUevent)
+	if err != nil {
+		return fmt.Errorf("Failed to open uevent socket: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}); err != nil {
+		syscall.Close(fd)
+		return fmt.Errorf("Failed to bind uevent socket: %w", err)
+	}
+	// The nonblocking socket is registered with the runtime poller, so closing the file makes a
+	// pending Read return, which closing the raw descriptor would not
+	socket := os.NewFile(uintptr(fd), "uevent")
+	go func() {
+		<-done
+		socket.Close()
+	}()
+
+	devName := []byte("DEVNAME=" + trimDev(device))
+	go func() {
+		buf := make([]byte, 16*1024)
+		for {
+			n, err := socket.Read(buf)
+			if err != nil {
+				// Missed uevents are caught up by polling
+				if errors.Is(err, syscall.ENOBUFS) {
+					continue
+				}
+				return
+			}
+			for _, field := range bytes.Split(buf[:n], []byte{0}) {
+				if bytes.Equal(field, devName) {
+					select {
+					case events <- struct{}{}:
+					default:
+					}
+					break
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// trimDev returns the uevent DEVNAME of a device path, e.g. "sr0" for /dev/sr0, resolving symbolic
+// links such as /dev/cdrom.
+func trimDev(device string) string {
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	if rel, err := filepath.Rel("/dev", device); err == nil && !filepath.IsAbs(rel) && rel[0] != '.' {
+		return rel
+	}
+	return filepath.Base(device)
+}
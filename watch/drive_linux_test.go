@@ -0,0 +1,42 @@
+//go:build linux
+
+package watch
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestListenUeventsStops checks that the receiving goroutines end once done is closed.
+func TestListenUeventsStops(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		done := make(chan struct{})
+		if err := listenUevents("/dev/sr0", make(chan struct{}, 1), done); err != nil {
+			t.Skipf("uevent socket unavailable: %v", err)
+		}
+		close(done)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTrimDev(t *testing.T) {
+	tests := map[string]string{
+		"/dev/sr0":         "sr0",
+		"/dev/bus/usb/sr1": "bus/usb/sr1",
+		"/somewhere/sr2":   "sr2",
+	}
+	for device, want := range tests {
+		if got := trimDev(device); got != want {
+			t.Errorf("trimDev(%q) = %q, want %q", device, got, want)
+		}
+	}
+}
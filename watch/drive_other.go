@@ -0,0 +1,18 @@
+//go:build !linux
+
+package watch
+
+// driveStatus is unsupported outside Linux.
+func driveStatus(device string) (Status, error) {
+	return StatusUnknown, ErrUnsupported
+}
+
+// listenUevents is unsupported outside Linux.
+func listenUevents(device string, events chan<- struct{}, done <-chan struct{}) error {
+	return ErrUnsupported
+}
+
+// listDrives is unsupported outside Linux.
+func listDrives() ([]string, error) {
+	return nil, ErrUnsupported
+}
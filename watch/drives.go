@@ -0,0 +1,73 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Drives returns the CD-ROM drives of the system.
+//
+// Returns:
+//   - []string: The device paths, e.g. [/dev/sr0 /dev/sr1], possibly empty.
+//   - error: ErrUnsupported outside Linux, or an error if the drives cannot be listed.
+func Drives() ([]string, error) {
+	return listDrives()
+}
+
+// DriveStatus returns the status of a drive, e.g. to skip the drives without audio disc.
+//
+// Parameters:
+//   - device: The drive device, e.g. /dev/sr0.
+//
+// Returns:
+//   - Status: The status of the drive.
+//   - error: ErrUnsupported outside Linux, or an error if the device cannot be opened or is not a CD drive.
+func DriveStatus(device string) (Status, error) {
+	return driveStatus(device)
+}
+
+// DriveStateFile returns the state file of one drive among several, adding the device name to path,
+// e.g. state-sr1.json for state.json and /dev/sr1. It returns "" if path is empty.
+//
+// Parameters:
+//   - path: The state file setting.
+//   - device: The drive device.
+//
+// Returns:
+//   - string: The state file of the drive.
+func DriveStateFile(path, device string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + filepath.Base(device) + ext
+}
+
+// RunAll runs each watcher in its own goroutine until ctx is canceled. The watchers may share a
+// generator, and so its rate limited clients and cache: a generator is safe for concurrent use.
+//
+// Parameters:
+//   - ctx: Stops the watchers when canceled.
+//   - watchers: The watchers, usually one per drive.
+//
+// Returns:
+//   - error: The errors of the watchers that could not watch their drive, joined, nil when ctx is canceled.
+func RunAll(ctx context.Context, watchers ...*Watcher) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(watchers))
+	for i, w := range watchers {
+		wg.Add(1)
+		go func(i int, w *Watcher) {
+			defer wg.Done()
+			if err := w.Run(ctx); err != nil {
+				w.logger.Error("failed to watch drive", "device", w.device, "error", err)
+				errs[i] = err
+			}
+		}(i, w)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
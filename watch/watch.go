@@ -0,0 +1,296 @@
+// Package watch monitors a disc drive and generates the playlists of each inserted audio disc.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cue"
+	"github.com/b0bbywan/go-disc-cuer/utils"
+)
+
+// notReadyInterval is the delay between two polls while the drive reads a newly inserted disc, which
+// is reported by no uevent once done.
+const notReadyInterval = 500 * time.Millisecond
+
+// ErrUnsupported is returned by Run on systems without the Linux CD-ROM ioctls and uevents.
+var ErrUnsupported = errors.New("drive watching is only supported on Linux")
+
+// Status is the state of a drive, as reported by the drive ioctls.
+type Status int
+
+const (
+	// StatusUnknown is reported when the drive cannot tell its state.
+	StatusUnknown Status = iota
+	// StatusNoDisc is reported when the tray is closed without disc.
+	StatusNoDisc
+	// StatusTrayOpen is reported when the tray is open.
+	StatusTrayOpen
+	// StatusNotReady is reported while the drive reads a newly inserted disc.
+	StatusNotReady
+	// StatusAudio is reported when an audio or mixed mode disc is ready.
+	StatusAudio
+	// StatusData is reported when a disc without audio tracks is ready.
+	StatusData
+)
+
+// String returns a human readable name for the status.
+func (s Status) String() string {
+	switch s {
+	case StatusNoDisc:
+		return "no-disc"
+	case StatusTrayOpen:
+		return "tray-open"
+	case StatusNotReady:
+		return "not-ready"
+	case StatusAudio:
+		return "audio"
+	case StatusData:
+		return "data"
+	default:
+		return "unknown"
+	}
+}
+
+// State values of the state file.
+const (
+	// StateEmpty is written when the drive has no audio disc.
+	StateEmpty = "empty"
+	// StateReading is written while the playlists of the inserted disc are generated.
+	StateReading = "reading"
+	// StateReady is written once the playlists of the inserted disc are generated.
+	StateReady = "ready"
+	// StateError is written when the generation failed. It is not retried until the disc is ejected.
+	StateError = "error"
+)
+
+// State is the content of the state file, describing the disc in the drive for other programs.
+//
+// Fields:
+//   - Device (string): The watched drive.
+//   - State (string): StateEmpty, StateReading, StateReady or StateError.
+//   - Since (time.Time): When the state was entered.
+//   - Disc (*cue.Report): The generation result, in the JSON output schema, once ready or failed.
+type State struct {
+	Device string      `json:"device"`
+	State  string      `json:"state"`
+	Since  time.Time   `json:"since"`
+	Disc   *cue.Report `json:"disc,omitempty"`
+}
+
+// Watcher polls a drive, and listens to its kernel uevents, to generate the playlists of each inserted
+// audio disc.
+type Watcher struct {
+	device    string
+	interval  time.Duration
+	uevents   bool
+	stateFile string
+	logger    *slog.Logger
+	onChange  func(State)
+
+	// The drive and the generator, replaced in tests
+	status           func(device string) (Status, error)
+	listen           func(device string, events chan<- struct{}, done <-chan struct{}) error
+	generateDisc     func(ctx context.Context, req cue.Request) (cue.Result, error)
+	notReadyInterval time.Duration
+}
+
+// Option configures a Watcher.
+type Option func(*Watcher)
+
+// WithInterval sets the delay between two drive status polls. Zero disables polling, leaving only the
+// uevents. Defaults to 2 seconds.
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+// WithUevents enables or disables listening to the kernel uevents of the drive, which detect insertions
+// without waiting for the next poll. Enabled by default.
+func WithUevents(enabled bool) Option {
+	return func(w *Watcher) {
+		w.uevents = enabled
+	}
+}
+
+// WithStateFile sets the JSON file describing the disc in the drive, removed when the watcher stops.
+// No state file is written if empty.
+func WithStateFile(path string) Option {
+	return func(w *Watcher) {
+		w.stateFile = path
+	}
+}
+
+// WithLogger sets the logger used to report drive changes. Changes are not logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Watcher) {
+		w.logger = logger
+	}
+}
+
+// WithOnChange sets a callback invoked on each state change.
+func WithOnChange(onChange func(State)) Option {
+	return func(w *Watcher) {
+		w.onChange = onChange
+	}
+}
+
+// NewWatcher creates a Watcher of device generating playlists with generator.
+//
+// Parameters:
+//   - generator: The generator of the playlists.
+//   - device: The drive to watch, e.g. /dev/sr0.
+//   - opts: Functional options overriding the defaults.
+//
+// Returns:
+//   - *Watcher: The configured watcher.
+//   - error: An error if no device is given or neither polling nor uevents are enabled.
+func NewWatcher(generator *cue.Generator, device string, opts ...Option) (*Watcher, error) {
+	w := &Watcher{
+		device:   device,
+		interval: 2 * time.Second,
+		uevents:  true,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+
+		status:           driveStatus,
+		listen:           listenUevents,
+		generateDisc:     generator.Generate,
+		notReadyInterval: notReadyInterval,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if device == "" {
+		return nil, fmt.Errorf("Failed to create watcher: %w", cue.ErrNoDevice)
+	}
+	if w.interval <= 0 && !w.uevents {
+		return nil, fmt.Errorf("Failed to create watcher: polling and uevents are both disabled")
+	}
+	return w, nil
+}
+
+// Run watches the drive until ctx is canceled. Each audio disc inserted, including one already in the
+// drive, is generated once; ejecting it clears the state. While the drive reads a newly inserted disc,
+// it is polled every notReadyInterval, even with polling disabled.
+//
+// Parameters:
+//   - ctx: Stops the watcher when canceled. Also the context of the generations.
+//
+// Returns:
+//   - error: An error if the drive cannot be watched at all, nil when ctx is canceled.
+func (w *Watcher) Run(ctx context.Context) error {
+	if _, err := w.status(w.device); errors.Is(err, ErrUnsupported) {
+		return err
+	}
+
+	events := make(chan struct{}, 1)
+	done := make(chan struct{})
+	defer close(done)
+	if w.uevents {
+		if err := w.listen(w.device, events, done); err != nil {
+			if w.interval <= 0 {
+				return err
+			}
+			w.logger.Warn("uevents unavailable, polling only", "device", w.device, "error", err)
+		}
+	}
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	if w.stateFile != "" {
+		defer os.Remove(w.stateFile)
+	}
+
+	w.logger.Info("watching drive", "device", w.device, "interval", w.interval, "uevents", w.uevents)
+	last := StatusUnknown
+	w.setState(StateEmpty, nil)
+	for {
+		status, err := w.status(w.device)
+		if err != nil {
+			w.logger.Debug("drive status unavailable", "device", w.device, "error", err)
+		}
+		if status != last {
+			w.logger.Debug("drive status changed", "device", w.device, "from", last, "to", status)
+			w.changed(ctx, last, status)
+			last = status
+		}
+
+		// The end of the reading of a disc is not reported by a uevent
+		var retry <-chan time.Time
+		if last == StatusNotReady {
+			retry = time.After(w.notReadyInterval)
+		}
+		select {
+		case <-ctx.Done():
+			w.logger.Info("stopped watching drive", "device", w.device)
+			return nil
+		case <-tick:
+		case <-events:
+		case <-retry:
+		}
+	}
+}
+
+// changed handles a drive status change: the generation of an inserted audio disc, or clearing the
+// state when the disc is removed.
+func (w *Watcher) changed(ctx context.Context, from, to Status) {
+	switch to {
+	case StatusAudio:
+		w.generate(ctx)
+	case StatusNoDisc, StatusTrayOpen, StatusData:
+		if from == StatusAudio {
+			w.logger.Info("disc ejected", "device", w.device)
+		}
+		w.setState(StateEmpty, nil)
+	}
+}
+
+// generate generates the playlists of the disc in the drive and records the outcome in the state.
+func (w *Watcher) generate(ctx context.Context) {
+	w.logger.Info("audio disc inserted", "device", w.device)
+	w.setState(StateReading, nil)
+	result, err := w.generateDisc(ctx, cue.Request{Device: w.device})
+	report := cue.NewReport(result, err)
+	if err != nil {
+		w.logger.Error("failed to generate playlist", "device", w.device, "error", err)
+		w.setState(StateError, &report)
+		return
+	}
+	w.setState(StateReady, &report)
+}
+
+// setState writes the state file and invokes the change callback.
+func (w *Watcher) setState(state string, disc *cue.Report) {
+	current := State{Device: w.device, State: state, Since: time.Now(), Disc: disc}
+	if w.stateFile != "" {
+		if err := writeState(w.stateFile, current); err != nil {
+			w.logger.Warn("failed to write state file", "path", w.stateFile, "error", err)
+		}
+	}
+	if w.onChange != nil {
+		w.onChange(current)
+	}
+}
+
+// writeState writes state to path atomically, so readers never see a partial file.
+func writeState(path string, state State) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, func(out io.Writer) error {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(state)
+	})
+}
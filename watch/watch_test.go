@@ -0,0 +1,204 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b0bbywan/go-disc-cuer/cue"
+)
+
+// fakeDrive is a drive whose status is set by the tests, holding a disc whose generation fails with err.
+type fakeDrive struct {
+	mu          sync.Mutex
+	status      Status
+	err         error
+	generations int
+	events      chan<- struct{}
+}
+
+// set changes the drive status, sending a uevent if event is set.
+func (d *fakeDrive) set(status Status, event bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = status
+	if event && d.events != nil {
+		select {
+		case d.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (d *fakeDrive) driveStatus(device string) (Status, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status, nil
+}
+
+func (d *fakeDrive) listen(device string, events chan<- struct{}, done <-chan struct{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = events
+	return nil
+}
+
+func (d *fakeDrive) generate(ctx context.Context, req cue.Request) (cue.Result, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.generations++
+	return cue.Result{DiscID: "disc"}, d.err
+}
+
+// count returns the number of generations.
+func (d *fakeDrive) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.generations
+}
+
+// startWatcher runs a watcher of drive until the end of the test, returning its states.
+func startWatcher(t *testing.T, drive *fakeDrive, opts ...Option) <-chan State {
+	t.Helper()
+	states := make(chan State, 100)
+	opts = append([]Option{
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithOnChange(func(state State) { states <- state }),
+	}, opts...)
+	w, err := NewWatcher(nil, "/dev/sr0", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.status, w.listen, w.generateDisc = drive.driveStatus, drive.listen, drive.generate
+	w.notReadyInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	})
+	return states
+}
+
+// expectStates fails unless the next states are want.
+func expectStates(t *testing.T, states <-chan State, want ...string) {
+	t.Helper()
+	for _, state := range want {
+		select {
+		case got := <-states:
+			if got.State != state {
+				t.Fatalf("state = %s, want %s", got.State, state)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no state change, want %s", state)
+		}
+	}
+}
+
+// expectNoState fails if the state changes within a few polls.
+func expectNoState(t *testing.T, states <-chan State) {
+	t.Helper()
+	select {
+	case got := <-states:
+		t.Fatalf("state = %s, want no change", got.State)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatcherInsertEject(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	drive := &fakeDrive{status: StatusNoDisc}
+	states := startWatcher(t, drive, WithInterval(5*time.Millisecond), WithUevents(false), WithStateFile(stateFile))
+	// The initial state, then the empty drive
+	expectStates(t, states, StateEmpty, StateEmpty)
+
+	drive.set(StatusAudio, false)
+	expectStates(t, states, StateReading, StateReady)
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Device != "/dev/sr0" || state.State != StateReady || state.Disc == nil || state.Disc.DiscID != "disc" {
+		t.Errorf("state file = %s, want the ready disc", data)
+	}
+	// The disc stays generated
+	expectNoState(t, states)
+
+	drive.set(StatusTrayOpen, false)
+	expectStates(t, states, StateEmpty)
+	drive.set(StatusAudio, false)
+	expectStates(t, states, StateReading, StateReady)
+	if got := drive.count(); got != 2 {
+		t.Errorf("generations = %d, want one per insertion", got)
+	}
+}
+
+func TestWatcherErrorNotRetried(t *testing.T) {
+	drive := &fakeDrive{status: StatusAudio, err: cue.ErrNotFound}
+	states := startWatcher(t, drive, WithInterval(5*time.Millisecond), WithUevents(false))
+	expectStates(t, states, StateEmpty, StateReading)
+	select {
+	case state := <-states:
+		if state.State != StateError || state.Disc == nil || state.Disc.Error == "" {
+			t.Fatalf("state = %+v, want %s with the report", state, StateError)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no state change, want error")
+	}
+	expectNoState(t, states)
+	if got := drive.count(); got != 1 {
+		t.Errorf("generations = %d, want 1 until the disc is ejected", got)
+	}
+
+	drive.set(StatusNoDisc, false)
+	expectStates(t, states, StateEmpty)
+	drive.set(StatusAudio, false)
+	expectStates(t, states, StateReading, StateError)
+}
+
+func TestWatcherNotReadyWithoutPolling(t *testing.T) {
+	drive := &fakeDrive{status: StatusNoDisc}
+	states := startWatcher(t, drive, WithInterval(0))
+	expectStates(t, states, StateEmpty, StateEmpty)
+
+	// The uevent of the insertion comes while the drive reads the disc, and none once it is ready
+	drive.set(StatusNotReady, true)
+	time.Sleep(20 * time.Millisecond)
+	drive.set(StatusAudio, false)
+	expectStates(t, states, StateReading, StateReady)
+}
+
+func TestWatcherUnsupported(t *testing.T) {
+	w, err := NewWatcher(nil, "/dev/sr0", WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.status = func(device string) (Status, error) { return StatusUnknown, ErrUnsupported }
+	if err := w.Run(context.Background()); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Run() error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestNewWatcherErrors(t *testing.T) {
+	if _, err := NewWatcher(nil, ""); !errors.Is(err, cue.ErrNoDevice) {
+		t.Errorf("NewWatcher() without device: error = %v, want cue.ErrNoDevice", err)
+	}
+	if _, err := NewWatcher(nil, "/dev/sr0", WithInterval(0), WithUevents(false)); err == nil {
+		t.Error("NewWatcher() without polling nor uevents: error = nil")
+	}
+}